@@ -0,0 +1,333 @@
+// Package apidiff compares two descriptions of an API and reports the
+// changes that break clients.
+//
+// Services and methods are matched by name, as they are in routes, and
+// objects are compared by the JSON they are sent as rather than by their
+// Go names, so renaming a type or a field without changing its JSON name
+// is compatible.
+package apidiff
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gitamped/fertilize/gen"
+	"github.com/gitamped/fertilize/parser"
+)
+
+// Severity is whether a change breaks clients.
+type Severity string
+
+const (
+	// Breaking changes break existing clients or servers.
+	Breaking Severity = "breaking"
+	// Compatible changes don't.
+	Compatible Severity = "compatible"
+)
+
+// Kinds of change.
+const (
+	ServiceRemoved   = "service-removed"
+	ServiceAdded     = "service-added"
+	MethodRemoved    = "method-removed"
+	MethodAdded      = "method-added"
+	RequestAdded     = "request-added"
+	RequestRemoved   = "request-removed"
+	ResponseAdded    = "response-added"
+	ResponseRemoved  = "response-removed"
+	FieldRemoved     = "field-removed"
+	FieldAdded       = "field-added"
+	FieldTypeChanged = "field-type-changed"
+	FieldRenamed     = "field-json-name-changed"
+	FieldNowRequired = "field-now-required"
+	FieldNowOptional = "field-now-optional"
+	TypeChanged      = "type-changed"
+)
+
+// Change is a difference between two descriptions.
+type Change struct {
+	Severity Severity `json:"severity"`
+	Kind     string   `json:"kind"`
+	// Path locates the change, for example
+	// "GreeterService.Greet request.Names".
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s: %s", c.Severity, c.Path, c.Message)
+}
+
+// HasBreaking reports whether any of changes are breaking.
+func HasBreaking(changes []Change) bool {
+	for _, c := range changes {
+		if c.Severity == Breaking {
+			return true
+		}
+	}
+	return false
+}
+
+// direction is which way an object is sent. A change that is safe for
+// a request can break a response and the other way round.
+type direction string
+
+const (
+	request  direction = "request"
+	response direction = "response"
+)
+
+// Compare reports the changes from old to new, breaking changes first.
+func Compare(old, new []*parser.Definition) []Change {
+	c := &comparer{
+		old:  gen.NewObjects(old),
+		new:  gen.NewObjects(new),
+		seen: make(map[string]bool),
+	}
+	oldServices, newServices := services(old), services(new)
+	for _, name := range sortedKeys(oldServices) {
+		os := oldServices[name]
+		ns, ok := newServices[name]
+		if !ok {
+			c.add(Breaking, ServiceRemoved, name, "service removed")
+			continue
+		}
+		c.compareService(os, ns)
+	}
+	for _, name := range sortedKeys(newServices) {
+		if _, ok := oldServices[name]; !ok {
+			c.add(Compatible, ServiceAdded, name, "service added")
+		}
+	}
+	sort.SliceStable(c.changes, func(i, j int) bool {
+		return c.changes[i].Severity == Breaking && c.changes[j].Severity != Breaking
+	})
+	return c.changes
+}
+
+func services(defs []*parser.Definition) map[string]parser.Service {
+	services := make(map[string]parser.Service)
+	for _, d := range defs {
+		for _, s := range d.Services {
+			services[s.Name] = s
+		}
+	}
+	return services
+}
+
+func sortedKeys(m map[string]parser.Service) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type comparer struct {
+	old, new gen.Objects
+	// seen are the pairs of objects compared already, so each change
+	// is reported once and recursive types end.
+	seen    map[string]bool
+	changes []Change
+}
+
+func (c *comparer) add(severity Severity, kind, path, format string, args ...interface{}) {
+	c.changes = append(c.changes, Change{
+		Severity: severity,
+		Kind:     kind,
+		Path:     path,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (c *comparer) compareService(old, new parser.Service) {
+	newMethods := make(map[string]parser.Method)
+	for _, m := range new.Methods {
+		newMethods[m.Name] = m
+	}
+	oldMethods := make(map[string]bool)
+	for _, om := range old.Methods {
+		oldMethods[om.Name] = true
+		path := old.Name + "." + om.Name
+		nm, ok := newMethods[om.Name]
+		if !ok {
+			c.add(Breaking, MethodRemoved, path, "method removed")
+			continue
+		}
+		oldReq, newReq := gen.Request(om), gen.Request(nm)
+		switch {
+		case oldReq != nil && newReq != nil:
+			c.compareType(path+" request", gen.TypeOf(*oldReq), gen.TypeOf(*newReq), request)
+		case oldReq != nil:
+			c.add(Compatible, RequestRemoved, path, "no longer takes a request object")
+		case newReq != nil:
+			c.add(Breaking, RequestAdded, path, "now takes a request object")
+		}
+		oldRes, newRes := gen.Response(om), gen.Response(nm)
+		switch {
+		case oldRes != nil && newRes != nil:
+			c.compareType(path+" response", gen.TypeOf(*oldRes), gen.TypeOf(*newRes), response)
+		case oldRes != nil:
+			c.add(Breaking, ResponseRemoved, path, "no longer returns a response")
+		case newRes != nil:
+			c.add(Compatible, ResponseAdded, path, "now returns a response")
+		}
+	}
+	for _, nm := range new.Methods {
+		if !oldMethods[nm.Name] {
+			c.add(Compatible, MethodAdded, new.Name+"."+nm.Name, "method added")
+		}
+	}
+}
+
+// compareType compares the JSON of two types, reporting a change of
+// shape or comparing the fields of objects.
+func (c *comparer) compareType(path string, old, new *gen.Type, dir direction) {
+	old, new = old.Deref(), new.Deref()
+	oldShape, newShape := c.shape(old, c.old), c.shape(new, c.new)
+	if oldShape != newShape {
+		c.add(Breaking, TypeChanged, path, "type changed from %s to %s", old, new)
+		return
+	}
+	old, new = old.JSON(), new.JSON()
+	switch old.Kind {
+	case gen.Slice, gen.Map:
+		c.compareType(path, old.Elem, new.Elem, dir)
+	case gen.Named:
+		oo, no := c.old.Lookup(old), c.new.Lookup(new)
+		if oo != nil && no != nil {
+			c.compareObject(path, oo, no, dir)
+		}
+	}
+}
+
+// shape describes the kind of JSON a type is encoded as, ignoring
+// null, so types with the same shape are interchangeable. Named types
+// that aren't objects have the shape of their underlying types, so
+// type Status string is a string.
+func (c *comparer) shape(t *gen.Type, objects gen.Objects) string {
+	t = t.Deref().JSON()
+	switch {
+	case t.IsBytes():
+		return "bytes"
+	case t.IsTime():
+		return "time"
+	}
+	switch t.Kind {
+	case gen.Slice:
+		return "[]" + c.shape(t.Elem, objects)
+	case gen.Map:
+		return "map[" + c.shape(t.Key, objects) + "]" + c.shape(t.Elem, objects)
+	case gen.Basic:
+		switch {
+		case t.Name == "string":
+			return "string"
+		case t.Name == "bool":
+			return "boolean"
+		case t.IsInteger():
+			return "integer"
+		case t.IsFloat():
+			return "number"
+		}
+	case gen.Named:
+		if objects.Lookup(t) != nil {
+			return "object"
+		}
+		return t.String()
+	}
+	return "any"
+}
+
+// field is a field of an object as encoded in JSON.
+type field struct {
+	parser.Field
+	json gen.JSONField
+}
+
+func fields(o *parser.Object) []field {
+	var fields []field
+	for _, f := range o.Fields {
+		jf := gen.JSON(f)
+		if !jf.Skip {
+			fields = append(fields, field{Field: f, json: jf})
+		}
+	}
+	return fields
+}
+
+// optional reports whether a field can be left out.
+func (f field) optional() bool {
+	if gen.IsRequired(f.Field) {
+		return false
+	}
+	return f.json.OmitEmpty || gen.TypeOf(f.Type).Kind == gen.Pointer
+}
+
+func (c *comparer) compareObject(path string, old, new *parser.Object, dir direction) {
+	key := string(dir) + " " + old.TypeID + " " + new.TypeID
+	if c.seen[key] {
+		return
+	}
+	c.seen[key] = true
+
+	oldFields, newFields := fields(old), fields(new)
+	matched := make(map[string]bool)
+	for _, of := range oldFields {
+		nf, ok := match(of, newFields)
+		fieldPath := path + "." + of.json.Name
+		if !ok {
+			c.add(Breaking, FieldRemoved, fieldPath, "field removed")
+			continue
+		}
+		matched[nf.Name] = true
+		if of.json.Name != nf.json.Name {
+			c.add(Breaking, FieldRenamed, fieldPath, "JSON name changed from %s to %s", of.json.Name, nf.json.Name)
+		}
+		switch {
+		case dir == request && of.optional() && !nf.optional():
+			c.add(Breaking, FieldNowRequired, fieldPath, "field is now required")
+		case dir == response && !of.optional() && nf.optional():
+			c.add(Breaking, FieldNowOptional, fieldPath, "field may now be left out")
+		}
+		if of.json.String != nf.json.String {
+			c.add(Breaking, FieldTypeChanged, fieldPath, "string option changed")
+			continue
+		}
+		before := len(c.changes)
+		c.compareType(fieldPath, gen.TypeOf(of.Type), gen.TypeOf(nf.Type), dir)
+		// report type changes of fields as such
+		for i := before; i < len(c.changes); i++ {
+			if c.changes[i].Kind == TypeChanged && c.changes[i].Path == fieldPath {
+				c.changes[i].Kind = FieldTypeChanged
+			}
+		}
+	}
+	for _, nf := range newFields {
+		if matched[nf.Name] {
+			continue
+		}
+		fieldPath := path + "." + nf.json.Name
+		if dir == request && gen.IsRequired(nf.Field) {
+			c.add(Breaking, FieldAdded, fieldPath, "required field added")
+			continue
+		}
+		c.add(Compatible, FieldAdded, fieldPath, "field added")
+	}
+}
+
+// match finds the field f became: the field with the same Go name, or
+// else the same JSON name.
+func match(f field, fields []field) (field, bool) {
+	for _, nf := range fields {
+		if nf.Name == f.Name {
+			return nf, true
+		}
+	}
+	for _, nf := range fields {
+		if nf.json.Name == f.json.Name {
+			return nf, true
+		}
+	}
+	return field{}, false
+}
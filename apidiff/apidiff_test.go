@@ -0,0 +1,286 @@
+package apidiff
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fatih/structtag"
+	"github.com/gitamped/fertilize/parser"
+)
+
+// typ is the FieldType of a Go type. Capitalized names without a
+// package are objects.
+func typ(name string) parser.FieldType {
+	clean := strings.TrimPrefix(name, "*")
+	return parser.FieldType{
+		TypeName:        name,
+		ObjectName:      name,
+		CleanObjectName: clean,
+		IsObject:        !strings.ContainsAny(clean, ".[") && strings.ToUpper(clean[:1]) == clean[:1],
+	}
+}
+
+// status is the FieldType of type Status string.
+var status = parser.FieldType{
+	TypeName: "Status",
+	Expr: &parser.TypeExpr{
+		Kind:       parser.KindNamed,
+		Name:       "Status",
+		Underlying: &parser.TypeExpr{Kind: parser.KindBasic, Name: "string"},
+	},
+}
+
+func newField(name string, ft parser.FieldType, tag string) parser.Field {
+	tags, err := structtag.Parse(tag)
+	if err != nil {
+		panic(err)
+	}
+	parsed := make(map[string]parser.FieldTag)
+	for _, t := range tags.Tags() {
+		parsed[t.Key] = parser.FieldTag{Value: t.Name, Options: t.Options}
+	}
+	return parser.Field{Name: name, Type: ft, Tag: tag, ParsedTags: parsed}
+}
+
+func object(name string, fields ...parser.Field) parser.Object {
+	return parser.Object{Name: name, TypeID: "example.com/api." + name, Fields: fields}
+}
+
+// api describes a package with the service S, whose method M takes req
+// and returns res, either of which may be "" for none.
+func api(req, res string, objects ...parser.Object) []*parser.Definition {
+	m := parser.Method{Name: "M"}
+	if req != "" {
+		m.InputObjects = []parser.FieldType{typ(req)}
+	}
+	if res != "" {
+		m.OutputObjects = []parser.FieldType{typ(res)}
+	}
+	return []*parser.Definition{{
+		PackageName: "api",
+		Services:    []parser.Service{{Name: "S", Methods: []parser.Method{m}}},
+		Objects:     objects,
+	}}
+}
+
+// renamed renames the service and method of an api.
+func renamed(defs []*parser.Definition, service, method string) []*parser.Definition {
+	defs[0].Services[0].Name = service
+	defs[0].Services[0].Methods[0].Name = method
+	return defs
+}
+
+// withRequest is an api whose request has the fields, and whose response
+// has none.
+func withRequest(fields ...parser.Field) []*parser.Definition {
+	return api("Req", "Res", object("Req", fields...), object("Res"))
+}
+
+// withResponse is an api whose response has the fields, and whose request
+// has none.
+func withResponse(fields ...parser.Field) []*parser.Definition {
+	return api("Req", "Res", object("Req"), object("Res", fields...))
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new []*parser.Definition
+		want     []string
+	}{
+		{
+			name: "unchanged",
+			old:  withRequest(newField("A", typ("string"), "")),
+			new:  withRequest(newField("A", typ("string"), "")),
+		},
+		{
+			name: "service removed",
+			old:  api("Req", "Res", object("Req"), object("Res")),
+			new:  renamed(api("Req", "Res", object("Req"), object("Res")), "T", "M"),
+			want: []string{
+				"breaking service-removed S",
+				"compatible service-added T",
+			},
+		},
+		{
+			name: "method removed",
+			old:  api("Req", "Res", object("Req"), object("Res")),
+			new:  renamed(api("Req", "Res", object("Req"), object("Res")), "S", "N"),
+			want: []string{
+				"breaking method-removed S.M",
+				"compatible method-added S.N",
+			},
+		},
+		{
+			name: "request added",
+			old:  api("", "Res", object("Res")),
+			new:  api("Req", "Res", object("Req"), object("Res")),
+			want: []string{"breaking request-added S.M"},
+		},
+		{
+			name: "request removed",
+			old:  api("Req", "Res", object("Req"), object("Res")),
+			new:  api("", "Res", object("Res")),
+			want: []string{"compatible request-removed S.M"},
+		},
+		{
+			name: "response removed",
+			old:  api("Req", "Res", object("Req"), object("Res")),
+			new:  api("Req", "", object("Req")),
+			want: []string{"breaking response-removed S.M"},
+		},
+		{
+			name: "response added",
+			old:  api("Req", "", object("Req")),
+			new:  api("Req", "Res", object("Req"), object("Res")),
+			want: []string{"compatible response-added S.M"},
+		},
+		{
+			name: "response type changed",
+			old:  api("Req", "Res", object("Req"), object("Res")),
+			new:  api("Req", "string", object("Req")),
+			want: []string{"breaking type-changed S.M response"},
+		},
+		{
+			name: "object renamed",
+			old:  api("Req", "Res", object("Req"), object("Res", newField("A", typ("int"), ""))),
+			new:  api("Req", "Reply", object("Req"), object("Reply", newField("A", typ("int"), ""))),
+		},
+		{
+			name: "field removed",
+			old:  withRequest(newField("A", typ("string"), ""), newField("B", typ("string"), "")),
+			new:  withRequest(newField("A", typ("string"), "")),
+			want: []string{"breaking field-removed S.M request.B"},
+		},
+		{
+			name: "optional field added",
+			old:  withRequest(newField("A", typ("string"), "")),
+			new:  withRequest(newField("A", typ("string"), ""), newField("B", typ("string"), "")),
+			want: []string{"compatible field-added S.M request.B"},
+		},
+		{
+			name: "required request field added",
+			old:  withRequest(newField("A", typ("string"), "")),
+			new:  withRequest(newField("A", typ("string"), ""), newField("B", typ("string"), `validate:"required"`)),
+			want: []string{"breaking field-added S.M request.B"},
+		},
+		{
+			name: "required response field added",
+			old:  withResponse(newField("A", typ("string"), "")),
+			new:  withResponse(newField("A", typ("string"), ""), newField("B", typ("string"), `validate:"required"`)),
+			want: []string{"compatible field-added S.M response.B"},
+		},
+		{
+			name: "JSON name changed",
+			old:  withRequest(newField("A", typ("string"), `json:"a"`)),
+			new:  withRequest(newField("A", typ("string"), `json:"b"`)),
+			want: []string{"breaking field-json-name-changed S.M request.a"},
+		},
+		{
+			name: "Go name changed",
+			old:  withRequest(newField("A", typ("string"), `json:"a"`)),
+			new:  withRequest(newField("B", typ("string"), `json:"a"`)),
+		},
+		{
+			name: "field type changed",
+			old:  withRequest(newField("A", typ("int"), "")),
+			new:  withRequest(newField("A", typ("string"), "")),
+			want: []string{"breaking field-type-changed S.M request.A"},
+		},
+		{
+			name: "integer size changed",
+			old:  withRequest(newField("A", typ("int32"), "")),
+			new:  withRequest(newField("A", typ("int64"), "")),
+		},
+		{
+			name: "pointer added",
+			old:  withResponse(newField("A", typ("string"), "")),
+			new:  withResponse(newField("A", typ("*string"), "")),
+			want: []string{"breaking field-now-optional S.M response.A"},
+		},
+		{
+			name: "request field now required",
+			old:  withRequest(newField("A", typ("string"), `json:",omitempty"`)),
+			new:  withRequest(newField("A", typ("string"), `validate:"required"`)),
+			want: []string{"breaking field-now-required S.M request.A"},
+		},
+		{
+			name: "response field now optional",
+			old:  withResponse(newField("A", typ("string"), "")),
+			new:  withResponse(newField("A", typ("string"), `json:",omitempty"`)),
+			want: []string{"breaking field-now-optional S.M response.A"},
+		},
+		{
+			name: "request field now optional",
+			old:  withRequest(newField("A", typ("string"), "")),
+			new:  withRequest(newField("A", typ("string"), `json:",omitempty"`)),
+		},
+		{
+			name: "string option changed",
+			old:  withRequest(newField("A", typ("int"), "")),
+			new:  withRequest(newField("A", typ("int"), `json:",string"`)),
+			want: []string{"breaking field-type-changed S.M request.A"},
+		},
+		{
+			name: "named type to its underlying type",
+			old:  withRequest(newField("A", status, "")),
+			new:  withRequest(newField("A", typ("string"), "")),
+		},
+		{
+			name: "named type to another type",
+			old:  withRequest(newField("A", status, "")),
+			new:  withRequest(newField("A", typ("int"), "")),
+			want: []string{"breaking field-type-changed S.M request.A"},
+		},
+		{
+			name: "time to string",
+			old:  withRequest(newField("A", typ("time.Time"), "")),
+			new:  withRequest(newField("A", typ("string"), "")),
+			want: []string{"breaking field-type-changed S.M request.A"},
+		},
+		{
+			name: "slice element changed",
+			old:  withRequest(newField("A", typ("[]int"), "")),
+			new:  withRequest(newField("A", typ("[]string"), "")),
+			want: []string{"breaking field-type-changed S.M request.A"},
+		},
+		{
+			name: "nested object field removed",
+			old: api("Req", "Res", object("Req", newField("Item", typ("Item"), "")), object("Res"),
+				object("Item", newField("A", typ("int"), ""))),
+			new: api("Req", "Res", object("Req", newField("Item", typ("Item"), "")), object("Res"),
+				object("Item")),
+			want: []string{"breaking field-removed S.M request.Item.A"},
+		},
+		{
+			name: "breaking changes first",
+			old:  withRequest(newField("A", typ("string"), "")),
+			new:  withRequest(newField("B", typ("string"), "")),
+			want: []string{
+				"breaking field-removed S.M request.A",
+				"compatible field-added S.M request.B",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, c := range Compare(tt.old, tt.new) {
+				got = append(got, string(c.Severity)+" "+c.Kind+" "+c.Path)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Compare =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestHasBreaking(t *testing.T) {
+	if HasBreaking([]Change{{Severity: Compatible}}) {
+		t.Error("HasBreaking of compatible changes = true")
+	}
+	if !HasBreaking([]Change{{Severity: Compatible}, {Severity: Breaking}}) {
+		t.Error("HasBreaking of a breaking change = false")
+	}
+}
@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/gitamped/fertilize/apidiff"
+	"github.com/gitamped/fertilize/parser"
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffGit  bool
+	diffJSON bool
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff old.json new.json | diff --git old-ref [new-ref]",
+	Short: "Reports changes between two API descriptions that break clients.",
+	Long: `Compares two descriptions of the API and classifies each change as
+breaking or compatible. Exits with a non-zero status if any change is
+breaking.
+
+The descriptions are JSON files written by the json format, or with
+--git, git refs whose trees are parsed with the current config. The
+new ref defaults to the working tree.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(cmd.Flags())
+		if err != nil {
+			return err
+		}
+		var old, new *parser.Document
+		if diffGit {
+			if old, err = parseRef(cfg, args[0]); err != nil {
+				return err
+			}
+			if len(args) == 2 {
+				new, err = parseRef(cfg, args[1])
+			} else {
+				new, err = parse(cfg, cfg.Packages...)
+			}
+		} else {
+			if len(args) != 2 {
+				return errors.New("diff needs an old and a new file")
+			}
+			if old, err = readDocument(args[0]); err != nil {
+				return err
+			}
+			new, err = readDocument(args[1])
+		}
+		if err != nil {
+			return err
+		}
+		changes := apidiff.Compare(old.Packages, new.Packages)
+		if err := printChanges(os.Stdout, changes, diffJSON); err != nil {
+			return err
+		}
+		if apidiff.HasBreaking(changes) {
+			return errors.New("breaking changes found")
+		}
+		return nil
+	},
+}
+
+func init() {
+	diffCmd.Flags().BoolVar(&diffGit, "git", false, "compare git refs instead of files")
+	diffCmd.Flags().BoolVar(&diffJSON, "json", false, "print the changes as JSON")
+	rootCmd.AddCommand(diffCmd)
+}
+
+// readDocument reads a Document written by the json format.
+func readDocument(path string) (*parser.Document, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// check the version first, as older documents may not decode
+	var version struct {
+		SchemaVersion string `json:"schemaVersion"`
+	}
+	if err := json.Unmarshal(b, &version); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if err := checkSchemaVersion(version.SchemaVersion); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	var doc parser.Document
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return &doc, nil
+}
+
+// parseRef parses the packages as they are at a git ref, checking it
+// out in a temporary worktree.
+func parseRef(cfg *Config, ref string) (*parser.Document, error) {
+	top, err := git(cfg.Dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(top, cfg.Dir)
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := os.MkdirTemp("", "fertilize-diff-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	worktree := filepath.Join(tmp, "tree")
+	if _, err := git(top, "worktree", "add", "--detach", worktree, ref); err != nil {
+		return nil, err
+	}
+	defer git(top, "worktree", "remove", "--force", worktree)
+
+	refCfg := *cfg
+	refCfg.Dir = filepath.Join(worktree, rel)
+	doc, err := parse(&refCfg, refPatterns(cfg.Packages, top, worktree)...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", ref, err)
+	}
+	return doc, nil
+}
+
+// refPatterns moves the absolute package patterns, like the directory go
+// generate runs fertilize in, from the working tree at top into
+// worktree. Other patterns are left as they are.
+func refPatterns(patterns []string, top, worktree string) []string {
+	moved := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		moved[i] = pattern
+		if !filepath.IsAbs(pattern) {
+			continue
+		}
+		rel, err := filepath.Rel(top, pattern)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		moved[i] = filepath.Join(worktree, rel)
+	}
+	return moved
+}
+
+// git runs a git command in dir, returning its trimmed output.
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		var stderr string
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			stderr = strings.TrimSpace(string(exitErr.Stderr))
+		}
+		return "", fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, stderr)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// printChanges writes changes as text, one per line, or as JSON.
+func printChanges(w io.Writer, changes []apidiff.Change, asJSON bool) error {
+	if asJSON {
+		if changes == nil {
+			changes = []apidiff.Change{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(struct {
+			Breaking bool             `json:"breaking"`
+			Changes  []apidiff.Change `json:"changes"`
+		}{apidiff.HasBreaking(changes), changes})
+	}
+	for _, c := range changes {
+		fmt.Fprintln(w, c)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRefPatterns(t *testing.T) {
+	top := filepath.FromSlash("/src/repo")
+	worktree := filepath.FromSlash("/tmp/fertilize-diff-1/tree")
+	patterns := []string{
+		"./services/...",
+		filepath.FromSlash("/src/repo/services/pleasantries"),
+		filepath.FromSlash("/src/repo/services/..."),
+		filepath.FromSlash("/src/repo"),
+		filepath.FromSlash("/src/other/services"),
+		"github.com/gitamped/fertilize/examples/testdata/services",
+	}
+	want := []string{
+		"./services/...",
+		filepath.FromSlash("/tmp/fertilize-diff-1/tree/services/pleasantries"),
+		filepath.FromSlash("/tmp/fertilize-diff-1/tree/services/..."),
+		filepath.FromSlash("/tmp/fertilize-diff-1/tree"),
+		filepath.FromSlash("/src/other/services"),
+		"github.com/gitamped/fertilize/examples/testdata/services",
+	}
+	if got := refPatterns(patterns, top, worktree); !reflect.DeepEqual(got, want) {
+		t.Errorf("refPatterns =\n%q\nwant\n%q", got, want)
+	}
+}
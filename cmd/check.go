@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gitamped/fertilize/output"
+	"github.com/spf13/cobra"
+)
+
+var (
+	dryRun   bool
+	showDiff bool
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Fails if generated files are out of date.",
+	Long: `Renders every output in memory and compares it to the file on disk.
+
+A unified diff is printed for each file that differs and the command
+exits with a non-zero status. Nothing is written, so it can be used as
+a pre-merge gate in CI.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(cmd.Flags())
+		if err != nil {
+			return err
+		}
+		return check(cfg, os.Stdout, true)
+	},
+}
+
+func init() {
+	generateCmd.Flags().BoolVar(&dryRun, "dry-run", false, "render without writing and fail if any file would change")
+	generateCmd.Flags().BoolVar(&showDiff, "diff", false, "print a unified diff of each change; implies --dry-run")
+	rootCmd.AddCommand(checkCmd)
+}
+
+// check renders every output and compares it to what is on disk,
+// reporting files that differ to w. Returns an error if any do.
+func check(cfg *Config, w io.Writer, diff bool) error {
+	doc, err := parse(cfg, cfg.Packages...)
+	if err != nil {
+		return err
+	}
+	files, err := renderFiles(cfg, doc)
+	if err != nil {
+		return err
+	}
+	var stale int
+	for _, f := range files {
+		if f.Path == "" {
+			continue
+		}
+		current, err := os.ReadFile(f.Path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		if bytes.Equal(current, f.Content) {
+			continue
+		}
+		stale++
+		name := f.Path
+		if rel, err := filepath.Rel(cfg.Dir, f.Path); err == nil && !strings.HasPrefix(rel, "..") {
+			name = rel
+		}
+		if !diff {
+			fmt.Fprintln(w, name)
+			continue
+		}
+		fmt.Fprint(w, output.UnifiedDiff("a/"+name, "b/"+name, current, f.Content))
+	}
+	if stale > 0 {
+		return fmt.Errorf("%d generated files are out of date (run fertilize generate)", stale)
+	}
+	return nil
+}
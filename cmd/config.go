@@ -0,0 +1,290 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gitamped/fertilize/parser"
+	"github.com/gitamped/fertilize/templates"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// configNames are the file names searched for when no --config flag
+// is given, in order of preference.
+var configNames = []string{"fertilize.yaml", "fertilize.yml", "fertilize.toml"}
+
+// Config describes a fertilize run.
+//
+// Values are read from a fertilize.yaml or fertilize.toml file found in
+// the working directory or one of its parents (up to the module root),
+// then overridden by FERTILIZE_* environment variables and finally by
+// command line flags.
+type Config struct {
+	// Dir is the directory relative paths are resolved against.
+	// It is the directory of the config file, or the working
+	// directory when there is none.
+	Dir string `mapstructure:"-"`
+	// File is the config file that was loaded, if any.
+	File string `mapstructure:"-"`
+	// OutDir is the directory relative output paths are resolved
+	// against. It is Dir, or the package directory when run by
+	// go generate.
+	OutDir string `mapstructure:"-"`
+
+	// Packages are the package patterns to describe.
+	Packages []string `mapstructure:"-"`
+	// Ignore are the names of interfaces to ignore.
+	Ignore []string `mapstructure:"-"`
+	// Filter selects the interfaces and structs to describe.
+	Filter parser.Filter `mapstructure:"-"`
+	// Verbose enables verbose output.
+	Verbose bool `mapstructure:"-"`
+	// Interface limits the services described to the named
+	// interface. It is set by --scope=interface under go generate.
+	Interface string `mapstructure:"-"`
+
+	// Outputs are the templates to render and where to write them.
+	Outputs []Output `mapstructure:"outputs"`
+	// Options are made available to templates via the option function.
+	Options map[string]interface{} `mapstructure:"options"`
+	// Engines are external template engines keyed by name.
+	Engines map[string]Engine `mapstructure:"engines"`
+	// Tests are the template tests run by fertilize test.
+	Tests []Test `mapstructure:"tests"`
+}
+
+// Output pairs a template or built-in format with the file it is
+// rendered to.
+type Output struct {
+	// Template is the path to the template file, or builtin:<name>
+	// for a built-in template.
+	Template string `mapstructure:"tmpl"`
+	// Engine names the engine rendering the template: text, html or
+	// one of the configured Engines. Defaults to the engine for the
+	// template's extension, html for .html, .htm and .gohtml
+	// files, and otherwise text.
+	Engine string `mapstructure:"engine"`
+	// Format names a built-in generator to use instead of a template,
+	// such as openapi.
+	Format string `mapstructure:"format"`
+	// Plugin names an external generator to run instead of a
+	// template, found on PATH as is or prefixed with fertilize-gen-.
+	Plugin string `mapstructure:"plugin"`
+	// Options configure the built-in generator, or are passed to the
+	// plugin as parameters.
+	Options map[string]interface{} `mapstructure:"options"`
+	// Out is the output file, or for plugins the directory the files
+	// they generate are written to. It may contain template actions which
+	// are executed against each Definition, for example
+	// "{{.PackageName}}/handlers.go". Empty means stdout. Built-in
+	// formats describe every package in one file unless Out contains
+	// template actions.
+	Out string `mapstructure:"out"`
+}
+
+// initConfig prepares viper to read the config file and environment.
+func initConfig() {
+	viper.SetEnvPrefix("fertilize")
+	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_", ".", "_"))
+	viper.AutomaticEnv()
+}
+
+// loadConfig reads the config file, if there is one, and merges it with
+// the environment and flags.
+func loadConfig(flags *pflag.FlagSet) (*Config, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	file := viper.GetString("config")
+	if file == "" {
+		file = findConfig(wd)
+	}
+	cfg := &Config{Dir: wd}
+	if file != "" {
+		viper.SetConfigFile(file)
+		if err := viper.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("reading config %s: %w", file, err)
+		}
+		cfg.File = file
+		cfg.Dir = filepath.Dir(file)
+		if !filepath.IsAbs(cfg.Dir) {
+			cfg.Dir = filepath.Join(wd, cfg.Dir)
+		}
+	}
+	if err := viper.Unmarshal(cfg); err != nil {
+		return nil, fmt.Errorf("decoding config: %w", err)
+	}
+	cfg.Packages = stringList(viper.Get("pkgs"))
+	cfg.Ignore = stringList(viper.Get("ignore"))
+	cfg.Filter = parser.Filter{
+		Include:   stringList(viper.Get("include")),
+		Exclude:   stringList(viper.Get("exclude")),
+		Shapes:    shapeList(viper.Get("shapes")),
+		Tags:      stringList(viper.Get("tags")),
+		Directive: viper.GetBool("directive"),
+		Reachable: viper.GetBool("reachable"),
+	}
+	// shapes contain commas, so the flag is read as is rather than
+	// through viper
+	if flags.Changed("shapes") {
+		cfg.Filter.Shapes, _ = flags.GetStringArray("shapes")
+	}
+	cfg.Verbose = viper.GetBool("verbose")
+	cfg.OutDir = cfg.Dir
+	if err := cfg.applyGoGenerate(flags); err != nil {
+		return nil, err
+	}
+
+	// an explicit --tmpl or --out, or a config without outputs,
+	// describes a single output
+	if len(cfg.Outputs) == 0 || isSet(flags, "tmpl") || isSet(flags, "out") || isSet(flags, "format") || isSet(flags, "plugin") || isSet(flags, "engine") {
+		o := Output{
+			Template: viper.GetString("tmpl"),
+			Engine:   viper.GetString("engine"),
+			Format:   viper.GetString("format"),
+			Plugin:   viper.GetString("plugin"),
+			Out:      viper.GetString("out"),
+		}
+		if o.Format != "" || o.Plugin != "" {
+			o.Template = ""
+		}
+		// paths given on the command line or in the environment
+		// are relative to the working directory, not the config
+		// file
+		if _, builtin := templates.Name(o.Template); isSet(flags, "tmpl") && !builtin {
+			o.Template = absPath(wd, o.Template)
+		}
+		if isSet(flags, "plugin") && strings.ContainsAny(o.Plugin, "/"+string(filepath.Separator)) {
+			o.Plugin = absPath(wd, o.Plugin)
+		}
+		if isSet(flags, "out") {
+			o.Out = absPath(wd, o.Out)
+		}
+		cfg.Outputs = []Output{o}
+	}
+	return cfg, nil
+}
+
+// applyGoGenerate scopes the config to the package containing the
+// go:generate directive when fertilize is run by go generate.
+func (c *Config) applyGoGenerate(flags *pflag.FlagSet) error {
+	scope := viper.GetString("scope")
+	g, err := detectGoGenerate()
+	if err != nil {
+		return err
+	}
+	if g == nil {
+		if scope == "interface" {
+			return errors.New("--scope=interface only works under go generate")
+		}
+		return nil
+	}
+	// a pattern given explicitly wins, but not the pkgs of the
+	// config file, which is shared by every package
+	if !isSet(flags, "pkgs") {
+		c.Packages = []string{g.Dir}
+	}
+	c.OutDir = g.Dir
+	switch scope {
+	case "", "package":
+	case "interface":
+		c.Interface, err = g.nextInterface()
+		if err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown scope %q (want package or interface)", scope)
+	}
+	return nil
+}
+
+// isSet reports whether the setting name was given by a flag or a
+// FERTILIZE_* environment variable, rather than by the config file or
+// the flag default.
+func isSet(flags *pflag.FlagSet, name string) bool {
+	if flags.Changed(name) {
+		return true
+	}
+	_, ok := os.LookupEnv("FERTILIZE_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")))
+	return ok
+}
+
+// findConfig looks for a config file in dir and its parents, stopping
+// at the directory containing go.mod. Returns an empty string if none
+// is found.
+func findConfig(dir string) string {
+	for {
+		for _, name := range configNames {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err == nil {
+				return path
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// path resolves a path from the config against the config directory.
+func (c *Config) path(p string) string {
+	return absPath(c.Dir, p)
+}
+
+// outPath resolves an output path against the output directory.
+func (c *Config) outPath(p string) string {
+	return absPath(c.OutDir, p)
+}
+
+// absPath resolves p against dir unless it is empty or already absolute.
+func absPath(dir, p string) string {
+	if p == "" || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(dir, p)
+}
+
+// stringList normalises a list value that may come from a config file
+// (a list) or a flag or environment variable (a comma separated string).
+func stringList(v interface{}) []string {
+	var items []string
+	switch v := v.(type) {
+	case string:
+		items = strings.Split(v, ",")
+	case []string:
+		items = v
+	case []interface{}:
+		for _, item := range v {
+			items = append(items, fmt.Sprint(item))
+		}
+	}
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		list = append(list, item)
+	}
+	return list
+}
+
+// shapeList normalises a list of method signature shapes, which may come
+// from a config file (a list) or an environment variable (separated by
+// semicolons, as the shapes contain commas).
+func shapeList(v interface{}) []string {
+	if s, ok := v.(string); ok {
+		v = strings.Split(s, ";")
+	}
+	return stringList(v)
+}
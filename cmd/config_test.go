@@ -0,0 +1,301 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+// chdir changes to dir for the rest of the test.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+// writeConfig writes a fertilize.yaml to a temporary directory and
+// changes to it.
+func writeConfig(t *testing.T, config string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "fertilize.yaml"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	chdir(t, dir)
+	initConfig()
+	return dir
+}
+
+// setFlag sets the flag name of the root command for the rest of the
+// test.
+func setFlag(t *testing.T, name, value string) {
+	t.Helper()
+	f := rootCmd.PersistentFlags().Lookup(name)
+	if err := f.Value.Set(value); err != nil {
+		t.Fatal(err)
+	}
+	f.Changed = true
+	t.Cleanup(func() {
+		// setting a slice flag appends to it
+		if s, ok := f.Value.(pflag.SliceValue); ok {
+			s.Replace(nil)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	})
+}
+
+func TestFindConfig(t *testing.T) {
+	root := t.TempDir()
+	mkdir := func(dir string, files ...string) string {
+		dir = filepath.Join(root, dir)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range files {
+			if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir
+	}
+	// a config above the module isn't found
+	mkdir("", "fertilize.yaml")
+	mkdir("mod", "go.mod")
+	mkdir("mod/toml", "fertilize.toml")
+	mkdir("mod/both", "fertilize.toml", "fertilize.yml", "fertilize.yaml")
+	mkdir("mod/nested/pkg/sub")
+	mkdir("mod/nested", "fertilize.yml")
+	mkdir("mod/none/pkg")
+	tests := []struct {
+		dir, want string
+	}{
+		{dir: "mod/toml", want: "mod/toml/fertilize.toml"},
+		{dir: "mod/both", want: "mod/both/fertilize.yaml"},
+		{dir: "mod/nested/pkg/sub", want: "mod/nested/fertilize.yml"},
+		{dir: "mod/none/pkg"},
+		{dir: "mod"},
+	}
+	for _, tt := range tests {
+		want := ""
+		if tt.want != "" {
+			want = filepath.Join(root, tt.want)
+		}
+		if got := findConfig(filepath.Join(root, tt.dir)); got != want {
+			t.Errorf("findConfig(%s) = %q, want %q", tt.dir, got, want)
+		}
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	const config = `
+pkgs: [./config/...]
+outputs:
+  - tmpl: handlers.tmpl
+    out: handlers.go
+`
+	tests := []struct {
+		name  string
+		env   map[string]string
+		flags map[string]string
+		// pkgs are the package patterns expected.
+		pkgs []string
+		// tmpl is the template of the single output expected, or
+		// empty for the config's.
+		tmpl string
+	}{
+		{
+			name: "config",
+			pkgs: []string{"./config/..."},
+		},
+		{
+			name: "environment",
+			env:  map[string]string{"FERTILIZE_PKGS": "./env/..., ./other/..."},
+			pkgs: []string{"./env/...", "./other/..."},
+		},
+		{
+			name:  "flag",
+			env:   map[string]string{"FERTILIZE_PKGS": "./env/..."},
+			flags: map[string]string{"pkgs": "./flag/...", "tmpl": "flag.tmpl"},
+			pkgs:  []string{"./flag/..."},
+			tmpl:  "flag.tmpl",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeConfig(t, config)
+			// the directory may be reached through a symlink
+			wd, err := os.Getwd()
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("FERTILIZE_CONFIG", filepath.Join(dir, "fertilize.yaml"))
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			for k, v := range tt.flags {
+				setFlag(t, k, v)
+			}
+			cfg, err := loadConfig(rootCmd.PersistentFlags())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.File != filepath.Join(dir, "fertilize.yaml") || cfg.Dir != dir {
+				t.Errorf("File, Dir = %s, %s, want the config file and its directory", cfg.File, cfg.Dir)
+			}
+			if !reflect.DeepEqual(cfg.Packages, tt.pkgs) {
+				t.Errorf("Packages = %q, want %q", cfg.Packages, tt.pkgs)
+			}
+			want := []Output{{Template: "handlers.tmpl", Out: "handlers.go"}}
+			if tt.tmpl != "" {
+				want = []Output{{Template: filepath.Join(wd, tt.tmpl)}}
+			}
+			if !reflect.DeepEqual(cfg.Outputs, want) {
+				t.Errorf("Outputs = %+v, want %+v", cfg.Outputs, want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigShapes(t *testing.T) {
+	const config = `
+shapes: ["(_, server.GenericRequest) _"]
+`
+	tests := []struct {
+		name  string
+		env   string
+		flags []string
+		want  []string
+	}{
+		{
+			name: "config",
+			want: []string{"(_, server.GenericRequest) _"},
+		},
+		{
+			name: "FERTILIZE_SHAPES",
+			env:  "(_, int) _; (_) error",
+			want: []string{"(_, int) _", "(_) error"},
+		},
+		{
+			name:  "flag",
+			env:   "(_, int) _",
+			flags: []string{"(_, string) _", "() error"},
+			want:  []string{"(_, string) _", "() error"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeConfig(t, config)
+			t.Setenv("FERTILIZE_CONFIG", filepath.Join(dir, "fertilize.yaml"))
+			if tt.env != "" {
+				t.Setenv("FERTILIZE_SHAPES", tt.env)
+			}
+			for _, shape := range tt.flags {
+				setFlag(t, "shapes", shape)
+			}
+			cfg, err := loadConfig(rootCmd.PersistentFlags())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cfg.Filter.Shapes, tt.want) {
+				t.Errorf("Shapes = %q, want %q", cfg.Filter.Shapes, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigOutputs(t *testing.T) {
+	const config = `
+outputs:
+  - tmpl: handlers.tmpl
+    out: handlers.go
+`
+	tests := []struct {
+		name string
+		// sub is the working directory, below the config's
+		sub  string
+		env  map[string]string
+		want []Output
+	}{
+		{
+			name: "config",
+			want: []Output{{Template: "handlers.tmpl", Out: "handlers.go"}},
+		},
+		{
+			name: "FERTILIZE_TMPL",
+			env:  map[string]string{"FERTILIZE_TMPL": "other.tmpl"},
+			want: []Output{{Template: "{dir}/other.tmpl"}},
+		},
+		{
+			name: "FERTILIZE_FORMAT",
+			env:  map[string]string{"FERTILIZE_FORMAT": "json", "FERTILIZE_OUT": "api.json"},
+			want: []Output{{Format: "json", Out: "{dir}/api.json"}},
+		},
+		{
+			name: "FERTILIZE_PLUGIN path",
+			sub:  "services",
+			env:  map[string]string{"FERTILIZE_PLUGIN": "./bin/fertilize-gen-foo", "FERTILIZE_OUT": "gen"},
+			want: []Output{{Plugin: "{dir}/bin/fertilize-gen-foo", Out: "{dir}/gen"}},
+		},
+		{
+			name: "FERTILIZE_PLUGIN name",
+			env:  map[string]string{"FERTILIZE_PLUGIN": "foo"},
+			want: []Output{{Plugin: "foo"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeConfig(t, config)
+			if tt.sub != "" {
+				if err := os.Mkdir(tt.sub, 0755); err != nil {
+					t.Fatal(err)
+				}
+				chdir(t, tt.sub)
+			}
+			// the directory may be reached through a symlink
+			wd, err := os.Getwd()
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("FERTILIZE_CONFIG", filepath.Join(dir, "fertilize.yaml"))
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			cfg, err := loadConfig(rootCmd.PersistentFlags())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range tt.want {
+				o := &tt.want[i]
+				if o.Template == "" && o.Format == "" && o.Plugin == "" {
+					o.Template = "builtin:seed-handlers"
+				}
+				o.Template = expandDir(o.Template, wd)
+				o.Plugin = expandDir(o.Plugin, wd)
+				o.Out = expandDir(o.Out, wd)
+			}
+			if !reflect.DeepEqual(cfg.Outputs, tt.want) {
+				t.Errorf("Outputs = %+v, want %+v", cfg.Outputs, tt.want)
+			}
+		})
+	}
+}
+
+// expandDir replaces a leading {dir} in s with dir.
+func expandDir(s, dir string) string {
+	if rest, ok := strings.CutPrefix(s, "{dir}/"); ok {
+		return filepath.Join(dir, rest)
+	}
+	return s
+}
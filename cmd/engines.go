@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gitamped/fertilize/render"
+	"github.com/gitamped/fertilize/templates"
+)
+
+// Engine is an external template engine, a command that reads a
+// render.Input on stdin and writes the rendered template to stdout.
+type Engine struct {
+	// Command is the command and its arguments. The template path is
+	// appended. It runs in the config directory.
+	Command []string `mapstructure:"command"`
+	// Extensions are the template file extensions, such as ".j2",
+	// rendered with the engine unless an output names another.
+	Extensions []string `mapstructure:"extensions"`
+}
+
+// htmlExtensions are the template file extensions rendered with
+// html/template unless an output names another engine.
+var htmlExtensions = []string{".html", ".htm", ".gohtml"}
+
+// engine gets the name of the engine that renders the template of o: the
+// one the output names, or else the one for the template's extension.
+func (c *Config) engine(o Output) string {
+	if o.Engine != "" {
+		return strings.ToLower(o.Engine)
+	}
+	ext := strings.ToLower(filepath.Ext(o.Template))
+	names := make([]string, 0, len(c.Engines))
+	for name := range c.Engines {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, e := range c.Engines[name].Extensions {
+			if strings.ToLower(e) == ext {
+				return name
+			}
+		}
+	}
+	for _, e := range htmlExtensions {
+		if e == ext {
+			return render.HTMLEngine
+		}
+	}
+	return render.TextEngine
+}
+
+// readTemplate reads the template of o, from disk or built in.
+func readTemplate(cfg *Config, o Output) ([]byte, error) {
+	if name, ok := templates.Name(o.Template); ok {
+		return templates.Read(name)
+	}
+	return os.ReadFile(cfg.path(o.Template))
+}
+
+// newRenderer makes the Renderer for the template of o.
+func newRenderer(cfg *Config, o Output) (render.Renderer, error) {
+	path := cfg.path(o.Template)
+	name := cfg.engine(o)
+	switch name {
+	case render.TextEngine, render.HTMLEngine:
+		t, err := readTemplate(cfg, o)
+		if err != nil {
+			return nil, fmt.Errorf("reading template: %w", err)
+		}
+		parse := render.Text
+		if name == render.HTMLEngine {
+			parse = render.HTML
+		}
+		r, err := parse(o.Template, string(t), templateFuncs(cfg))
+		if err != nil {
+			return nil, fmt.Errorf("parsing template %s: %w", o.Template, err)
+		}
+		return r, nil
+	}
+	e, ok := cfg.Engines[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown engine %q for %s (want text, html or one from the engines section of the config)", name, o.Template)
+	}
+	if len(e.Command) == 0 {
+		return nil, fmt.Errorf("engine %q has no command", name)
+	}
+	if _, ok := templates.Name(o.Template); ok {
+		return nil, fmt.Errorf("engine %q can't render built-in template %s", name, o.Template)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("reading template: %w", err)
+	}
+	return &render.Command{
+		Args:     e.Command,
+		Dir:      cfg.Dir,
+		Template: path,
+		Options:  cfg.Options,
+	}, nil
+}
@@ -0,0 +1,209 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gitamped/fertilize/gen/collection"
+	"github.com/gitamped/fertilize/gen/docs"
+	"github.com/gitamped/fertilize/gen/fake"
+	"github.com/gitamped/fertilize/gen/goclient"
+	"github.com/gitamped/fertilize/gen/graphql"
+	"github.com/gitamped/fertilize/gen/jsonschema"
+	"github.com/gitamped/fertilize/gen/openapi"
+	"github.com/gitamped/fertilize/gen/proto"
+	"github.com/gitamped/fertilize/gen/seed"
+	"github.com/gitamped/fertilize/gen/typescript"
+	"github.com/gitamped/fertilize/parser"
+	"github.com/mitchellh/mapstructure"
+)
+
+// generator renders the packages of a Document into the file at out
+// using the options from the output. Some generators also keep state in
+// other files, which they return too.
+type generator func(doc *parser.Document, options map[string]interface{}, out string) ([]file, error)
+
+// formats are the built-in generators, selected with --format or the
+// format key of an output.
+var formats = map[string]generator{
+	"fake":       format(ofPackages(fake.Generate)),
+	"goclient":   format(ofPackages(goclient.Generate)),
+	"graphql":    format(ofPackages(graphql.Generate)),
+	"html":       format(ofPackages(docs.HTML)),
+	"http":       format(ofPackages(collection.HTTPFile)),
+	"json":       format(marshalDocument),
+	"jsonschema": format(ofPackages(jsonschema.Generate)),
+	"markdown":   format(ofPackages(docs.Markdown)),
+	"openapi":    generateOpenAPI,
+	"postman":    format(ofPackages(collection.Postman)),
+	"proto":      generateProto,
+	"seed":       format(ofPackages(seed.Generate)),
+	"typescript": format(ofPackages(typescript.Generate)),
+}
+
+// format makes a generator writing the single file generate makes, with
+// the options of the output decoded into its options struct.
+func format[O any](generate func(*parser.Document, O) ([]byte, error)) generator {
+	return func(doc *parser.Document, options map[string]interface{}, out string) ([]file, error) {
+		var opts O
+		if err := decodeOptions(options, &opts); err != nil {
+			return nil, err
+		}
+		b, err := generate(doc, opts)
+		if err != nil {
+			return nil, err
+		}
+		return []file{{Path: out, Content: b}}, nil
+	}
+}
+
+// ofPackages adapts a gen function, which only needs the packages, for
+// format.
+func ofPackages[O any](generate func([]*parser.Definition, O) ([]byte, error)) func(*parser.Document, O) ([]byte, error) {
+	return func(doc *parser.Document, opts O) ([]byte, error) {
+		return generate(doc.Packages, opts)
+	}
+}
+
+// generateOpenAPI writes YAML when out ends in .yaml or .yml, unless the
+// yaml option says otherwise.
+func generateOpenAPI(doc *parser.Document, options map[string]interface{}, out string) ([]file, error) {
+	if _, ok := options["yaml"]; !ok {
+		withYAML := map[string]interface{}{}
+		for k, v := range options {
+			withYAML[k] = v
+		}
+		ext := filepath.Ext(out)
+		withYAML["yaml"] = ext == ".yaml" || ext == ".yml"
+		options = withYAML
+	}
+	return format(ofPackages(openapi.Generate))(doc, options, out)
+}
+
+// generateProto generates a .proto file, keeping field numbers in a lock
+// file next to it. Without an out path, as when printing to standard
+// output, the lockFile option must say where the lock file is.
+func generateProto(doc *parser.Document, options map[string]interface{}, out string) ([]file, error) {
+	var opts proto.Options
+	if err := decodeOptions(options, &opts); err != nil {
+		return nil, err
+	}
+	lockPath := opts.LockFile
+	switch {
+	case lockPath == "" && out == "":
+		return nil, errors.New("proto needs an out path or a lockFile option to keep field numbers in")
+	case lockPath == "":
+		lockPath = out + ".lock"
+	case !filepath.IsAbs(lockPath) && out != "":
+		lockPath = filepath.Join(filepath.Dir(out), lockPath)
+	}
+	data, err := os.ReadFile(lockPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	lock, err := proto.ParseLock(data)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", lockPath, err)
+	}
+	b, err := proto.Generate(doc.Packages, opts, lock)
+	if err != nil {
+		return nil, err
+	}
+	lockData, err := lock.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	return []file{
+		{Path: out, Content: b},
+		{Path: lockPath, Content: lockData},
+	}, nil
+}
+
+// jsonOptions configure the json format.
+type jsonOptions struct {
+	// Generated includes the time the packages were parsed, which
+	// otherwise is left out so the output only changes with them.
+	Generated bool `mapstructure:"generated"`
+}
+
+// marshalDocument encodes doc, the form fertilize diff reads.
+func marshalDocument(doc *parser.Document, opts jsonOptions) ([]byte, error) {
+	if !opts.Generated {
+		d := *doc
+		d.Generated = nil
+		doc = &d
+	}
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// formatNames lists the built-in formats.
+func formatNames() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// renderFormat renders o with a built-in generator. All packages go in
+// one file unless the output path is templated, in which case each
+// package gets its own, still finding the objects of the others.
+func renderFormat(cfg *Config, o Output, doc *parser.Document) ([]file, error) {
+	generate, ok := formats[o.Format]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q (want one of %s)", o.Format, strings.Join(formatNames(), ", "))
+	}
+	if !strings.Contains(o.Out, "{{") {
+		files, err := generate(doc, o.Options, cfg.outPath(o.Out))
+		if err != nil {
+			return nil, fmt.Errorf("generating %s: %w", o.Format, err)
+		}
+		return files, nil
+	}
+	outTmpl, err := newTemplate(cfg, doc, "out").Parse(o.Out)
+	if err != nil {
+		return nil, fmt.Errorf("parsing output path %q: %w", o.Out, err)
+	}
+	files := make([]file, 0, len(doc.Packages))
+	for _, d := range doc.Packages {
+		var out strings.Builder
+		if err := outTmpl.Execute(&out, d); err != nil {
+			return nil, fmt.Errorf("executing output path %q: %w", o.Out, err)
+		}
+		pkgDoc := *doc
+		pkgDoc.Packages = doc.Alone(d)
+		generated, err := generate(&pkgDoc, o.Options, cfg.outPath(out.String()))
+		if err != nil {
+			return nil, fmt.Errorf("generating %s for %s: %w", o.Format, d.PackageName, err)
+		}
+		files = append(files, generated...)
+	}
+	return files, nil
+}
+
+// decodeOptions decodes the options of an output into the options
+// struct of a generator.
+func decodeOptions(options map[string]interface{}, opts interface{}) error {
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		Result:           opts,
+		WeaklyTypedInput: true,
+		ErrorUnused:      true,
+	})
+	if err != nil {
+		return err
+	}
+	if err := dec.Decode(options); err != nil {
+		return fmt.Errorf("options: %w", err)
+	}
+	return nil
+}
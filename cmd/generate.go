@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var watch bool
+
+var generateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Renders the configured templates.",
+	Long: `Renders the configured templates against the described packages.
+
+This is what running fertilize without a command does.`,
+	RunE: runGenerate,
+}
+
+func init() {
+	generateCmd.Flags().BoolVar(&watch, "watch", false, "regenerate when Go sources or templates change")
+	rootCmd.AddCommand(generateCmd)
+}
+
+// runGenerate loads the config and renders the outputs once, or keeps
+// doing so as files change with --watch.
+func runGenerate(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig(cmd.Flags())
+	if err != nil {
+		return err
+	}
+	// --diff implies --dry-run
+	if dryRun || showDiff {
+		return check(cfg, os.Stdout, showDiff)
+	}
+	if watch {
+		return runWatch(cfg)
+	}
+	return run(cfg)
+}
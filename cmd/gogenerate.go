@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"go/ast"
+	goparser "go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// goGenerate describes the environment go generate runs commands in.
+// See go help generate.
+type goGenerate struct {
+	// File is the base name of the file containing the directive.
+	File string
+	// Package is the name of the package containing the file.
+	Package string
+	// Line is the line number of the directive in the file.
+	Line int
+	// Dir is the directory of the package; go generate runs
+	// commands from there.
+	Dir string
+}
+
+// detectGoGenerate returns the go generate environment, or nil when
+// fertilize was not started by go generate.
+func detectGoGenerate() (*goGenerate, error) {
+	file, pkg := os.Getenv("GOFILE"), os.Getenv("GOPACKAGE")
+	if file == "" || pkg == "" {
+		return nil, nil
+	}
+	g := &goGenerate{
+		File:    file,
+		Package: pkg,
+	}
+	if line := os.Getenv("GOLINE"); line != "" {
+		var err error
+		g.Line, err = strconv.Atoi(line)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GOLINE %q: %w", line, err)
+		}
+	}
+	var err error
+	g.Dir, err = os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// nextInterface finds the interface declared after the go:generate
+// directive.
+func (g *goGenerate) nextInterface() (string, error) {
+	fset := token.NewFileSet()
+	f, err := goparser.ParseFile(fset, filepath.Join(g.Dir, g.File), nil, goparser.SkipObjectResolution)
+	if err != nil {
+		return "", err
+	}
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		// the directive may be inside a type ( ... ) group, so
+		// the specs are checked rather than the declaration
+		for _, spec := range gen.Specs {
+			spec, ok := spec.(*ast.TypeSpec)
+			if !ok || fset.Position(spec.Pos()).Line <= g.Line {
+				continue
+			}
+			// the first type after the directive must be the
+			// interface
+			if _, ok := spec.Type.(*ast.InterfaceType); !ok {
+				return "", fmt.Errorf("%s:%d: %s is not an interface", g.File, fset.Position(spec.Pos()).Line, spec.Name.Name)
+			}
+			return spec.Name.Name, nil
+		}
+	}
+	return "", fmt.Errorf("%s:%d: no interface declared after the go:generate directive", g.File, g.Line)
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNextInterface(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+		// err is part of the error expected instead.
+		err string
+	}{
+		{
+			name: "declaration",
+			src: `package p
+
+type Before interface{}
+
+//go:generate fertilize --scope interface
+type Service interface{}
+`,
+			want: "Service",
+		},
+		{
+			name: "group",
+			src: `package p
+
+type (
+	Before interface{}
+
+	//go:generate fertilize --scope interface
+	Service interface{}
+
+	After interface{}
+)
+`,
+			want: "Service",
+		},
+		{
+			name: "empty group",
+			src: `package p
+
+//go:generate fertilize --scope interface
+type ()
+
+type Service interface{}
+`,
+			want: "Service",
+		},
+		{
+			name: "not an interface",
+			src: `package p
+
+//go:generate fertilize --scope interface
+type Request struct{}
+`,
+			err: "Request is not an interface",
+		},
+		{
+			name: "nothing after",
+			src: `package p
+
+type Service interface{}
+
+//go:generate fertilize --scope interface
+type ()
+`,
+			err: "no interface declared after",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "p.go"), []byte(tt.src), 0644); err != nil {
+				t.Fatal(err)
+			}
+			line := 1 + strings.Count(tt.src[:strings.Index(tt.src, "//go:generate")], "\n")
+			g := &goGenerate{File: "p.go", Package: "p", Line: line, Dir: dir}
+			got, err := g.nextInterface()
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("nextInterface() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
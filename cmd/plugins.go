@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/gitamped/fertilize/parser"
+	"github.com/gitamped/fertilize/plugin"
+)
+
+// renderPlugin runs the plugin of o, which lists the files to write in
+// the output directory o.Out.
+func renderPlugin(cfg *Config, o Output, doc *parser.Document) ([]file, error) {
+	path, err := plugin.Find(cfg.Dir, o.Plugin)
+	if err != nil {
+		return nil, fmt.Errorf("finding plugin %s: %w", o.Plugin, err)
+	}
+	dir := o.Out
+	if dir == "" {
+		dir = "."
+	}
+	dir = cfg.outPath(dir)
+	parameters := o.Options
+	if parameters == nil {
+		parameters = map[string]interface{}{}
+	}
+	res, err := plugin.Exec(path, &plugin.Request{
+		Document:   doc,
+		Parameters: parameters,
+		OutputDir:  dir,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("running plugin: %w", err)
+	}
+	for _, d := range res.Diagnostics {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", o.Plugin, d)
+	}
+	files := make([]file, 0, len(res.Files))
+	for _, f := range res.Files {
+		files = append(files, file{
+			Path:    filepath.Join(dir, filepath.FromSlash(f.Name)),
+			Content: []byte(f.Content),
+		})
+	}
+	return files, nil
+}
@@ -1,25 +1,37 @@
 package cmd
 
 import (
-	"encoding/json"
+	"bytes"
 	"fmt"
-	"log"
 	"os"
-	"path/filepath"
 	"strings"
 	"text/template"
 
+	"github.com/gitamped/fertilize/output"
 	"github.com/gitamped/fertilize/parser"
+	"github.com/gitamped/fertilize/render"
+	"github.com/gitamped/fertilize/templates"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var (
+	configFile string
 	outfile    string
 	pkgs       string
 	tmplPath   string
 	v          bool
 	ignoreList string
+	include    string
+	exclude    string
+	shapes     []string
+	tags       string
+	directive  bool
+	reachable  bool
+	scope      string
+	formatName string
+	engineName string
+	pluginName string
 )
 
 var rootCmd = &cobra.Command{
@@ -28,38 +40,14 @@ var rootCmd = &cobra.Command{
 	Long: `Fertilize describes Go packages in a generic way.
 
 Use the output json to generate boilerplate code and documentation with
-a template engine of your choice.`,
-	Run: func(cmd *cobra.Command, args []string) {
-
-		patterns := strings.Split(viper.GetString("pkgs"), ",")
-		p := parser.New(patterns...)
-		p.ExcludeInterfaces = strings.Split(viper.GetString("ignore"), ",")
-		p.Verbose = viper.GetBool("verbose")
-		def, err := p.Parse()
-		if err != nil {
-			panic("err parsing")
-		}
-
-		b, err := json.Marshal(def)
-		t, _ := os.ReadFile(tmplPath)
-		var data map[string]parser.Definition
-		json.Unmarshal(b, &data)
+a template engine of your choice.
 
-		tmpl, _ := template.New("test").Parse(string(t))
-
-		for _, v := range data {
-			os.Truncate(filepath.Join(outfile), 0)
-			f, err := os.OpenFile(filepath.Join(outfile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-			if err != nil {
-				log.Fatal(err)
-			}
-
-			tmpl.Execute(f, v)
-			if err != nil {
-				log.Fatal(err)
-			}
-		}
-	},
+Settings are read from a fertilize.yaml or fertilize.toml file in the
+project, FERTILIZE_* environment variables and flags, in increasing
+order of precedence.`,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          runGenerate,
 }
 
 func Execute() {
@@ -70,15 +58,221 @@ func Execute() {
 }
 
 func init() {
+	cobra.OnInitialize(initConfig)
+
+	rootCmd.PersistentFlags().StringVar(&configFile, "config", "", "config file (default: fertilize.yaml or fertilize.toml in the project)")
 	rootCmd.PersistentFlags().StringVar(&outfile, "out", "", "output file (default: stdout)")
 	rootCmd.PersistentFlags().StringVar(&pkgs, "pkgs", "./...", "comma separated list of package patterns")
-	rootCmd.PersistentFlags().StringVar(&tmplPath, "tmpl", "handler.tmpl", "template filepath")
+	rootCmd.PersistentFlags().StringVar(&tmplPath, "tmpl", templates.Prefix+"seed-handlers", "template filepath, or builtin:<name> for a built-in template")
 	rootCmd.PersistentFlags().BoolVar(&v, "verbose", false, "verbose output (default: false)")
 	rootCmd.PersistentFlags().StringVar(&ignoreList, "ignore", "", "comma separated list of interfaces to ignore")
+	rootCmd.PersistentFlags().StringVar(&include, "include", "", "comma separated patterns of the interfaces to describe, like pkg.*Service (default: all)")
+	rootCmd.PersistentFlags().StringVar(&exclude, "exclude", "", "comma separated patterns of interfaces to leave out")
+	rootCmd.PersistentFlags().StringArrayVar(&shapes, "shapes", nil, "method signature services must have, like \"(_, server.GenericRequest) _\" (repeatable)")
+	rootCmd.PersistentFlags().StringVar(&tags, "tags", "", "comma separated tags; only describe services with a fertilize:tags directive naming one")
+	rootCmd.PersistentFlags().BoolVar(&directive, "directive", false, "only describe interfaces with a fertilize:service directive")
+	rootCmd.PersistentFlags().BoolVar(&reachable, "reachable", false, "only describe structs used by the services")
+	rootCmd.PersistentFlags().StringVar(&formatName, "format", "", "built-in generator to use instead of a template ("+strings.Join(formatNames(), ", ")+")")
+	rootCmd.PersistentFlags().StringVar(&pluginName, "plugin", "", "external generator to run instead of a template, such as fertilize-gen-foo; --out is the directory it writes to")
+	rootCmd.PersistentFlags().StringVar(&engineName, "engine", "", "template engine: text, html or one from the config (default: by template extension)")
+	rootCmd.PersistentFlags().StringVar(&scope, "scope", "package", "under go generate, describe the whole package or only the interface after the directive (package, interface)")
 
+	viper.BindPFlag("config", rootCmd.PersistentFlags().Lookup("config"))
 	viper.BindPFlag("out", rootCmd.PersistentFlags().Lookup("out"))
 	viper.BindPFlag("pkgs", rootCmd.PersistentFlags().Lookup("pkgs"))
 	viper.BindPFlag("tmpl", rootCmd.PersistentFlags().Lookup("tmpl"))
 	viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
 	viper.BindPFlag("ignore", rootCmd.PersistentFlags().Lookup("ignore"))
+	viper.BindPFlag("include", rootCmd.PersistentFlags().Lookup("include"))
+	viper.BindPFlag("exclude", rootCmd.PersistentFlags().Lookup("exclude"))
+	viper.BindPFlag("tags", rootCmd.PersistentFlags().Lookup("tags"))
+	viper.BindPFlag("directive", rootCmd.PersistentFlags().Lookup("directive"))
+	viper.BindPFlag("reachable", rootCmd.PersistentFlags().Lookup("reachable"))
+	viper.BindPFlag("format", rootCmd.PersistentFlags().Lookup("format"))
+	viper.BindPFlag("plugin", rootCmd.PersistentFlags().Lookup("plugin"))
+	viper.BindPFlag("engine", rootCmd.PersistentFlags().Lookup("engine"))
+	viper.BindPFlag("scope", rootCmd.PersistentFlags().Lookup("scope"))
+}
+
+// run parses the configured packages and renders every output.
+func run(cfg *Config) error {
+	doc, err := parse(cfg, cfg.Packages...)
+	if err != nil {
+		return err
+	}
+	res, err := renderAll(cfg, doc)
+	if err != nil {
+		return err
+	}
+	res.print(cfg)
+	return nil
+}
+
+// parse describes the packages matching patterns, printing any problems
+// loading them to stderr.
+func parse(cfg *Config, patterns ...string) (*parser.Document, error) {
+	return parseCached(cfg, nil, patterns...)
+}
+
+// parseCached is parse keeping the packages in cache, if it isn't nil,
+// so only those invalidated since are parsed again.
+func parseCached(cfg *Config, cache *parser.Cache, patterns ...string) (*parser.Document, error) {
+	p := parser.New(patterns...)
+	p.Cache = cache
+	p.Dir = cfg.Dir
+	p.ExcludeInterfaces = cfg.Ignore
+	p.Filter = cfg.Filter
+	p.Verbose = cfg.Verbose
+	doc, err := p.ParseDocument()
+	if err != nil {
+		return nil, fmt.Errorf("parsing packages: %w", err)
+	}
+	for _, diag := range doc.Diagnostics {
+		fmt.Fprintln(os.Stderr, "warning:", diag)
+	}
+	if cfg.Verbose {
+		for _, drop := range doc.Dropped {
+			fmt.Fprintln(os.Stderr, "dropped", drop)
+		}
+	}
+	if cfg.Interface != "" {
+		for _, d := range doc.Packages {
+			services := d.Services[:0]
+			for _, s := range d.Services {
+				if s.Name == cfg.Interface {
+					services = append(services, s)
+				}
+			}
+			d.Services = services
+		}
+	}
+	return doc, nil
+}
+
+// file is a rendered output. An empty Path means stdout.
+type file struct {
+	Path    string
+	Content []byte
+}
+
+// renderAll renders every output and writes the results.
+func renderAll(cfg *Config, doc *parser.Document) (*writeResult, error) {
+	files, err := renderFiles(cfg, doc)
+	if err != nil {
+		return nil, err
+	}
+	return writeFiles(files)
+}
+
+// renderFiles renders every output in memory.
+func renderFiles(cfg *Config, doc *parser.Document) ([]file, error) {
+	var files []file
+	for _, o := range cfg.Outputs {
+		rendered, err := renderOutput(cfg, o, doc)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, rendered...)
+	}
+	return files, nil
+}
+
+// renderOutput renders the template of o for every Definition, in
+// package path order.
+func renderOutput(cfg *Config, o Output, doc *parser.Document) ([]file, error) {
+	if o.Format != "" {
+		return renderFormat(cfg, o, doc)
+	}
+	if o.Plugin != "" {
+		return renderPlugin(cfg, o, doc)
+	}
+	r, err := newRenderer(cfg, o)
+	if err != nil {
+		return nil, err
+	}
+	outTmpl, err := newTemplate(cfg, doc, "out").Parse(o.Out)
+	if err != nil {
+		return nil, fmt.Errorf("parsing output path %q: %w", o.Out, err)
+	}
+
+	var files []file
+	written := make(map[string]string)
+	for _, d := range doc.Packages {
+		pkgPath := d.PackagePath
+		var out bytes.Buffer
+		if err := outTmpl.Execute(&out, d); err != nil {
+			return nil, fmt.Errorf("executing output path %q: %w", o.Out, err)
+		}
+		var path string
+		if out.Len() > 0 {
+			path = cfg.outPath(out.String())
+			if other, ok := written[path]; ok {
+				return nil, fmt.Errorf("%s and %s both write to %s (use a templated out path such as \"{{.PackageName}}/handlers.go\")", other, pkgPath, path)
+			}
+			written[path] = pkgPath
+		}
+		var buf bytes.Buffer
+		if err := r.Render(&buf, doc, d); err != nil {
+			return nil, fmt.Errorf("executing template %s for %s: %w", o.Template, pkgPath, err)
+		}
+		files = append(files, file{Path: path, Content: buf.Bytes()})
+	}
+	return files, nil
+}
+
+// writeResult lists the files touched by writeFiles.
+type writeResult struct {
+	// Written are the files whose content changed.
+	Written []string
+	// Unchanged are the files that already had the rendered content.
+	Unchanged []string
+}
+
+// print writes a summary of the result to stderr.
+func (r *writeResult) print(cfg *Config) {
+	if len(r.Written)+len(r.Unchanged) == 0 {
+		return
+	}
+	if cfg.Verbose {
+		for _, path := range r.Written {
+			fmt.Fprintln(os.Stderr, "wrote", path)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d written, %d unchanged\n", len(r.Written), len(r.Unchanged))
+}
+
+// writeFiles writes files to disk, and those without a path to stdout.
+// Files are replaced atomically and only when their content changes.
+func writeFiles(files []file) (*writeResult, error) {
+	res := &writeResult{}
+	for _, f := range files {
+		if f.Path == "" {
+			if _, err := os.Stdout.Write(f.Content); err != nil {
+				return res, err
+			}
+			continue
+		}
+		changed, err := output.WriteFile(f.Path, f.Content, 0644)
+		if err != nil {
+			return res, fmt.Errorf("writing %s: %w", f.Path, err)
+		}
+		if changed {
+			res.Written = append(res.Written, f.Path)
+		} else {
+			res.Unchanged = append(res.Unchanged, f.Path)
+		}
+	}
+	return res, nil
+}
+
+// templateFuncs are the functions available to all fertilize
+// templates, besides document.
+func templateFuncs(cfg *Config) map[string]interface{} {
+	return render.Funcs(cfg.Options)
+}
+
+// newTemplate makes a text/template template, such as an output path,
+// with the functions available to all fertilize templates.
+func newTemplate(cfg *Config, doc *parser.Document, name string) *template.Template {
+	return template.New(name).Funcs(templateFuncs(cfg)).Funcs(render.DocumentFuncs(doc))
 }
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gitamped/fertilize/parser"
+)
+
+func TestRenderAllTemplateError(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	good := write("good.tmpl", "package {{.PackageName}}\n")
+	// fails after writing some of the output
+	bad := write("bad.tmpl", "package {{.PackageName}}\n{{.NoSuchField}}\n")
+	first := write("first.go", "package old\n")
+	second := write("second.go", "package old\n")
+	cfg := &Config{
+		Dir: dir,
+		Outputs: []Output{
+			{Template: good, Out: first},
+			{Template: bad, Out: second},
+		},
+	}
+	doc := &parser.Document{Packages: []*parser.Definition{{PackageName: "api", PackagePath: "example.com/api"}}}
+	if _, err := renderAll(cfg, doc); err == nil || !strings.Contains(err.Error(), "NoSuchField") {
+		t.Fatalf("renderAll error = %v, want one about NoSuchField", err)
+	}
+	// nothing is written unless every output renders
+	for _, path := range []string{first, second} {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "package old\n" {
+			t.Errorf("%s = %q, want the old content", filepath.Base(path), b)
+		}
+	}
+}
+
+func TestRenderFormatPerPackage(t *testing.T) {
+	doc, err := parser.New("../examples/testdata/services", "../examples/testdata/services/pleasantries").ParseDocument()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{Dir: t.TempDir()}
+	files, err := renderFormat(cfg, Output{Format: "typescript", Out: "{{.PackageName}}.ts"}, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("rendered %d files, want one per package", len(files))
+	}
+	// pleasantries uses services.Page, which is still found
+	got := string(files[1].Content)
+	if filepath.Base(files[1].Path) != "pleasantries.ts" || !strings.Contains(got, "Page: Page;") || !strings.Contains(got, "export interface Page {") {
+		t.Errorf("%s:\n%s", files[1].Path, got)
+	}
+	if !strings.Contains(got, "GreeterService") {
+		t.Error("the services of pleasantries are missing")
+	}
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gitamped/fertilize/gen/jsonschema"
+	"github.com/gitamped/fertilize/output"
+	"github.com/gitamped/fertilize/parser"
+	"github.com/spf13/cobra"
+)
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Prints the JSON Schema of the json format.",
+	Long: `Prints the JSON Schema describing the document the json format writes,
+so tools in other languages can validate it, or writes it to --out.
+
+The document has a schemaVersion. Its minor version goes up when
+properties are added, which consumers must ignore if they don't know
+them; the major version goes up when properties are removed or renamed,
+or their types or meaning change.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		b, err := schema()
+		if err != nil {
+			return err
+		}
+		if outfile == "" {
+			_, err := os.Stdout.Write(b)
+			return err
+		}
+		_, err = output.WriteFile(outfile, b, 0644)
+		return err
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(schemaCmd)
+}
+
+// schema makes the JSON Schema of parser.Document.
+func schema() ([]byte, error) {
+	s := jsonschema.Reflect(parser.Document{})
+	s.Title = "fertilize " + parser.SchemaVersion
+	s.Description = "Go packages described by fertilize."
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// checkSchemaVersion returns an error if a document written with version
+// can't be read, because its major version differs from ours.
+func checkSchemaVersion(version string) error {
+	major, _, _ := strings.Cut(version, ".")
+	ours, _, _ := strings.Cut(parser.SchemaVersion, ".")
+	if major != ours {
+		return fmt.Errorf("schema version %q is not compatible with %s", version, parser.SchemaVersion)
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gitamped/fertilize/parser"
+)
+
+func TestSchemaFile(t *testing.T) {
+	want, err := schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("../schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(want) {
+		t.Error("schema.json is out of date; regenerate it with fertilize schema --out schema.json")
+	}
+}
+
+func TestSchemaValidatesDocument(t *testing.T) {
+	cfg := &Config{
+		Dir:    "../examples/testdata",
+		Ignore: []string{"Ignorer"},
+		Filter: parser.Filter{Reachable: true},
+	}
+	doc, err := parse(cfg, "./services/...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Generated == nil || len(doc.Dropped) == 0 {
+		t.Fatal("the document doesn't use every property the test is meant to check")
+	}
+	b, err := marshalDocument(doc, jsonOptions{Generated: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		t.Fatal(err)
+	}
+	b, err = schema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s map[string]interface{}
+	if err := json.Unmarshal(b, &s); err != nil {
+		t.Fatal(err)
+	}
+	for _, problem := range validate(s, s, v, "") {
+		t.Error(problem)
+	}
+}
+
+// validate checks v against s, a schema in root using the keywords
+// jsonschema.Reflect does, and returns the problems it finds.
+func validate(root, s map[string]interface{}, v interface{}, path string) []string {
+	if ref, ok := s["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/$defs/")
+		def, ok := root["$defs"].(map[string]interface{})[name].(map[string]interface{})
+		if !ok {
+			return []string{fmt.Sprintf("%s: no definition for %s", path, ref)}
+		}
+		return validate(root, def, v, path)
+	}
+	if oneOf, ok := s["oneOf"].([]interface{}); ok {
+		matched := 0
+		for _, option := range oneOf {
+			if len(validate(root, option.(map[string]interface{}), v, path)) == 0 {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return []string{fmt.Sprintf("%s: %d of the oneOf schemas match %v", path, matched, v)}
+		}
+		return nil
+	}
+	if types, ok := s["type"]; ok {
+		var allowed []string
+		switch types := types.(type) {
+		case string:
+			allowed = []string{types}
+		case []interface{}:
+			for _, typ := range types {
+				allowed = append(allowed, typ.(string))
+			}
+		}
+		if !hasJSONType(allowed, v) {
+			return []string{fmt.Sprintf("%s: %v is not %s", path, v, strings.Join(allowed, " or "))}
+		}
+	}
+	var problems []string
+	switch v := v.(type) {
+	case string:
+		if s["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339, v); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", path, err))
+			}
+		}
+	case []interface{}:
+		if items, ok := s["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				problems = append(problems, validate(root, items, item, fmt.Sprintf("%s/%d", path, i))...)
+			}
+		}
+	case map[string]interface{}:
+		properties, _ := s["properties"].(map[string]interface{})
+		required, _ := s["required"].([]interface{})
+		for _, name := range required {
+			if _, ok := v[name.(string)]; !ok {
+				problems = append(problems, fmt.Sprintf("%s: %s is required", path, name))
+			}
+		}
+		for name, value := range v {
+			if property, ok := properties[name].(map[string]interface{}); ok {
+				problems = append(problems, validate(root, property, value, path+"/"+name)...)
+			} else if additional, ok := s["additionalProperties"].(map[string]interface{}); ok {
+				problems = append(problems, validate(root, additional, value, path+"/"+name)...)
+			}
+		}
+	}
+	return problems
+}
+
+// hasJSONType reports whether v, decoded by encoding/json, is one of the
+// JSON Schema types.
+func hasJSONType(types []string, v interface{}) bool {
+	for _, typ := range types {
+		switch v := v.(type) {
+		case nil:
+			if typ == "null" {
+				return true
+			}
+		case bool:
+			if typ == "boolean" {
+				return true
+			}
+		case float64:
+			if typ == "number" || typ == "integer" && v == math.Trunc(v) {
+				return true
+			}
+		case string:
+			if typ == "string" {
+				return true
+			}
+		case []interface{}:
+			if typ == "array" {
+				return true
+			}
+		case map[string]interface{}:
+			if typ == "object" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"text/tabwriter"
+
+	"github.com/gitamped/fertilize/output"
+	"github.com/gitamped/fertilize/templates"
+	"github.com/spf13/cobra"
+)
+
+var ejectForce bool
+
+var templatesCmd = &cobra.Command{
+	Use:   "templates",
+	Short: "Lists and ejects the built-in templates.",
+	Long: `Built-in templates are used with --tmpl builtin:<name> or the tmpl key
+of an output. Eject one to customize it.`,
+}
+
+var templatesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists the built-in templates.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		list, err := templates.List()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		for _, t := range list {
+			fmt.Fprintf(w, "%s%s\t%s\n", templates.Prefix, t.Name, t.Description)
+		}
+		return w.Flush()
+	},
+}
+
+var templatesEjectCmd = &cobra.Command{
+	Use:   "eject <name>",
+	Short: "Copies a built-in template to a file to customize it.",
+	Long: `Copies a built-in template to <name>.tmpl, or to --out, so it can be
+customized and used with --tmpl instead of the built-in one.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+		if n, ok := templates.Name(name); ok {
+			name = n
+		}
+		b, err := templates.Read(name)
+		if err != nil {
+			return err
+		}
+		path := outfile
+		if path == "" {
+			path = name + ".tmpl"
+		}
+		if _, err := os.Stat(path); err == nil && !ejectForce {
+			return fmt.Errorf("%s already exists (use --force to replace it)", path)
+		} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if _, err := output.WriteFile(path, b, 0644); err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "wrote", path)
+		return nil
+	},
+}
+
+func init() {
+	templatesEjectCmd.Flags().BoolVar(&ejectForce, "force", false, "replace the file if it exists")
+	templatesCmd.AddCommand(templatesListCmd, templatesEjectCmd)
+	rootCmd.AddCommand(templatesCmd)
+}
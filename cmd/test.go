@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/gitamped/fertilize/fertilizetest"
+	"github.com/gitamped/fertilize/parser"
+	"github.com/spf13/cobra"
+)
+
+var (
+	testUpdate bool
+	testRun    string
+)
+
+// Test is a test of a template, rendering it for fixture packages and
+// comparing the results to golden files.
+type Test struct {
+	// Name names the test. Defaults to the template.
+	Name string `mapstructure:"name"`
+	// Template is the path of the template, or builtin:<name>.
+	Template string `mapstructure:"tmpl"`
+	// Packages are the patterns of the fixture packages.
+	Packages []string `mapstructure:"pkgs"`
+	// Ignore are the names of interfaces to ignore.
+	Ignore []string `mapstructure:"ignore"`
+	// Filter selects the interfaces and structs to describe, with
+	// the keys include, exclude, shapes, tags, directive and
+	// reachable.
+	Filter parser.Filter `mapstructure:",squash"`
+	// Golden is the path of the golden file, which may contain
+	// template actions like output paths.
+	Golden string `mapstructure:"golden"`
+	// TypeCheck type-checks the rendered Go against the fixture.
+	TypeCheck bool `mapstructure:"typecheck"`
+	// Options are added to the options of the config.
+	Options map[string]interface{} `mapstructure:"options"`
+}
+
+var testCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Tests templates against golden files.",
+	Long: `Renders the templates of the tests in the config for their fixture
+packages and compares the results to golden files, printing a diff for
+each that differs. Tests can also type-check the rendered Go against the
+fixture package.
+
+With --update the golden files are written instead.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig(cmd.Flags())
+		if err != nil {
+			return err
+		}
+		return runTests(cfg)
+	},
+}
+
+func init() {
+	testCmd.Flags().BoolVar(&testUpdate, "update", false, "write the golden files")
+	testCmd.Flags().StringVar(&testRun, "run", "", "only run tests whose name matches this regular expression")
+	rootCmd.AddCommand(testCmd)
+}
+
+// runTests runs the tests in the config, reporting each to stdout.
+func runTests(cfg *Config) error {
+	if len(cfg.Tests) == 0 {
+		return errors.New("no tests in the config")
+	}
+	run, err := regexp.Compile(testRun)
+	if err != nil {
+		return fmt.Errorf("--run: %w", err)
+	}
+	var failed int
+	for _, t := range cfg.Tests {
+		c := fertilizetest.Case{
+			Name:              t.Name,
+			Template:          t.Template,
+			Dir:               cfg.Dir,
+			Packages:          t.Packages,
+			ExcludeInterfaces: t.Ignore,
+			Filter:            t.Filter,
+			Options:           make(map[string]interface{}),
+			Golden:            t.Golden,
+			TypeCheck:         t.TypeCheck,
+		}
+		if c.Name == "" {
+			c.Name = c.Template
+		}
+		if !run.MatchString(c.Name) {
+			continue
+		}
+		for k, v := range cfg.Options {
+			c.Options[k] = v
+		}
+		for k, v := range t.Options {
+			c.Options[k] = v
+		}
+		results, err := fertilizetest.Run(c, testUpdate)
+		if err != nil {
+			failed++
+			fmt.Printf("FAIL %s: %v\n", c.Name, err)
+			continue
+		}
+		ok := true
+		for _, res := range results {
+			switch {
+			case res.Updated:
+				fmt.Printf("updated %s\n", res.Golden)
+			case res.Want == nil:
+				fmt.Printf("%s: %s doesn't exist (run with --update to write it)\n", res.Package, res.Golden)
+			case !bytes.Equal(res.Got, res.Want):
+				fmt.Print(res.Diff())
+			}
+			if res.TypeErr != nil {
+				fmt.Printf("%s: type-checking: %v\n", res.Package, res.TypeErr)
+			}
+			ok = ok && res.OK()
+		}
+		if !ok {
+			failed++
+			fmt.Printf("FAIL %s\n", c.Name)
+			continue
+		}
+		fmt.Printf("ok   %s\n", c.Name)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d tests failed", failed)
+	}
+	return nil
+}
@@ -0,0 +1,302 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"go/build"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gitamped/fertilize/parser"
+	"github.com/gitamped/fertilize/templates"
+	"golang.org/x/tools/go/packages"
+)
+
+// debounce is how long to wait for further changes before regenerating,
+// so saving several files at once only triggers one run.
+const debounce = 200 * time.Millisecond
+
+// watcher regenerates outputs as Go sources and templates change.
+type watcher struct {
+	cfg *Config
+	fs  *fsnotify.Watcher
+
+	// doc is the last successfully parsed description of the packages,
+	// and cache what was found in each of them.
+	doc   *parser.Document
+	cache *parser.Cache
+	// dirs maps package directories to package paths, and roots are
+	// the directories of the patterns ending in /..., below which new
+	// packages may appear.
+	dirs  map[string]string
+	roots []string
+	// templates are the template files being watched.
+	templates map[string]struct{}
+	// written are files fertilize wrote itself, changes to
+	// which are ignored.
+	written map[string]struct{}
+
+	// stale is set when the sources changed since doc was parsed.
+	// The packages that changed, and those importing them, are
+	// invalidated in cache.
+	stale bool
+	// relist is set when packages may have been added or removed, so
+	// they are listed again before parsing.
+	relist bool
+}
+
+// runWatch renders the outputs and then watches the loaded packages and
+// templates, regenerating after each change until interrupted. Errors are
+// printed rather than returned so a broken file doesn't end the session.
+func runWatch(cfg *Config) error {
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer fsw.Close()
+	w := &watcher{
+		cfg:       cfg,
+		fs:        fsw,
+		templates: make(map[string]struct{}),
+		written:   make(map[string]struct{}),
+		cache:     &parser.Cache{},
+		stale:     true,
+	}
+	if _, err := w.watchPackages(); err != nil {
+		return err
+	}
+	for _, o := range cfg.Outputs {
+		if _, builtin := templates.Name(o.Template); o.Template == "" || builtin {
+			continue
+		}
+		path := cfg.path(o.Template)
+		w.templates[path] = struct{}{}
+		if err := fsw.Add(filepath.Dir(path)); err != nil {
+			return fmt.Errorf("watching %s: %w", path, err)
+		}
+	}
+	w.regenerate()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	fmt.Fprintf(os.Stderr, "watching %d packages for changes\n", len(w.dirs))
+	var timer <-chan time.Time
+	for {
+		select {
+		case <-interrupt:
+			return nil
+		case err, ok := <-fsw.Errors:
+			if !ok {
+				return nil
+			}
+			fmt.Fprintln(os.Stderr, "watch:", err)
+		case event, ok := <-fsw.Events:
+			if !ok {
+				return nil
+			}
+			if w.handle(event) {
+				timer = time.After(debounce)
+			}
+		case <-timer:
+			timer = nil
+			w.regenerate()
+		}
+	}
+}
+
+// watchPackages lists the packages matching the configured patterns
+// and watches their directories, along with every directory below the
+// roots of the patterns. It reports whether the packages changed since
+// it was last called.
+func (w *watcher) watchPackages() (bool, error) {
+	pkgs, err := packages.Load(&packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles,
+		Dir:  w.cfg.Dir,
+	}, w.cfg.Packages...)
+	if err != nil {
+		return false, fmt.Errorf("listing packages: %w", err)
+	}
+	dirs := make(map[string]string)
+	for _, pkg := range pkgs {
+		if len(pkg.GoFiles) == 0 {
+			continue
+		}
+		dir := filepath.Dir(pkg.GoFiles[0])
+		dirs[dir] = pkg.PkgPath
+		if err := w.fs.Add(dir); err != nil {
+			return false, fmt.Errorf("watching %s: %w", dir, err)
+		}
+	}
+	w.roots = patternRoots(w.cfg, pkgs)
+	for _, root := range w.roots {
+		if err := w.watchTree(root); err != nil {
+			return false, err
+		}
+	}
+	changed := len(dirs) != len(w.dirs)
+	for dir, pkgPath := range dirs {
+		if w.dirs[dir] != pkgPath {
+			changed = true
+		}
+	}
+	w.dirs = dirs
+	return changed, nil
+}
+
+// patternRoots gets the directories of the patterns of cfg ending in
+// /..., found from the packages matching them when the pattern is an
+// import path.
+func patternRoots(cfg *Config, pkgs []*packages.Package) []string {
+	var roots []string
+	for _, pattern := range cfg.Packages {
+		prefix, ok := strings.CutSuffix(pattern, "/...")
+		if !ok {
+			continue
+		}
+		if build.IsLocalImport(prefix) || filepath.IsAbs(prefix) {
+			roots = append(roots, absPath(cfg.Dir, prefix))
+			continue
+		}
+		for _, pkg := range pkgs {
+			rel, ok := strings.CutPrefix(pkg.PkgPath, prefix)
+			if !ok || len(pkg.GoFiles) == 0 || (rel != "" && rel[0] != '/') {
+				continue
+			}
+			dir := filepath.Dir(pkg.GoFiles[0])
+			roots = append(roots, strings.TrimSuffix(dir, filepath.FromSlash(rel)))
+			break
+		}
+	}
+	return roots
+}
+
+// watchTree watches dir and the directories below it, except those the
+// go command leaves out of patterns.
+func (w *watcher) watchTree(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			// removed since
+			return nil
+		}
+		if err != nil || !d.IsDir() {
+			return err
+		}
+		if path != dir && ignoredDir(d.Name()) {
+			return filepath.SkipDir
+		}
+		if err := w.fs.Add(path); err != nil {
+			return fmt.Errorf("watching %s: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// ignoredDir reports whether the go command leaves directories named
+// name out when matching patterns ending in /....
+func ignoredDir(name string) bool {
+	return name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
+
+// inRoots reports whether path is below one of the pattern roots.
+func (w *watcher) inRoots(path string) bool {
+	for _, root := range w.roots {
+		if strings.HasPrefix(path, root+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// handle records what needs regenerating after event and reports
+// whether anything does.
+func (w *watcher) handle(event fsnotify.Event) bool {
+	if event.Op == fsnotify.Chmod {
+		return false
+	}
+	if _, ok := w.written[event.Name]; ok {
+		return false
+	}
+	if _, ok := w.templates[event.Name]; ok {
+		if w.cfg.Verbose {
+			fmt.Fprintln(os.Stderr, "template changed:", event.Name)
+		}
+		return true
+	}
+	if event.Op.Has(fsnotify.Create) && w.inRoots(event.Name) {
+		if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+			if ignoredDir(info.Name()) {
+				return false
+			}
+			if err := w.watchTree(event.Name); err != nil {
+				fmt.Fprintln(os.Stderr, "watch:", err)
+			}
+			// a directory moved in may already hold packages
+			w.relist = true
+			w.stale = true
+			return true
+		}
+	}
+	if !strings.HasSuffix(event.Name, ".go") || strings.HasSuffix(event.Name, "_test.go") {
+		return false
+	}
+	pkgPath, ok := w.dirs[filepath.Dir(event.Name)]
+	switch {
+	case !ok && !w.inRoots(event.Name):
+		return false
+	case !ok, event.Op.Has(fsnotify.Remove), event.Op.Has(fsnotify.Rename):
+		// a package may have been added or removed
+		w.relist = true
+	}
+	if w.cfg.Verbose {
+		fmt.Fprintln(os.Stderr, "source changed:", event.Name)
+	}
+	if ok {
+		w.cache.Invalidate(pkgPath)
+	}
+	w.stale = true
+	return true
+}
+
+// regenerate parses the packages whose sources changed again and renders
+// every output.
+func (w *watcher) regenerate() {
+	start := time.Now()
+	if w.relist {
+		changed, err := w.watchPackages()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		if changed {
+			// the packages are parsed again in the order a clean
+			// run loads them
+			w.cache = &parser.Cache{}
+		}
+		w.relist = false
+	}
+	if w.stale {
+		doc, err := parseCached(w.cfg, w.cache, w.cfg.Packages...)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
+		}
+		w.doc = doc
+		w.stale = false
+	}
+	res, err := renderAll(w.cfg, w.doc)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	for _, path := range res.Written {
+		w.written[path] = struct{}{}
+	}
+	fmt.Fprintf(os.Stderr, "regenerated in %s: %d written, %d unchanged\n", time.Since(start).Round(time.Millisecond), len(res.Written), len(res.Unchanged))
+}
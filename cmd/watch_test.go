@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gitamped/fertilize/parser"
+	"golang.org/x/tools/go/packages"
+)
+
+const testdata = "github.com/gitamped/fertilize/examples/testdata"
+
+// copyFixture copies the services and pleasantries fixtures to new
+// packages in the module, the copy of pleasantries importing the copy of
+// services. It returns the directory and import path of the copy of
+// services, which pleasantries is below.
+func copyFixture(t *testing.T) (root, rootPath string) {
+	t.Helper()
+	tmp, err := os.MkdirTemp("../examples/testdata", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tmp) })
+	root, err = filepath.Abs(filepath.Join(tmp, "services"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootPath = testdata + "/" + filepath.Base(tmp) + "/services"
+	imports := strings.NewReplacer(`"`+testdata+`/services"`, `"`+rootPath+`"`)
+	for _, sub := range []string{"", "pleasantries"} {
+		src := filepath.Join("../examples/testdata/services", sub)
+		dst := filepath.Join(root, sub)
+		if err := os.MkdirAll(dst, 0755); err != nil {
+			t.Fatal(err)
+		}
+		entries, err := os.ReadDir(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			b, err := os.ReadFile(filepath.Join(src, e.Name()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dst, e.Name()), []byte(imports.Replace(string(b))), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return root, rootPath
+}
+
+// newWatcher makes a watcher of the packages of cfg, whose directories are
+// keyed by package path in dirs. Events are handed to it by the tests
+// rather than read from the file system.
+func newWatcher(t *testing.T, cfg *Config, dirs map[string]string) *watcher {
+	t.Helper()
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fsw.Close() })
+	w := &watcher{
+		cfg:       cfg,
+		fs:        fsw,
+		cache:     &parser.Cache{},
+		dirs:      make(map[string]string),
+		templates: make(map[string]struct{}),
+		written:   make(map[string]struct{}),
+		stale:     true,
+	}
+	for pkgPath, dir := range dirs {
+		w.dirs[dir] = pkgPath
+	}
+	return w
+}
+
+// edit makes the replacements in the file at path, and has w handle the
+// change.
+func edit(t *testing.T, w *watcher, path string, replacer *strings.Replacer) {
+	t.Helper()
+	src, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := replacer.Replace(string(src))
+	if edited == string(src) {
+		t.Fatalf("editing %s changed nothing", path)
+	}
+	if err := os.WriteFile(path, []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !w.handle(fsnotify.Event{Name: path, Op: fsnotify.Write}) {
+		t.Fatalf("the edit of %s wasn't handled", path)
+	}
+}
+
+// checkFullRun checks the output of w's config at out is what a full run
+// renders, and returns it.
+func checkFullRun(t *testing.T, w *watcher, out string) []byte {
+	t.Helper()
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := parse(w.cfg, w.cfg.Packages...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := renderFiles(w.cfg, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := files[0].Content; !bytes.Equal(got, want) {
+		t.Errorf("watch output differs from a full run:\n%s", string(got))
+	}
+	return got
+}
+
+func TestWatchMatchesFullRun(t *testing.T) {
+	root, rootPath := copyFixture(t)
+	out := filepath.Join(t.TempDir(), "api.json")
+	cfg := &Config{
+		Packages: []string{rootPath, rootPath + "/pleasantries"},
+		Ignore:   []string{"Ignorer"},
+		Filter:   parser.Filter{Reachable: true},
+		Outputs:  []Output{{Format: "json", Out: out}},
+	}
+	w := newWatcher(t, cfg, map[string]string{
+		rootPath:                   root,
+		rootPath + "/pleasantries": filepath.Join(root, "pleasantries"),
+	})
+	w.regenerate()
+	if got := checkFullRun(t, w, out); !bytes.Contains(got, []byte(`"name": "Page"`)) {
+		t.Fatal("Page isn't described before the edit")
+	}
+
+	// stop using services.Page, which then isn't reachable
+	edit(t, w, filepath.Join(root, "pleasantries", "greeter.go"), strings.NewReplacer(
+		"Page services.Page `tagtest:\"value,option1,option2\"`", "Cursor string",
+		"\t\""+rootPath+"\"\n", "",
+	))
+	w.regenerate()
+	if got := checkFullRun(t, w, out); bytes.Contains(got, []byte(`"name": "Page"`)) {
+		t.Error("Page is still described after the edit")
+	}
+}
+
+func TestWatchParsesImporters(t *testing.T) {
+	root, rootPath := copyFixture(t)
+	greeter := filepath.Join(root, "pleasantries", "greeter.go")
+	src, err := os.ReadFile(greeter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// pleasantries describes the underlying type of services.Cursor in
+	// its fields, so it is parsed again when services changes
+	src = bytes.Replace(src, []byte("\tPage services.Page"), []byte("\tCursor services.Cursor\n\tPage services.Page"), 1)
+	if err := os.WriteFile(greeter, src, 0644); err != nil {
+		t.Fatal(err)
+	}
+	paging := filepath.Join(root, "paging.go")
+	src, err = os.ReadFile(paging)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src = append(src, "\n// Cursor is where a page starts.\ntype Cursor int\n"...)
+	if err := os.WriteFile(paging, src, 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(t.TempDir(), "api.json")
+	cfg := &Config{
+		Packages: []string{rootPath, rootPath + "/pleasantries"},
+		Outputs:  []Output{{Format: "json", Out: out}},
+	}
+	w := newWatcher(t, cfg, map[string]string{
+		rootPath:                   root,
+		rootPath + "/pleasantries": filepath.Join(root, "pleasantries"),
+	})
+	w.regenerate()
+	checkFullRun(t, w, out)
+
+	edit(t, w, paging, strings.NewReplacer("type Cursor int", "type Cursor string"))
+	w.regenerate()
+	checkFullRun(t, w, out)
+}
+
+func TestWatchNewPackage(t *testing.T) {
+	root, _ := copyFixture(t)
+	out := filepath.Join(t.TempDir(), "api.json")
+	// the go command leaves testdata out of import path patterns, so
+	// the copy is matched from its directory
+	cfg := &Config{
+		Dir:      root,
+		Packages: []string{"./..."},
+		Outputs:  []Output{{Format: "json", Out: out}},
+	}
+	w := newWatcher(t, cfg, nil)
+	if _, err := w.watchPackages(); err != nil {
+		t.Fatal(err)
+	}
+	w.regenerate()
+	if got := checkFullRun(t, w, out); !bytes.Contains(got, []byte(`"name": "GreeterService"`)) {
+		t.Fatal("the packages aren't described before the change")
+	}
+
+	dir := filepath.Join(root, "pleasantries", "extra")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if !w.handle(fsnotify.Event{Name: dir, Op: fsnotify.Create}) {
+		t.Fatal("the new directory wasn't handled")
+	}
+	src := "package extra\n\ntype Extra interface {\n\tGet(GetRequest) GetResponse\n}\n\ntype GetRequest struct{}\n\ntype GetResponse struct{}\n"
+	path := filepath.Join(dir, "extra.go")
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !w.handle(fsnotify.Event{Name: path, Op: fsnotify.Create}) {
+		t.Fatal("the file of the new package wasn't handled")
+	}
+	w.regenerate()
+	if got := checkFullRun(t, w, out); !bytes.Contains(got, []byte(`"name": "Extra"`)) {
+		t.Error("the service of the new package isn't described")
+	}
+	if _, ok := w.dirs[dir]; !ok {
+		t.Error("the directory of the new package isn't watched")
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if !w.handle(fsnotify.Event{Name: path, Op: fsnotify.Remove}) {
+		t.Fatal("the removal of the new package wasn't handled")
+	}
+	w.regenerate()
+	if got := checkFullRun(t, w, out); bytes.Contains(got, []byte(`"name": "Extra"`)) {
+		t.Error("the service of the removed package is still described")
+	}
+}
+
+func TestPatternRoots(t *testing.T) {
+	dir := filepath.FromSlash("/src/mod")
+	pkgs := []*packages.Package{
+		{PkgPath: "example.com/mod/api/v1", GoFiles: []string{filepath.Join(dir, "api", "v1", "api.go")}},
+		{PkgPath: "example.com/mod/apiv2", GoFiles: []string{filepath.Join(dir, "apiv2", "api.go")}},
+	}
+	cfg := &Config{
+		Dir:      dir,
+		Packages: []string{"example.com/mod/api/...", "example.com/mod/apiv2", "./internal/...", "example.com/other/..."},
+	}
+	want := []string{filepath.Join(dir, "api"), filepath.Join(dir, "internal")}
+	if got := patternRoots(cfg, pkgs); !reflect.DeepEqual(got, want) {
+		t.Errorf("patternRoots = %q, want %q", got, want)
+	}
+}
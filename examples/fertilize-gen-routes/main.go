@@ -0,0 +1,40 @@
+// Command fertilize-gen-routes is an example plugin listing the routes of
+// the described services.
+//
+//	go install ./examples/fertilize-gen-routes
+//	fertilize --plugin routes --out docs
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gitamped/fertilize/plugin"
+)
+
+func main() {
+	plugin.Main(func(req *plugin.Request, res *plugin.Response) error {
+		title, _ := req.Parameters["title"].(string)
+		if title == "" {
+			title = "Routes"
+		}
+		var b strings.Builder
+		fmt.Fprintf(&b, "# %s\n", title)
+		for _, d := range req.Document.Packages {
+			if len(d.Services) == 0 {
+				continue
+			}
+			fmt.Fprintf(&b, "\n## %s\n\n", d.PackagePath)
+			for _, s := range d.Services {
+				for _, m := range s.Methods {
+					fmt.Fprintf(&b, "- POST /v1/%s.%s\n", s.Name, m.Name)
+				}
+			}
+		}
+		if b.Len() == len(title)+3 {
+			res.Warnf("no services found")
+		}
+		res.AddFile("routes.md", b.String())
+		return nil
+	})
+}
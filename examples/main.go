@@ -1,15 +1,15 @@
 package main
 
 import (
-	"encoding/json"
+	"bytes"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
 	"text/template"
 
+	"github.com/gitamped/fertilize/output"
 	"github.com/gitamped/fertilize/parser"
 )
 
@@ -19,28 +19,34 @@ func main() {
 	p := parser.New(patterns...)
 	p.ExcludeInterfaces = []string{"Welcomer", "Ignorer"}
 	p.Verbose = false
-	def, err := p.Parse()
+	doc, err := p.ParseDocument()
 	if err != nil {
 		panic(fmt.Sprintf("err parsing: %s", err))
 	}
-	b, err := json.Marshal(def)
-	t, _ := ioutil.ReadFile("templates/handlers.tmpl")
-	var data map[string]parser.Definition
-	json.Unmarshal(b, &data)
-
-	tmpl, _ := template.New("test").Parse(string(t))
+	t, err := os.ReadFile("templates/handlers.tmpl")
+	if err != nil {
+		log.Fatal(err)
+	}
+	tmpl, err := template.New("test").Parse(string(t))
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	for k, v := range data {
-		p := strings.Replace(k, "github.com/gitamped/fertilize/examples/", "", -1)
-		os.Truncate(filepath.Join(p, "handlers.go"), 0)
-		f, err := os.OpenFile(filepath.Join(p, "handlers.go"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-		if err != nil {
+	for _, d := range doc.Packages {
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, d); err != nil {
 			log.Fatal(err)
 		}
-
-		tmpl.Execute(f, v)
+		p := strings.Replace(d.PackagePath, "github.com/gitamped/fertilize/examples/", "", -1)
+		path := filepath.Join(p, "handlers.go")
+		changed, err := output.WriteFile(path, buf.Bytes(), 0644)
 		if err != nil {
 			log.Fatal(err)
 		}
+		if changed {
+			fmt.Println("wrote", path)
+		} else {
+			fmt.Println("unchanged", path)
+		}
 	}
 }
@@ -0,0 +1,3 @@
+// Package filters has interfaces and structs for testing the filters of
+// the parser.
+package filters
@@ -0,0 +1,95 @@
+package filters
+
+import (
+	"io"
+
+	"github.com/gitamped/seed/server"
+)
+
+// OrderService manages orders.
+//
+//fertilize:service
+//fertilize:tags public, admin
+type OrderService interface {
+	// Place places an order.
+	Place(PlaceRequest, server.GenericRequest) PlaceResponse
+}
+
+// PlaceRequest is the request object for OrderService.Place.
+type PlaceRequest struct {
+	Items []Item
+	// Notes are the notes on the items, keyed by SKU.
+	Notes map[string][]Note
+}
+
+// Note is only reached through the values of a map.
+type Note struct {
+	Text string
+}
+
+// PlaceResponse is the response object for OrderService.Place.
+type PlaceResponse struct {
+	OrderID string
+}
+
+// Item is an item of an order.
+type Item struct {
+	SKU      string
+	Quantity int
+	Price    *Price
+}
+
+// Price is reached through Item.
+type Price struct {
+	Amount   int
+	Currency string
+}
+
+// RefundService refunds orders.
+//
+//fertilize:tags admin
+type RefundService interface {
+	// Refund refunds an order.
+	Refund(RefundRequest, server.GenericRequest) RefundResponse
+}
+
+// RefundRequest is the request object for RefundService.Refund.
+type RefundRequest struct {
+	OrderID string
+	// Items are the items to refund, all of them if empty.
+	Items []Item
+}
+
+// RefundResponse is the response object for RefundService.Refund.
+type RefundResponse struct{}
+
+// Internal is left out by its directive.
+//
+//fertilize:ignore
+type Internal interface {
+	Sync(SyncRequest, server.GenericRequest) SyncResponse
+}
+
+// SyncRequest is only used by Internal.
+type SyncRequest struct{}
+
+// SyncResponse is only used by Internal.
+type SyncResponse struct{}
+
+// Source isn't a service, it wraps an io.Reader.
+type Source interface {
+	io.Reader
+	Name() string
+}
+
+// Config isn't used by any service.
+type Config struct {
+	Debug bool
+}
+
+// Secret is left out by its directive.
+//
+//fertilize:ignore
+type Secret struct {
+	Key string
+}
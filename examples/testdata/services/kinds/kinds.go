@@ -0,0 +1,30 @@
+// Package kinds has fields whose types are described by their structure,
+// like named types declared as basic types and maps keyed by them.
+package kinds
+
+import (
+	"time"
+
+	"github.com/gitamped/seed/server"
+)
+
+// StatusService reports the status of things.
+type StatusService interface {
+	// Check checks the status of a thing.
+	Check(CheckRequest, server.GenericRequest) CheckResponse
+}
+
+// CheckRequest is the request object for StatusService.Check.
+type CheckRequest struct {
+	Name string
+}
+
+// CheckResponse is the response object for StatusService.Check.
+type CheckResponse struct {
+	Status  Status
+	Timeout time.Duration
+	Counts  map[Status]int
+}
+
+// Status is encoded as the string it is declared as.
+type Status string
@@ -0,0 +1,48 @@
+// Package validation has fields with validate tags, on basic types and on
+// named types declared as them.
+package validation
+
+// SignupService signs people up.
+type SignupService interface {
+	// Signup signs somebody up.
+	Signup(SignupRequest) SignupResponse
+}
+
+// SignupRequest is the request object for SignupService.Signup.
+type SignupRequest struct {
+	Name   string         `validate:"required"`
+	Email  *string        `validate:"required,email"`
+	Code   string         `validate:"len=6"`
+	Age    int            `validate:"min=18,max=130"`
+	Score  float64        `validate:"gt=0,lt=1"`
+	Plan   string         `validate:"oneof=free pro"`
+	Tags   []string       `validate:"min=1,max=5,dive,required,max=20"`
+	Limits map[string]int `validate:"dive,min=1"`
+	Status Status         `validate:"required"`
+	Former *Status        `validate:"required"`
+	Ref    Ref            `validate:"len=8"`
+	Count  Count          `validate:"min=1,max=5"`
+	Level  Level          `validate:"oneof=1 2 3"`
+	Counts []Count        `validate:"len=2,dive,min=1,max=5"`
+	Levels Levels         `validate:"max=3,dive,oneof=1 2"`
+}
+
+// SignupResponse is the response object for SignupService.Signup.
+type SignupResponse struct {
+	Status Status
+}
+
+// Status is encoded as the string it is declared as.
+type Status string
+
+// Ref refers to an account.
+type Ref string
+
+// Count counts something.
+type Count int
+
+// Level is a level of service.
+type Level int
+
+// Levels are levels of service.
+type Levels []Level
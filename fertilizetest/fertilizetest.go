@@ -0,0 +1,265 @@
+// Package fertilizetest tests templates by rendering them for fixture
+// packages and comparing the results to golden files.
+//
+// In a test:
+//
+//	var update = flag.Bool("update", false, "update golden files")
+//
+//	func TestHandlers(t *testing.T) {
+//		fertilizetest.Test(t, fertilizetest.Case{
+//			Template:  "templates/handlers.tmpl",
+//			Packages:  []string{"./testdata/greeter"},
+//			Golden:    "testdata/{{.PackageName}}_handlers.go.golden",
+//			TypeCheck: true,
+//		}, *update)
+//	}
+//
+// Running go test -update writes the golden files.
+package fertilizetest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/gitamped/fertilize/output"
+	"github.com/gitamped/fertilize/parser"
+	"github.com/gitamped/fertilize/render"
+	"github.com/gitamped/fertilize/templates"
+)
+
+// Case is a template to render for fixture packages.
+type Case struct {
+	// Name names the case in reports. Defaults to the template.
+	Name string
+	// Template is the path of the template, or builtin:<name> for a
+	// built-in template. Templates ending in .html, .htm or .gohtml are
+	// html/template templates, others text/template.
+	Template string
+	// Renderer renders the packages instead of Template, as Generator
+	// does for the built-in generators.
+	Renderer render.Renderer
+	// Dir is the directory the packages are resolved in and relative
+	// paths are relative to. Empty means the current directory.
+	Dir string
+	// Packages are the patterns of the fixture packages.
+	Packages []string
+	// ExcludeInterfaces are the interfaces to leave out.
+	ExcludeInterfaces []string
+	// Filter selects the interfaces and structs to describe.
+	Filter parser.Filter
+	// Options are available to the template with the option function.
+	Options map[string]interface{}
+	// Golden is the path of the golden file. It is executed as a
+	// template against each Definition, like output paths, so
+	// "testdata/{{.PackageName}}.golden" gives each package its own.
+	Golden string
+	// TypeCheck type-checks the rendered Go along with the fixture
+	// package it was rendered for, or on its own if it declares
+	// another package.
+	TypeCheck bool
+}
+
+// Result is the outcome of a Case for a package.
+type Result struct {
+	// Package is the import path of the package.
+	Package string
+	// Golden is the path of the golden file.
+	Golden string
+	// Got is what the template rendered.
+	Got []byte
+	// Want is the content of the golden file, nil if there is none.
+	Want []byte
+	// TypeErr is the error type-checking Got, if any.
+	TypeErr error
+	// Updated is set when the golden file was written.
+	Updated bool
+}
+
+// OK reports whether the package passed: it rendered the golden file and
+// type-checked.
+func (r Result) OK() bool {
+	return r.TypeErr == nil && r.Want != nil && bytes.Equal(r.Got, r.Want)
+}
+
+// Diff gets a unified diff from the golden file to what was rendered.
+func (r Result) Diff() string {
+	return output.UnifiedDiff(r.Golden, r.Golden+" (rendered)", r.Want, r.Got)
+}
+
+// Run renders the template of c for each package. With update it writes
+// the golden files whose content differs instead of comparing them.
+func Run(c Case, update bool) ([]Result, error) {
+	if len(c.Packages) == 0 {
+		return nil, errors.New("no fixture packages")
+	}
+	r := c.Renderer
+	if r == nil {
+		var err error
+		if r, err = newRenderer(c); err != nil {
+			return nil, err
+		}
+	}
+	golden, err := template.New("golden").Funcs(render.Funcs(c.Options)).Parse(c.Golden)
+	if err != nil {
+		return nil, fmt.Errorf("parsing golden path %q: %w", c.Golden, err)
+	}
+	p := parser.New(c.Packages...)
+	p.Dir = c.Dir
+	p.ExcludeInterfaces = c.ExcludeInterfaces
+	p.Filter = c.Filter
+	doc, err := p.ParseDocument()
+	if err != nil {
+		return nil, fmt.Errorf("parsing fixtures: %w", err)
+	}
+	if len(doc.Diagnostics) > 0 {
+		return nil, fmt.Errorf("loading fixtures: %s", doc.Diagnostics[0])
+	}
+	var checker *typeChecker
+	if c.TypeCheck {
+		if checker, err = loadTypeChecker(c.Dir, c.Packages); err != nil {
+			return nil, err
+		}
+	}
+
+	results := make([]Result, 0, len(doc.Packages))
+	for _, d := range doc.Packages {
+		var path bytes.Buffer
+		if err := golden.Execute(&path, d); err != nil {
+			return nil, fmt.Errorf("executing golden path %q: %w", c.Golden, err)
+		}
+		res := Result{Package: d.PackagePath, Golden: path.String()}
+		if res.Golden == "" {
+			return nil, fmt.Errorf("no golden file for %s", d.PackagePath)
+		}
+		var got bytes.Buffer
+		if err := r.Render(&got, doc, d); err != nil {
+			return nil, fmt.Errorf("rendering %s for %s: %w", c.name(), d.PackagePath, err)
+		}
+		res.Got = got.Bytes()
+		if checker != nil {
+			res.TypeErr = checker.check(d.PackagePath, res.Got)
+		}
+		file := abs(c.Dir, res.Golden)
+		if update {
+			if res.Updated, err = output.WriteFile(file, res.Got, 0644); err != nil {
+				return nil, err
+			}
+			res.Want = res.Got
+		} else {
+			res.Want, err = os.ReadFile(file)
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// Test runs c as a subtest of t, reporting a diff for each package that
+// didn't render its golden file and any type errors. With update it
+// writes the golden files instead.
+func Test(t *testing.T, c Case, update bool) {
+	t.Helper()
+	t.Run(c.name(), func(t *testing.T) {
+		t.Helper()
+		results, err := Run(c, update)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, res := range results {
+			if res.TypeErr != nil {
+				t.Errorf("%s: type-checking: %v", res.Package, res.TypeErr)
+			}
+			switch {
+			case res.Want == nil:
+				t.Errorf("%s: %s doesn't exist (run with -update to write it)", res.Package, res.Golden)
+			case !bytes.Equal(res.Got, res.Want):
+				t.Errorf("%s: rendered template differs from %s (run with -update to accept it):\n%s", res.Package, res.Golden, res.Diff())
+			}
+		}
+	})
+}
+
+// Parse describes the fixture packages matching patterns, failing t if
+// they can't be loaded. It is for testing generators with Definitions
+// rather than golden files.
+func Parse(t testing.TB, patterns ...string) *parser.Document {
+	t.Helper()
+	doc, err := parser.New(patterns...).ParseDocument()
+	if err != nil {
+		t.Fatalf("parsing fixtures: %v", err)
+	}
+	if len(doc.Diagnostics) > 0 {
+		t.Fatalf("loading fixtures: %s", doc.Diagnostics[0])
+	}
+	return doc
+}
+
+// name is the name of c in reports.
+func (c Case) name() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.Template
+}
+
+// Generator renders each package on its own with a built-in generator,
+// such as openapi.Generate, with opts. The objects of the other packages
+// are still found, as they are by fertilize.
+func Generator[O any](generate func([]*parser.Definition, O) ([]byte, error), opts O) render.Renderer {
+	return generator(func(w io.Writer, doc *parser.Document, d *parser.Definition) error {
+		b, err := generate(doc.Alone(d), opts)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	})
+}
+
+type generator func(w io.Writer, doc *parser.Document, d *parser.Definition) error
+
+func (g generator) Render(w io.Writer, doc *parser.Document, d *parser.Definition) error {
+	return g(w, doc, d)
+}
+
+// newRenderer parses the template of c.
+func newRenderer(c Case) (render.Renderer, error) {
+	var src []byte
+	var err error
+	if name, ok := templates.Name(c.Template); ok {
+		src, err = templates.Read(name)
+	} else {
+		src, err = os.ReadFile(abs(c.Dir, c.Template))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading template: %w", err)
+	}
+	parse := render.Text
+	switch strings.ToLower(filepath.Ext(c.Template)) {
+	case ".html", ".htm", ".gohtml":
+		parse = render.HTML
+	}
+	r, err := parse(c.Template, string(src), render.Funcs(c.Options))
+	if err != nil {
+		return nil, fmt.Errorf("parsing template %s: %w", c.Template, err)
+	}
+	return r, nil
+}
+
+// abs resolves p against dir unless it is already absolute.
+func abs(dir, p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(dir, p)
+}
@@ -0,0 +1,182 @@
+package fertilizetest
+
+import (
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gitamped/fertilize/parser"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+const fixtures = "../examples/testdata"
+
+// golden is a case rendering testdata/services.tmpl for pleasantries.
+func golden(t *testing.T) Case {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Case{
+		Template:  filepath.Join(wd, "testdata/services.tmpl"),
+		Dir:       fixtures,
+		Packages:  []string{"./services/pleasantries"},
+		Golden:    filepath.Join(wd, "testdata/{{.PackageName}}.go.golden"),
+		TypeCheck: true,
+	}
+}
+
+func TestGolden(t *testing.T) {
+	Test(t, golden(t), *update)
+}
+
+func TestRunDiffers(t *testing.T) {
+	c := golden(t)
+	c.Golden = filepath.Join(t.TempDir(), "services.go.golden")
+	if err := os.WriteFile(c.Golden, []byte("package pleasantries\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	results, err := Run(c, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+	res := results[0]
+	if res.OK() {
+		t.Error("OK with a golden file that differs")
+	}
+	if res.TypeErr != nil {
+		t.Errorf("TypeErr = %v, want nil", res.TypeErr)
+	}
+	if diff := res.Diff(); !strings.Contains(diff, "+var serviceNames") {
+		t.Errorf("Diff doesn't add serviceNames:\n%s", diff)
+	}
+
+	// updating writes the golden file and passes
+	if results, err = Run(c, true); err != nil {
+		t.Fatal(err)
+	}
+	if !results[0].Updated || !results[0].OK() {
+		t.Errorf("after update Updated = %v, OK = %v, want both", results[0].Updated, results[0].OK())
+	}
+	if results, err = Run(c, false); err != nil {
+		t.Fatal(err)
+	}
+	if !results[0].OK() {
+		t.Errorf("updated golden file differs:\n%s", results[0].Diff())
+	}
+}
+
+func TestRunMissingGolden(t *testing.T) {
+	c := golden(t)
+	c.Golden = filepath.Join(t.TempDir(), "missing.golden")
+	results, err := Run(c, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Want != nil || results[0].OK() {
+		t.Errorf("Want = %q, OK = %v for a missing golden file", results[0].Want, results[0].OK())
+	}
+}
+
+func TestRunGenerator(t *testing.T) {
+	c := golden(t)
+	c.Name = "names"
+	c.Template = ""
+	c.Golden = filepath.Join(t.TempDir(), "names.golden")
+	type options struct{ sep string }
+	c.Renderer = Generator(func(defs []*parser.Definition, opts options) ([]byte, error) {
+		var names []string
+		for _, s := range defs[0].Services {
+			names = append(names, s.Name)
+		}
+		return []byte("package names\n\n// " + strings.Join(names, opts.sep) + "\n"), nil
+	}, options{sep: ", "})
+	results, err := Run(c, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := results[0]
+	if want := "GreeterService, Ignorer"; !strings.Contains(string(res.Got), want) {
+		t.Errorf("Got = %q, want it to contain %q", res.Got, want)
+	}
+	if !res.OK() {
+		t.Errorf("OK = false, TypeErr = %v", res.TypeErr)
+	}
+
+	c.Renderer = Generator(func([]*parser.Definition, options) ([]byte, error) {
+		return nil, errors.New("boom")
+	}, options{})
+	if _, err := Run(c, false); err == nil || !strings.Contains(err.Error(), "rendering names") {
+		t.Errorf("Run error = %v, want one rendering names", err)
+	}
+}
+
+func TestRunTypeCheck(t *testing.T) {
+	dir := t.TempDir()
+	c := golden(t)
+	c.Template = filepath.Join(dir, "broken.tmpl")
+	c.Golden = filepath.Join(dir, "broken.golden")
+	src := "package {{.PackageName}}\n\nvar _ GreeterService = NoSuchService{}\n"
+	if err := os.WriteFile(c.Template, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	results, err := Run(c, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := results[0]
+	if res.TypeErr == nil || !strings.Contains(res.TypeErr.Error(), "NoSuchService") {
+		t.Errorf("TypeErr = %v, want an error about NoSuchService", res.TypeErr)
+	}
+	if res.OK() {
+		t.Error("OK despite the type error")
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(c *Case)
+		// err is part of the error expected.
+		err string
+	}{
+		{
+			name: "no packages",
+			edit: func(c *Case) { c.Packages = nil },
+			err:  "no fixture packages",
+		},
+		{
+			name: "missing template",
+			edit: func(c *Case) { c.Template = "no/such.tmpl" },
+			err:  "reading template",
+		},
+		{
+			name: "bad golden path",
+			edit: func(c *Case) { c.Golden = "{{.Nope" },
+			err:  "parsing golden path",
+		},
+		{
+			name: "unknown built-in template",
+			edit: func(c *Case) { c.Template = "builtin:nope" },
+			err:  "no such built-in template",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := golden(t)
+			tt.edit(&c)
+			_, err := Run(c, false)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Run error = %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
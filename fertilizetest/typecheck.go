@@ -0,0 +1,101 @@
+package fertilizetest
+
+import (
+	"fmt"
+	"go/ast"
+	goparser "go/parser"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// typeChecker type-checks generated Go against fixture packages.
+type typeChecker struct {
+	// fixtures are the fixture packages by import path.
+	fixtures map[string]*packages.Package
+	// deps are the type-checked packages fixtures import, and the
+	// fixtures themselves, by import path. Other packages the
+	// generated code imports are loaded and added as needed.
+	deps map[string]*types.Package
+	dir  string
+}
+
+// loadTypeChecker loads the packages matching patterns in dir along with
+// the types of everything they import.
+func loadTypeChecker(dir string, patterns []string) (*typeChecker, error) {
+	pkgs, err := packages.Load(&packages.Config{
+		Mode: packages.NeedName | packages.NeedFiles | packages.NeedCompiledGoFiles | packages.NeedSyntax |
+			packages.NeedTypes | packages.NeedTypesInfo | packages.NeedImports | packages.NeedDeps,
+		Dir: dir,
+	}, patterns...)
+	if err != nil {
+		return nil, fmt.Errorf("loading fixtures: %w", err)
+	}
+	c := &typeChecker{
+		fixtures: make(map[string]*packages.Package),
+		deps:     make(map[string]*types.Package),
+		dir:      dir,
+	}
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if pkg.Types != nil {
+			c.deps[pkg.PkgPath] = pkg.Types
+		}
+	})
+	for _, pkg := range pkgs {
+		c.fixtures[pkg.PkgPath] = pkg
+	}
+	return c, nil
+}
+
+func (c *typeChecker) Import(path string) (*types.Package, error) {
+	if pkg, ok := c.deps[path]; ok {
+		return pkg, nil
+	}
+	pkgs, err := packages.Load(&packages.Config{
+		Mode: packages.NeedName | packages.NeedTypes | packages.NeedImports | packages.NeedDeps,
+		Dir:  c.dir,
+	}, path)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 || pkgs[0].Types == nil || len(pkgs[0].Errors) > 0 {
+		return nil, fmt.Errorf("can't load %s", path)
+	}
+	packages.Visit(pkgs, nil, func(pkg *packages.Package) {
+		if _, ok := c.deps[pkg.PkgPath]; !ok && pkg.Types != nil {
+			c.deps[pkg.PkgPath] = pkg.Types
+		}
+	})
+	return pkgs[0].Types, nil
+}
+
+// check type-checks src, rendered for the fixture package pkgPath. If it
+// declares the same package it is checked along with the fixture's
+// files, otherwise on its own.
+func (c *typeChecker) check(pkgPath string, src []byte) error {
+	fixture, ok := c.fixtures[pkgPath]
+	if !ok {
+		return fmt.Errorf("fixture %s wasn't loaded", pkgPath)
+	}
+	fset := token.NewFileSet()
+	f, err := goparser.ParseFile(fset, "generated.go", src, goparser.AllErrors)
+	if err != nil {
+		return err
+	}
+	files := []*ast.File{f}
+	path := pkgPath + "/generated"
+	if f.Name.Name == fixture.Name {
+		path = pkgPath
+		for _, name := range fixture.CompiledGoFiles {
+			ff, err := goparser.ParseFile(fset, name, nil, goparser.AllErrors)
+			if err != nil {
+				return err
+			}
+			files = append(files, ff)
+		}
+	}
+	conf := types.Config{Importer: c}
+	_, err = conf.Check(path, fset, files, nil)
+	return err
+}
@@ -0,0 +1,226 @@
+// Package collection exports requests for trying out services by hand:
+// Postman collections and .http files for editors.
+//
+// There is one request per Service.Method, grouped by package and
+// service, POSTing a body built from the example metadata of the fields
+// of the request object, and zero values where there is none.
+package collection
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gitamped/fertilize/gen"
+	"github.com/gitamped/fertilize/parser"
+)
+
+// Options configure the exported requests.
+type Options struct {
+	// Name is the name of the collection. Defaults to the name of the
+	// package if only one is described (see gen.Main), otherwise "API".
+	Name string `mapstructure:"name"`
+	// BaseURL is the initial value of the baseUrl variable requests are
+	// sent to. Defaults to http://localhost:8080.
+	BaseURL string `mapstructure:"baseURL"`
+	// BasePath is prefixed to each route. Defaults to /v1/, the seed
+	// server default.
+	BasePath string `mapstructure:"basePath"`
+}
+
+func (opts *Options) defaults(defs []*parser.Definition) {
+	if opts.Name == "" {
+		opts.Name = "API"
+		if d := gen.Main(defs); d != nil {
+			opts.Name = d.PackageName
+		}
+	}
+	if opts.BaseURL == "" {
+		opts.BaseURL = "http://localhost:8080"
+	}
+	if opts.BasePath == "" {
+		opts.BasePath = "/v1/"
+	}
+}
+
+// request is a request to a method.
+type request struct {
+	pkg, service, method string
+	comment              gen.Comment
+	path                 string
+	body                 string
+}
+
+// requests lists the requests to every method in defs.
+func requests(defs []*parser.Definition, opts Options) ([]request, error) {
+	objects := gen.NewObjects(defs)
+	var reqs []request
+	for _, d := range defs {
+		for _, s := range d.Services {
+			for _, m := range s.Methods {
+				var body interface{} = gen.Ordered{}
+				if in := gen.Request(m); in != nil {
+					body = gen.Sample(objects, gen.TypeOf(*in))
+				}
+				b, err := json.MarshalIndent(body, "", "  ")
+				if err != nil {
+					return nil, fmt.Errorf("%s.%s: %w", s.Name, m.Name, err)
+				}
+				reqs = append(reqs, request{
+					pkg:     d.PackageName,
+					service: s.Name,
+					method:  m.Name,
+					comment: gen.ParseComment(m.Comment),
+					path:    opts.BasePath + s.Name + "." + m.Name,
+					body:    string(b),
+				})
+			}
+		}
+	}
+	return reqs, nil
+}
+
+// PostmanSchema is the schema of Postman v2.1 collections.
+const PostmanSchema = "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+
+// Postman collection types, as far as they are used.
+type (
+	// Collection is a Postman collection.
+	Collection struct {
+		Info     Info       `json:"info"`
+		Item     []*Item    `json:"item"`
+		Variable []Variable `json:"variable,omitempty"`
+	}
+	// Info describes a collection.
+	Info struct {
+		Name   string `json:"name"`
+		Schema string `json:"schema"`
+	}
+	// Item is a folder of items or a request.
+	Item struct {
+		Name        string   `json:"name"`
+		Description string   `json:"description,omitempty"`
+		Item        []*Item  `json:"item,omitempty"`
+		Request     *Request `json:"request,omitempty"`
+	}
+	// Request is an HTTP request.
+	Request struct {
+		Method      string   `json:"method"`
+		Header      []Header `json:"header"`
+		Body        *Body    `json:"body,omitempty"`
+		URL         URL      `json:"url"`
+		Description string   `json:"description,omitempty"`
+	}
+	// Header is an HTTP header.
+	Header struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	}
+	// Body is a request body.
+	Body struct {
+		Mode    string      `json:"mode"`
+		Raw     string      `json:"raw"`
+		Options interface{} `json:"options,omitempty"`
+	}
+	// URL is the URL a request is sent to.
+	URL struct {
+		Raw  string   `json:"raw"`
+		Host []string `json:"host"`
+		Path []string `json:"path"`
+	}
+	// Variable is a collection variable.
+	Variable struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	}
+)
+
+// Postman makes a Postman v2.1 collection with a folder per package
+// holding a folder per service.
+func Postman(defs []*parser.Definition, opts Options) ([]byte, error) {
+	opts.defaults(defs)
+	reqs, err := requests(defs, opts)
+	if err != nil {
+		return nil, err
+	}
+	c := Collection{
+		Info:     Info{Name: opts.Name, Schema: PostmanSchema},
+		Item:     []*Item{},
+		Variable: []Variable{{Key: "baseUrl", Value: opts.BaseURL}},
+	}
+	packages := make(map[string]*Item)
+	services := make(map[string]*Item)
+	comments := make(map[string]string)
+	for _, d := range defs {
+		for _, s := range d.Services {
+			comments[d.PackageName+"."+s.Name] = gen.ParseComment(s.Comment).Text
+		}
+	}
+	for _, r := range reqs {
+		pkg, ok := packages[r.pkg]
+		if !ok {
+			pkg = &Item{Name: r.pkg}
+			packages[r.pkg] = pkg
+			c.Item = append(c.Item, pkg)
+		}
+		key := r.pkg + "." + r.service
+		service, ok := services[key]
+		if !ok {
+			service = &Item{Name: r.service, Description: comments[key]}
+			services[key] = service
+			pkg.Item = append(pkg.Item, service)
+		}
+		service.Item = append(service.Item, &Item{
+			Name: r.method,
+			Request: &Request{
+				Method: "POST",
+				Header: []Header{{Key: "Content-Type", Value: "application/json"}},
+				Body: &Body{
+					Mode:    "raw",
+					Raw:     r.body,
+					Options: map[string]interface{}{"raw": map[string]string{"language": "json"}},
+				},
+				URL: URL{
+					Raw:  "{{baseUrl}}" + r.path,
+					Host: []string{"{{baseUrl}}"},
+					Path: strings.Split(strings.Trim(r.path, "/"), "/"),
+				},
+				Description: r.comment.Text,
+			},
+		})
+	}
+	b, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// HTTPFile makes a .http file, as used by the REST Client extension for
+// VS Code and JetBrains IDEs, with the baseUrl as a variable.
+func HTTPFile(defs []*parser.Definition, opts Options) ([]byte, error) {
+	opts.defaults(defs)
+	reqs, err := requests(defs, opts)
+	if err != nil {
+		return nil, err
+	}
+	var w strings.Builder
+	fmt.Fprintf(&w, "# %s\n", opts.Name)
+	fmt.Fprintln(&w, "# Code generated by fertilize; DO NOT EDIT.")
+	fmt.Fprintln(&w)
+	fmt.Fprintf(&w, "@baseUrl = %s\n", opts.BaseURL)
+	for _, r := range reqs {
+		fmt.Fprintln(&w)
+		fmt.Fprintf(&w, "### %s / %s.%s\n", r.pkg, r.service, r.method)
+		if r.comment.Text != "" {
+			for _, line := range strings.Split(r.comment.Text, "\n") {
+				fmt.Fprintf(&w, "# %s\n", line)
+			}
+		}
+		fmt.Fprintf(&w, "POST {{baseUrl}}%s\n", r.path)
+		fmt.Fprintln(&w, "Content-Type: application/json")
+		fmt.Fprintln(&w)
+		fmt.Fprintln(&w, r.body)
+	}
+	return []byte(w.String()), nil
+}
@@ -0,0 +1,135 @@
+package collection
+
+import (
+	"encoding/json"
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gitamped/fertilize/fertilizetest"
+	"github.com/gitamped/fertilize/parser"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGenerate(t *testing.T) {
+	fertilizetest.Test(t, fertilizetest.Case{
+		Name:     "postman",
+		Renderer: fertilizetest.Generator(Postman, Options{}),
+		Packages: []string{"../../examples/testdata/services/..."},
+		Golden:   "testdata/{{.PackageName}}.postman.json.golden",
+	}, *update)
+	fertilizetest.Test(t, fertilizetest.Case{
+		Name:     "http",
+		Renderer: fertilizetest.Generator(HTTPFile, Options{}),
+		Packages: []string{"../../examples/testdata/services/..."},
+		Golden:   "testdata/{{.PackageName}}.http.golden",
+	}, *update)
+}
+
+func TestOptions(t *testing.T) {
+	kinds := &parser.Definition{PackageName: "kinds", Services: []parser.Service{{Name: "A"}}}
+	other := &parser.Definition{PackageName: "other", Services: []parser.Service{{Name: "B"}}}
+	objectsOnly := &parser.Definition{PackageName: "objects"}
+	for _, tt := range []struct {
+		defs []*parser.Definition
+		opts Options
+		want Options
+	}{
+		{[]*parser.Definition{kinds}, Options{}, Options{Name: "kinds", BaseURL: "http://localhost:8080", BasePath: "/v1/"}},
+		// the other packages only matter for their objects
+		{[]*parser.Definition{kinds, objectsOnly}, Options{}, Options{Name: "kinds", BaseURL: "http://localhost:8080", BasePath: "/v1/"}},
+		{[]*parser.Definition{kinds, other}, Options{}, Options{Name: "API", BaseURL: "http://localhost:8080", BasePath: "/v1/"}},
+		{nil, Options{}, Options{Name: "API", BaseURL: "http://localhost:8080", BasePath: "/v1/"}},
+		{
+			[]*parser.Definition{kinds},
+			Options{Name: "Kinds", BaseURL: "https://api.example.com", BasePath: "/rpc/"},
+			Options{Name: "Kinds", BaseURL: "https://api.example.com", BasePath: "/rpc/"},
+		},
+	} {
+		opts := tt.opts
+		opts.defaults(tt.defs)
+		if opts != tt.want {
+			t.Errorf("defaults of %+v = %+v, want %+v", tt.opts, opts, tt.want)
+		}
+	}
+}
+
+func TestHTTPFile(t *testing.T) {
+	defs := fertilizetest.Parse(t, "../../examples/testdata/services/pleasantries").Packages
+	b, err := HTTPFile(defs, Options{Name: "Greetings", BaseURL: "https://api.example.com", BasePath: "/rpc/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# Greetings\n",
+		"@baseUrl = https://api.example.com\n",
+		"### pleasantries / GreeterService.Greet\n# Greet creates a Greeting for one or more people.\nPOST {{baseUrl}}/rpc/GreeterService.Greet\n",
+		// examples are used where there are some
+		"{\n  \"Names\": [\n    \"Mat\",\n    \"David\"\n  ]\n}\n",
+		// and zero values elsewhere
+		"\"Cursor\": \"\",\n",
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("the .http file doesn't contain:\n%s\nin:\n%s", want, b)
+		}
+	}
+}
+
+func TestPostman(t *testing.T) {
+	defs := fertilizetest.Parse(t, "../../examples/testdata/services/pleasantries").Packages
+	b, err := Postman(defs, Options{BaseURL: "https://api.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c Collection
+	if err := json.Unmarshal(b, &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Info.Name != "pleasantries" || c.Info.Schema != PostmanSchema {
+		t.Errorf("info = %+v", c.Info)
+	}
+	if want := []Variable{{Key: "baseUrl", Value: "https://api.example.com"}}; !reflect.DeepEqual(c.Variable, want) {
+		t.Errorf("variables = %+v, want %+v", c.Variable, want)
+	}
+	if len(c.Item) != 1 || c.Item[0].Name != "pleasantries" {
+		t.Fatalf("folders = %+v, want one for pleasantries", c.Item)
+	}
+	var greet *Item
+	for _, service := range c.Item[0].Item {
+		for _, method := range service.Item {
+			if service.Name == "GreeterService" && method.Name == "Greet" {
+				greet = method
+			}
+		}
+	}
+	if greet == nil {
+		t.Fatal("there is no GreeterService.Greet request")
+	}
+	r := greet.Request
+	if r.Method != "POST" || r.URL.Raw != "{{baseUrl}}/v1/GreeterService.Greet" || !reflect.DeepEqual(r.URL.Path, []string{"v1", "GreeterService.Greet"}) {
+		t.Errorf("request = %+v", r)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal([]byte(r.Body.Raw), &body); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]interface{}{"Names": []interface{}{"Mat", "David"}}; !reflect.DeepEqual(body, want) {
+		t.Errorf("body = %v, want %v", body, want)
+	}
+}
+
+func TestRequestsWithoutInput(t *testing.T) {
+	defs := []*parser.Definition{{
+		PackageName: "api",
+		Services:    []parser.Service{{Name: "Things", Methods: []parser.Method{{Name: "Ping"}}}},
+	}}
+	reqs, err := requests(defs, Options{BasePath: "/v1/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reqs) != 1 || reqs[0].body != "{}" || reqs[0].path != "/v1/Things.Ping" {
+		t.Errorf("requests = %+v, want one with an empty body", reqs)
+	}
+}
@@ -0,0 +1,300 @@
+// Package docs generates API reference documentation.
+//
+// The reference lists the services of each package with their methods,
+// and the objects clients send and receive with a table of their fields.
+// Comments become the text, comment metadata examples become example
+// bodies, and types link to the objects they refer to. It is written as
+// Markdown or as a single static HTML page with a sidebar and search.
+package docs
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/gitamped/fertilize/gen"
+	"github.com/gitamped/fertilize/parser"
+)
+
+// Options configure the generated reference.
+type Options struct {
+	// Title is the title of the reference. Defaults to the name of the
+	// package if only one is described (see gen.Main), otherwise "API
+	// reference".
+	Title string `mapstructure:"title"`
+	// BasePath is prefixed to each route. Defaults to /v1/, the seed
+	// server default.
+	BasePath string `mapstructure:"basePath"`
+}
+
+// Reference is the content of the documentation.
+type Reference struct {
+	Title    string
+	Packages []Package
+}
+
+// Package documents the services and objects of a package.
+type Package struct {
+	Name     string
+	Services []Service
+	Objects  []Object
+}
+
+// Service documents a Service.
+type Service struct {
+	Name    string
+	Anchor  string
+	Comment gen.Comment
+	Methods []Method
+}
+
+// Method documents a Method.
+type Method struct {
+	Name    string
+	Anchor  string
+	Path    string
+	Comment gen.Comment
+	// Request and Response are nil if the method takes or returns
+	// nothing.
+	Request  *TypeRef
+	Response *TypeRef
+	// RequestExample and ResponseExample are indented JSON built from
+	// the examples of the fields, or empty if there are none.
+	RequestExample  string
+	ResponseExample string
+}
+
+// Object documents an Object.
+type Object struct {
+	Name    string
+	Anchor  string
+	Comment gen.Comment
+	Fields  []Field
+	Example string
+}
+
+// Field documents a field of an Object.
+type Field struct {
+	// Name is the JSON name.
+	Name     string
+	Type     TypeRef
+	Comment  gen.Comment
+	Optional bool
+	// Example is the example as JSON, or empty.
+	Example string
+}
+
+// TypeRef describes the JSON type of a value, such as "Greeting[]".
+// If it refers to an object, Object is its name and Anchor the anchor of
+// its documentation.
+type TypeRef struct {
+	Text   string
+	Object string
+	Anchor string
+}
+
+// Build makes the Reference describing defs.
+func Build(defs []*parser.Definition, opts Options) *Reference {
+	if opts.BasePath == "" {
+		opts.BasePath = "/v1/"
+	}
+	if opts.Title == "" {
+		opts.Title = "API reference"
+		if d := gen.Main(defs); d != nil {
+			opts.Title = d.PackageName
+		}
+	}
+	objects := gen.NewObjects(defs)
+	reachable := make(map[string]bool)
+	for _, o := range gen.Reachable(defs) {
+		reachable[o.Name] = true
+	}
+	ref := &Reference{Title: opts.Title}
+	for _, d := range defs {
+		p := Package{Name: d.PackageName}
+		for _, s := range d.Services {
+			service := Service{
+				Name:    s.Name,
+				Anchor:  Anchor(s.Name),
+				Comment: gen.ParseComment(s.Comment),
+			}
+			for _, m := range s.Methods {
+				method := Method{
+					Name:    m.Name,
+					Anchor:  Anchor(s.Name + "." + m.Name),
+					Path:    opts.BasePath + s.Name + "." + m.Name,
+					Comment: gen.ParseComment(m.Comment),
+				}
+				if in := gen.Request(m); in != nil {
+					t := typeRef(objects, gen.TypeOf(*in))
+					method.Request = &t
+					method.RequestExample = indent(example(objects, gen.TypeOf(*in), nil))
+				}
+				if out := gen.Response(m); out != nil {
+					t := typeRef(objects, gen.TypeOf(*out))
+					method.Response = &t
+					method.ResponseExample = indent(example(objects, gen.TypeOf(*out), nil))
+				}
+				service.Methods = append(service.Methods, method)
+			}
+			p.Services = append(p.Services, service)
+		}
+		for i := range d.Objects {
+			o := &d.Objects[i]
+			if !reachable[o.Name] {
+				continue
+			}
+			p.Objects = append(p.Objects, object(objects, o))
+		}
+		if len(p.Services) == 0 && len(p.Objects) == 0 {
+			// nothing of it is used
+			continue
+		}
+		ref.Packages = append(ref.Packages, p)
+	}
+	return ref
+}
+
+func object(objects gen.Objects, o *parser.Object) Object {
+	obj := Object{
+		Name:    o.Name,
+		Anchor:  Anchor(o.Name),
+		Comment: gen.ParseComment(o.Comment),
+		Example: indent(example(objects, &gen.Type{Kind: gen.Named, Name: o.Name}, nil)),
+	}
+	for _, f := range o.Fields {
+		jf := gen.JSON(f)
+		if jf.Skip {
+			continue
+		}
+		t := gen.TypeOf(f.Type)
+		field := Field{
+			Name:     jf.Name,
+			Type:     typeRef(objects, t),
+			Comment:  gen.ParseComment(f.Comment),
+			Optional: jf.OmitEmpty || t.Kind == gen.Pointer,
+		}
+		if jf.String {
+			field.Type = TypeRef{Text: "string"}
+		}
+		if v, ok := field.Comment.Example(); ok {
+			if b, err := json.Marshal(v); err == nil {
+				field.Example = string(b)
+			}
+		}
+		obj.Fields = append(obj.Fields, field)
+	}
+	return obj
+}
+
+// typeRef describes the JSON values encoding/json makes from t.
+func typeRef(objects gen.Objects, t *gen.Type) TypeRef {
+	t = t.JSON()
+	switch {
+	case t.IsBytes():
+		return TypeRef{Text: "string (base64)"}
+	case t.IsTime():
+		return TypeRef{Text: "string (date-time)"}
+	}
+	switch t.Kind {
+	case gen.Pointer:
+		ref := typeRef(objects, t.Elem)
+		if !strings.HasSuffix(ref.Text, " | null") && ref.Text != "any" {
+			ref.Text += " | null"
+		}
+		return ref
+	case gen.Slice:
+		ref := typeRef(objects, t.Elem)
+		if strings.Contains(ref.Text, " ") {
+			ref.Text = "(" + ref.Text + ")"
+		}
+		ref.Text += "[]"
+		return ref
+	case gen.Map:
+		ref := typeRef(objects, t.Elem)
+		ref.Text = "map of " + ref.Text
+		return ref
+	case gen.Basic:
+		switch {
+		case t.Name == "string":
+			return TypeRef{Text: "string"}
+		case t.Name == "bool":
+			return TypeRef{Text: "boolean"}
+		case t.IsInteger():
+			return TypeRef{Text: "integer"}
+		case t.IsFloat():
+			return TypeRef{Text: "number"}
+		}
+	case gen.Named:
+		if o := objects.Lookup(t); o != nil {
+			return TypeRef{Text: o.Name, Object: o.Name, Anchor: Anchor(o.Name)}
+		}
+	}
+	return TypeRef{Text: "any"}
+}
+
+// example builds an example value of type t from the example metadata
+// of the fields of the objects it uses. Returns nil if there are none.
+func example(objects gen.Objects, t *gen.Type, seen map[string]bool) interface{} {
+	o := objects.Lookup(t.Deref())
+	if o == nil || seen[o.Name] {
+		return nil
+	}
+	if seen == nil {
+		seen = make(map[string]bool)
+	}
+	seen[o.Name] = true
+	defer delete(seen, o.Name)
+	body := make(map[string]interface{})
+	for _, f := range o.Fields {
+		jf := gen.JSON(f)
+		if jf.Skip {
+			continue
+		}
+		if v, ok := gen.ParseComment(f.Comment).Example(); ok {
+			body[jf.Name] = v
+			continue
+		}
+		ft := gen.TypeOf(f.Type)
+		elem := ft.Deref()
+		if elem.Kind == gen.Slice {
+			if v := example(objects, elem.Elem, seen); v != nil {
+				body[jf.Name] = []interface{}{v}
+			}
+			continue
+		}
+		if v := example(objects, elem, seen); v != nil {
+			body[jf.Name] = v
+		}
+	}
+	if len(body) == 0 {
+		return nil
+	}
+	return body
+}
+
+// indent formats v as indented JSON, or returns "" if v is nil.
+func indent(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// Anchor makes the anchor of a heading the way GitHub does: lower case,
+// with spaces replaced by dashes and other punctuation removed.
+func Anchor(heading string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(heading) {
+		switch {
+		case r == ' ':
+			sb.WriteRune('-')
+		case r == '-', r == '_', r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
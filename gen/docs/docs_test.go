@@ -0,0 +1,198 @@
+package docs
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gitamped/fertilize/fertilizetest"
+	"github.com/gitamped/fertilize/gen"
+	"github.com/gitamped/fertilize/parser"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGenerate(t *testing.T) {
+	fertilizetest.Test(t, fertilizetest.Case{
+		Name:     "markdown",
+		Renderer: fertilizetest.Generator(Markdown, Options{}),
+		Packages: []string{"../../examples/testdata/services/..."},
+		Golden:   "testdata/{{.PackageName}}.md.golden",
+	}, *update)
+	fertilizetest.Test(t, fertilizetest.Case{
+		Name:     "html",
+		Renderer: fertilizetest.Generator(HTML, Options{}),
+		Packages: []string{"../../examples/testdata/services/..."},
+		Golden:   "testdata/{{.PackageName}}.html.golden",
+	}, *update)
+}
+
+// headings gets the headings of the Markdown in b.
+func headings(b []byte) []string {
+	var headings []string
+	for _, line := range strings.Split(string(b), "\n") {
+		if strings.HasPrefix(line, "#") {
+			headings = append(headings, line)
+		}
+	}
+	return headings
+}
+
+func TestMarkdownPackages(t *testing.T) {
+	doc := fertilizetest.Parse(t, "../../examples/testdata/services", "../../examples/testdata/services/kinds", "../../examples/testdata/services/pleasantries")
+	kinds := doc.Package("github.com/gitamped/fertilize/examples/testdata/services/kinds")
+	b, err := Markdown(doc.Alone(kinds), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// nothing of the other packages is used, so they are left out and
+	// the sections stay at the second level
+	want := []string{
+		"# kinds",
+		"## Services",
+		"### StatusService",
+		"#### StatusService.Check",
+		"## Objects",
+		"### CheckRequest",
+		"### CheckResponse",
+	}
+	if got := headings(b); !reflect.DeepEqual(got, want) {
+		t.Errorf("headings of kinds = %q, want %q", got, want)
+	}
+
+	// pleasantries uses services.Page, which is described under its own
+	// package
+	pleasantries := doc.Package("github.com/gitamped/fertilize/examples/testdata/services/pleasantries")
+	b, err = Markdown(doc.Alone(pleasantries), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := headings(b)
+	want = []string{
+		"# pleasantries",
+		"## Package pleasantries",
+		"### Services",
+		"#### GreeterService",
+		"##### GreeterService.GetGreetings",
+	}
+	if !reflect.DeepEqual(got[:len(want)], want) {
+		t.Errorf("headings of pleasantries start %q, want %q", got[:len(want)], want)
+	}
+	want = []string{
+		"## Package services",
+		"### Objects",
+		"#### Page",
+	}
+	if tail := got[len(got)-len(want):]; !reflect.DeepEqual(tail, want) {
+		t.Errorf("headings of pleasantries end %q, want %q", tail, want)
+	}
+}
+
+func TestBuildOptions(t *testing.T) {
+	doc := fertilizetest.Parse(t, "../../examples/testdata/services/filters", "../../examples/testdata/services/kinds")
+	kinds := doc.Packages[1:]
+	ref := Build(kinds, Options{})
+	if ref.Title != "kinds" {
+		t.Errorf("title of one package = %q, want kinds", ref.Title)
+	}
+	if got := ref.Packages[0].Services[0].Methods[0].Path; got != "/v1/StatusService.Check" {
+		t.Errorf("default path = %q, want /v1/StatusService.Check", got)
+	}
+	if ref := Build(doc.Packages, Options{}); ref.Title != "API reference" {
+		t.Errorf("title of two packages = %q, want API reference", ref.Title)
+	}
+
+	ref = Build(kinds, Options{Title: "Status", BasePath: "/api/"})
+	if ref.Title != "Status" {
+		t.Errorf("title = %q, want Status", ref.Title)
+	}
+	if got := ref.Packages[0].Services[0].Methods[0].Path; got != "/api/StatusService.Check" {
+		t.Errorf("path = %q, want /api/StatusService.Check", got)
+	}
+}
+
+func TestBuildObjects(t *testing.T) {
+	defs := fertilizetest.Parse(t, "../../examples/testdata/services/filters").Packages
+	var names []string
+	for _, o := range Build(defs, Options{}).Packages[0].Objects {
+		names = append(names, o.Name)
+	}
+	// objects no service uses are left out
+	for _, name := range names {
+		if name == "Config" {
+			t.Errorf("objects %q include Config, which no service uses", names)
+		}
+	}
+	if len(names) == 0 {
+		t.Error("no objects are documented")
+	}
+}
+
+func TestTypeRef(t *testing.T) {
+	objects := gen.Objects{"Page": &parser.Object{Name: "Page"}}
+	for typ, want := range map[string]TypeRef{
+		"string":         {Text: "string"},
+		"*int":           {Text: "integer | null"},
+		"[]*float64":     {Text: "(number | null)[]"},
+		"map[string]int": {Text: "map of integer"},
+		"[]byte":         {Text: "string (base64)"},
+		"time.Time":      {Text: "string (date-time)"},
+		"*interface{}":   {Text: "any"},
+		"[]*Page":        {Text: "(Page | null)[]", Object: "Page", Anchor: "page"},
+	} {
+		if got := typeRef(objects, gen.ParseType(typ)); got != want {
+			t.Errorf("typeRef(%s) = %+v, want %+v", typ, got, want)
+		}
+	}
+}
+
+func TestExample(t *testing.T) {
+	// a tree of nodes only gets one level of children
+	node := &parser.Object{Name: "Node", Fields: []parser.Field{
+		{Name: "Name", Type: parser.FieldType{TypeName: "string"}, Comment: `example: "root"`},
+		{Name: "Children", Type: parser.FieldType{TypeName: "[]*Node"}},
+		{Name: "Size", Type: parser.FieldType{TypeName: "int"}},
+	}}
+	got := indent(example(gen.Objects{"Node": node}, gen.ParseType("*Node"), nil))
+	want := "{\n  \"Name\": \"root\"\n}"
+	if got != want {
+		t.Errorf("example = %s, want %s", got, want)
+	}
+	if got := example(gen.Objects{}, gen.ParseType("Node"), nil); got != nil {
+		t.Errorf("example of an unknown object = %v, want nil", got)
+	}
+}
+
+func TestAnchor(t *testing.T) {
+	for heading, want := range map[string]string{
+		"GreeterService":       "greeterservice",
+		"GreeterService.Greet": "greeterservicegreet",
+		"Package kinds":        "package-kinds",
+		"snake_case-dash":      "snake_case-dash",
+	} {
+		if got := Anchor(heading); got != want {
+			t.Errorf("Anchor(%q) = %q, want %q", heading, got, want)
+		}
+	}
+}
+
+func TestHTML(t *testing.T) {
+	defs := fertilizetest.Parse(t, "../../examples/testdata/services/kinds").Packages
+	b, err := HTML(defs, Options{Title: "<Status>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "<title>&lt;Status&gt;</title>") {
+		t.Error("the title isn't escaped")
+	}
+	want := []Entry{
+		{Title: "StatusService", Kind: "service", Anchor: "statusservice", Summary: "StatusService reports the status of things."},
+		{Title: "StatusService.Check", Kind: "method", Anchor: "statusservicecheck", Summary: "Check checks the status of a thing."},
+		{Title: "CheckRequest", Kind: "object", Anchor: "checkrequest", Summary: "CheckRequest is the request object for StatusService.Check."},
+		{Title: "CheckResponse", Kind: "object", Anchor: "checkresponse", Summary: "CheckResponse is the response object for StatusService.Check."},
+	}
+	if got := Index(Build(defs, Options{})); !reflect.DeepEqual(got, want) {
+		t.Errorf("Index = %+v, want %+v", got, want)
+	}
+}
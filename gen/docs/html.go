@@ -0,0 +1,200 @@
+package docs
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+
+	"github.com/gitamped/fertilize/parser"
+)
+
+// Entry is an item of the search index of the HTML reference.
+type Entry struct {
+	Title   string `json:"title"`
+	Kind    string `json:"kind"`
+	Anchor  string `json:"anchor"`
+	Summary string `json:"summary,omitempty"`
+}
+
+// Index lists the services, methods and objects of ref for searching.
+func Index(ref *Reference) []Entry {
+	var index []Entry
+	for _, p := range ref.Packages {
+		for _, s := range p.Services {
+			index = append(index, Entry{Title: s.Name, Kind: "service", Anchor: s.Anchor, Summary: s.Comment.Summary()})
+			for _, m := range s.Methods {
+				index = append(index, Entry{Title: s.Name + "." + m.Name, Kind: "method", Anchor: m.Anchor, Summary: m.Comment.Summary()})
+			}
+		}
+		for _, o := range p.Objects {
+			index = append(index, Entry{Title: o.Name, Kind: "object", Anchor: o.Anchor, Summary: o.Comment.Summary()})
+		}
+	}
+	return index
+}
+
+// HTML makes a single page HTML reference describing defs, with a
+// sidebar linking to everything and a search box.
+func HTML(defs []*parser.Definition, opts Options) ([]byte, error) {
+	ref := Build(defs, opts)
+	var buf bytes.Buffer
+	err := htmlTemplate.Execute(&buf, struct {
+		*Reference
+		Index []Entry
+	}{ref, Index(ref)})
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var htmlTemplate = template.Must(template.New("docs").Funcs(template.FuncMap{
+	"paragraphs": paragraphs,
+}).Parse(htmlSource))
+
+// paragraphs splits text into paragraphs at blank lines.
+func paragraphs(text string) []string {
+	var paras []string
+	for _, p := range strings.Split(text, "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			paras = append(paras, p)
+		}
+	}
+	return paras
+}
+
+const htmlSource = `<!DOCTYPE html>
+<!-- Code generated by fertilize; DO NOT EDIT. -->
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { margin: 0; font-family: system-ui, sans-serif; line-height: 1.5; color: #222; }
+nav { position: fixed; top: 0; bottom: 0; left: 0; width: 16rem; overflow-y: auto; padding: 1rem; background: #f6f6f6; border-right: 1px solid #ddd; box-sizing: border-box; }
+nav input { width: 100%; box-sizing: border-box; padding: .4rem; margin-bottom: 1rem; }
+nav h2 { font-size: .8rem; text-transform: uppercase; color: #666; margin: 1rem 0 .25rem; }
+nav ul { list-style: none; margin: 0; padding: 0; }
+nav li.method { padding-left: 1rem; }
+nav a { color: inherit; text-decoration: none; }
+nav a:hover { text-decoration: underline; }
+main { margin-left: 16rem; padding: 1rem 2rem; max-width: 50rem; }
+section { border-top: 1px solid #eee; }
+code, pre { font-family: ui-monospace, monospace; }
+pre { background: #f6f6f6; padding: .75rem; overflow-x: auto; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; vertical-align: top; padding: .4rem; border-bottom: 1px solid #eee; }
+.route { font-weight: bold; }
+.optional { color: #666; font-size: .9em; }
+</style>
+</head>
+<body>
+<nav>
+<input id="search" type="search" placeholder="Search" aria-label="Search">
+{{- range .Packages}}
+{{- if .Services}}
+<h2>Services</h2>
+<ul>
+{{- range .Services}}
+<li class="service"><a href="#{{.Anchor}}">{{.Name}}</a></li>
+{{- range .Methods}}
+<li class="method"><a href="#{{.Anchor}}">{{.Name}}</a></li>
+{{- end}}
+{{- end}}
+</ul>
+{{- end}}
+{{- if .Objects}}
+<h2>Objects</h2>
+<ul>
+{{- range .Objects}}
+<li class="object"><a href="#{{.Anchor}}">{{.Name}}</a></li>
+{{- end}}
+</ul>
+{{- end}}
+{{- end}}
+</nav>
+<main>
+<h1>{{.Title}}</h1>
+{{- $multiple := gt (len .Packages) 1}}
+{{- range .Packages}}
+{{- if $multiple}}
+<h1>Package {{.Name}}</h1>
+{{- end}}
+{{- range .Services}}
+<section>
+<h2 id="{{.Anchor}}">{{.Name}}</h2>
+{{- range paragraphs .Comment.Text}}
+<p>{{.}}</p>
+{{- end}}
+{{- $service := .Name}}
+{{- range .Methods}}
+<h3 id="{{.Anchor}}">{{$service}}.{{.Name}}</h3>
+{{- range paragraphs .Comment.Text}}
+<p>{{.}}</p>
+{{- end}}
+<p class="route"><code>POST {{.Path}}</code></p>
+<ul>
+{{- with .Request}}
+<li>Request: {{template "type" .}}</li>
+{{- end}}
+{{- with .Response}}
+<li>Response: {{template "type" .}}</li>
+{{- end}}
+</ul>
+{{- with .RequestExample}}
+<p>Example request:</p>
+<pre>{{.}}</pre>
+{{- end}}
+{{- with .ResponseExample}}
+<p>Example response:</p>
+<pre>{{.}}</pre>
+{{- end}}
+{{- end}}
+</section>
+{{- end}}
+{{- range .Objects}}
+<section>
+<h2 id="{{.Anchor}}">{{.Name}}</h2>
+{{- range paragraphs .Comment.Text}}
+<p>{{.}}</p>
+{{- end}}
+{{- if .Fields}}
+<table>
+<thead><tr><th>Field</th><th>Type</th><th>Description</th></tr></thead>
+<tbody>
+{{- range .Fields}}
+<tr>
+<td><code>{{.Name}}</code>{{if .Optional}} <span class="optional">optional</span>{{end}}</td>
+<td>{{template "type" .Type}}</td>
+<td>{{.Comment.Text}}{{with .Example}}<br>Example: <code>{{.}}</code>{{end}}</td>
+</tr>
+{{- end}}
+</tbody>
+</table>
+{{- end}}
+{{- with .Example}}
+<p>Example:</p>
+<pre>{{.}}</pre>
+{{- end}}
+</section>
+{{- end}}
+{{- end}}
+</main>
+<script>
+const index = {{.Index}};
+const search = document.getElementById("search");
+search.addEventListener("input", () => {
+	const query = search.value.trim().toLowerCase();
+	const matches = new Set(index
+		.filter((e) => (e.title + " " + (e.summary || "")).toLowerCase().includes(query))
+		.map((e) => e.anchor));
+	for (const a of document.querySelectorAll("nav li a")) {
+		const anchor = a.getAttribute("href").slice(1);
+		a.parentElement.hidden = query !== "" && !matches.has(anchor);
+	}
+});
+</script>
+</body>
+</html>
+{{define "type"}}{{if .Object}}<a href="#{{.Anchor}}"><code>{{.Text}}</code></a>{{else}}<code>{{.Text}}</code>{{end}}{{end}}`
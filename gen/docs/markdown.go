@@ -0,0 +1,148 @@
+package docs
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gitamped/fertilize/parser"
+)
+
+// Markdown makes a Markdown reference describing defs.
+func Markdown(defs []*parser.Definition, opts Options) ([]byte, error) {
+	ref := Build(defs, opts)
+	w := &markdown{}
+	w.line("<!-- Code generated by fertilize; DO NOT EDIT. -->")
+	w.line("")
+	w.line("# %s", ref.Title)
+	if len(ref.Packages) > 1 {
+		// the sections of each package go under its own heading
+		w.depth = 1
+	}
+	for _, p := range ref.Packages {
+		if len(ref.Packages) > 1 {
+			w.line("")
+			w.line("## Package %s", p.Name)
+		}
+		if len(p.Services) > 0 {
+			w.heading(2, "Services")
+		}
+		for _, s := range p.Services {
+			w.service(s)
+		}
+		if len(p.Objects) > 0 {
+			w.heading(2, "Objects")
+		}
+		for _, o := range p.Objects {
+			w.object(o)
+		}
+	}
+	return []byte(w.String()), nil
+}
+
+type markdown struct {
+	strings.Builder
+	// depth is added to the level of the headings of a package.
+	depth int
+}
+
+// heading writes a heading of level in a package.
+func (w *markdown) heading(level int, format string, args ...interface{}) {
+	w.line("")
+	w.line(strings.Repeat("#", level+w.depth)+" "+format, args...)
+}
+
+func (w *markdown) line(format string, args ...interface{}) {
+	fmt.Fprintf(w, format, args...)
+	w.WriteByte('\n')
+}
+
+// text writes the text of a comment as a paragraph.
+func (w *markdown) text(text string) {
+	if text == "" {
+		return
+	}
+	w.line("")
+	w.line("%s", text)
+}
+
+func (w *markdown) service(s Service) {
+	w.heading(3, "%s", s.Name)
+	w.text(s.Comment.Text)
+	if len(s.Methods) > 0 {
+		w.line("")
+	}
+	for _, m := range s.Methods {
+		w.line("- [%s](#%s)", m.Name, m.Anchor)
+	}
+	for _, m := range s.Methods {
+		w.heading(4, "%s.%s", s.Name, m.Name)
+		w.text(m.Comment.Text)
+		w.line("")
+		w.line("`POST %s`", m.Path)
+		w.line("")
+		if m.Request != nil {
+			w.line("- Request: %s", typeLink(*m.Request))
+		}
+		if m.Response != nil {
+			w.line("- Response: %s", typeLink(*m.Response))
+		}
+		w.example("Example request", m.RequestExample)
+		w.example("Example response", m.ResponseExample)
+	}
+}
+
+func (w *markdown) object(o Object) {
+	w.heading(3, "%s", o.Name)
+	w.text(o.Comment.Text)
+	if len(o.Fields) > 0 {
+		w.line("")
+		w.line("| Field | Type | Description |")
+		w.line("| --- | --- | --- |")
+	}
+	for _, f := range o.Fields {
+		description := cell(f.Comment.Text)
+		if f.Optional {
+			description = strings.TrimSpace("Optional. " + description)
+		}
+		if f.Example != "" {
+			description = strings.TrimSpace(description + " Example: `" + cell(f.Example) + "`")
+		}
+		typ := strings.ReplaceAll(typeLink(f.Type), "|", `\|`)
+		w.line("| `%s` | %s | %s |", f.Name, typ, description)
+	}
+	w.example("Example", o.Example)
+}
+
+func (w *markdown) example(title, example string) {
+	if example == "" {
+		return
+	}
+	w.line("")
+	w.line("%s:", title)
+	w.line("")
+	w.line("```json")
+	w.line("%s", example)
+	w.line("```")
+}
+
+// typeLink formats a type, linking to the object it refers to.
+func typeLink(t TypeRef) string {
+	if t.Object == "" {
+		return "`" + t.Text + "`"
+	}
+	before, after, _ := strings.Cut(t.Text, t.Object)
+	link := "[" + t.Object + "](#" + t.Anchor + ")"
+	if before != "" {
+		link = "`" + before + "`" + link
+	}
+	if after != "" {
+		link += "`" + after + "`"
+	}
+	return link
+}
+
+// cell makes text fit in a table cell.
+func cell(text string) string {
+	text = strings.ReplaceAll(text, "|", `\|`)
+	return strings.Join(strings.Fields(text), " ")
+}
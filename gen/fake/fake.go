@@ -0,0 +1,261 @@
+// Package fake generates test doubles for services.
+//
+// Each Service gets a Fake<Service> implementing it. Every method calls
+// the func in the matching <Method>Func field, or returns zero values if
+// it is nil, and records its arguments. Fakes are safe to call from
+// several goroutines.
+package fake
+
+import (
+	"fmt"
+	"go/format"
+	"strings"
+	"unicode"
+
+	"github.com/gitamped/fertilize/gen"
+	"github.com/gitamped/fertilize/parser"
+)
+
+// Options configure the generated code.
+type Options struct {
+	// Package is the name of the generated package. Defaults to the
+	// name of the first package with fake appended. If it is the name
+	// of the service package the fakes are declared in that package.
+	Package string `mapstructure:"package"`
+}
+
+// Generate makes fakes of the services in defs.
+func Generate(defs []*parser.Definition, opts Options) ([]byte, error) {
+	if opts.Package == "" {
+		opts.Package = "fake"
+		if len(defs) > 0 {
+			opts.Package = defs[0].PackageName + "fake"
+		}
+	}
+	g := &generator{imports: gen.NewImports("sync", "f", "fn", "call", "calls")}
+	for _, d := range defs {
+		if len(d.Services) == 0 {
+			continue
+		}
+		pkgPath := gen.PackagePath(d)
+		if d.PackageName == opts.Package {
+			// in the same package, so nothing is qualified with it
+			pkgPath = ""
+		} else if pkgPath == "" {
+			return nil, fmt.Errorf("can't find the import path of package %s", d.PackageName)
+		}
+		for _, s := range d.Services {
+			g.service(pkgPath, d.PackageName, s)
+		}
+	}
+
+	var out strings.Builder
+	fmt.Fprintln(&out, "// Code generated by fertilize; DO NOT EDIT.")
+	fmt.Fprintln(&out)
+	fmt.Fprintf(&out, "package %s\n", opts.Package)
+	// without services there are no fakes, and nothing to import
+	if g.Len() > 0 {
+		fmt.Fprintln(&out)
+		fmt.Fprintln(&out, "import (")
+		fmt.Fprintln(&out, "\t\"sync\"")
+		if g.imports.Len() > 0 {
+			fmt.Fprintln(&out)
+		}
+		for _, spec := range g.imports.Specs() {
+			fmt.Fprintf(&out, "\t%s\n", spec)
+		}
+		fmt.Fprintln(&out, ")")
+		out.WriteString(g.String())
+	}
+	b, err := format.Source([]byte(out.String()))
+	if err != nil {
+		return nil, fmt.Errorf("formatting fakes: %w", err)
+	}
+	return b, nil
+}
+
+type generator struct {
+	strings.Builder
+	imports *gen.Imports
+}
+
+func (g *generator) line(format string, args ...interface{}) {
+	fmt.Fprintf(g, format, args...)
+	g.WriteByte('\n')
+}
+
+// param is a parameter or result of a method.
+type param struct {
+	// name is the name of the field of the call recording it.
+	name string
+	typ  string
+}
+
+func (g *generator) service(pkgPath, pkgName string, s parser.Service) {
+	iface := s.Name
+	if pkgPath != "" {
+		iface = g.imports.Add(pkgPath, pkgName) + "." + s.Name
+	}
+	fake := "Fake" + s.Name
+
+	g.line("")
+	g.line("// %s is a fake %s for tests.", fake, s.Name)
+	g.line("type %s struct {", fake)
+	for _, m := range s.Methods {
+		g.line("\t// %sFunc is called by %s. If it is nil %s returns zero values.", m.Name, m.Name, m.Name)
+		g.line("\t%sFunc func%s", m.Name, g.signature(m, pkgPath, pkgName))
+	}
+	g.line("")
+	g.line("\tmu sync.Mutex")
+	for _, m := range s.Methods {
+		g.line("\t%s []%s%sCall", calls(m), fake, m.Name)
+	}
+	g.line("}")
+	g.line("")
+	g.line("var _ %s = (*%s)(nil)", iface, fake)
+	for _, m := range s.Methods {
+		g.method(fake, m, pkgPath, pkgName)
+	}
+	g.line("")
+	g.line("// Reset forgets the calls made to f.")
+	g.line("func (f *%s) Reset() {", fake)
+	g.line("\tf.mu.Lock()")
+	g.line("\tdefer f.mu.Unlock()")
+	for _, m := range s.Methods {
+		g.line("\tf.%s = nil", calls(m))
+	}
+	g.line("}")
+}
+
+func (g *generator) method(fake string, m parser.Method, pkgPath, pkgName string) {
+	call := fake + m.Name + "Call"
+	params := g.params(m.InputObjects, pkgPath, pkgName)
+	results := g.params(m.OutputObjects, pkgPath, pkgName)
+
+	g.line("")
+	g.line("// %s records a call to %s.%s.", call, fake, m.Name)
+	g.line("type %s struct {", call)
+	for _, p := range params {
+		g.line("\t%s %s", p.name, p.typ)
+	}
+	g.line("}")
+
+	var args, decl []string
+	for i, p := range params {
+		arg := fmt.Sprintf("p%d", i)
+		args = append(args, arg)
+		decl = append(decl, arg+" "+p.typ)
+	}
+	g.line("")
+	g.doc(m.Comment)
+	g.line("func (f *%s) %s(%s)%s {", fake, m.Name, strings.Join(decl, ", "), resultList(results))
+	g.line("\tf.mu.Lock()")
+	g.line("\tf.%s = append(f.%s, %s{", calls(m), calls(m), call)
+	for i, p := range params {
+		g.line("\t\t%s: %s,", p.name, args[i])
+	}
+	g.line("\t})")
+	g.line("\tfn := f.%sFunc", m.Name)
+	g.line("\tf.mu.Unlock()")
+	g.line("\tif fn == nil {")
+	if len(results) == 0 {
+		g.line("\t\treturn")
+	} else {
+		var zeros []string
+		for i, r := range results {
+			zero := fmt.Sprintf("r%d", i)
+			g.line("\t\tvar %s %s", zero, r.typ)
+			zeros = append(zeros, zero)
+		}
+		g.line("\t\treturn %s", strings.Join(zeros, ", "))
+	}
+	g.line("\t}")
+	if len(results) == 0 {
+		g.line("\tfn(%s)", strings.Join(args, ", "))
+	} else {
+		g.line("\treturn fn(%s)", strings.Join(args, ", "))
+	}
+	g.line("}")
+
+	g.line("")
+	g.line("// %sCalls gets the calls made to %s.", m.Name, m.Name)
+	g.line("func (f *%s) %sCalls() []%s {", fake, m.Name, call)
+	g.line("\tf.mu.Lock()")
+	g.line("\tdefer f.mu.Unlock()")
+	g.line("\treturn append([]%s(nil), f.%s...)", call, calls(m))
+	g.line("}")
+	g.line("")
+	g.line("// %sCallCount gets the number of calls made to %s.", m.Name, m.Name)
+	g.line("func (f *%s) %sCallCount() int {", fake, m.Name)
+	g.line("\tf.mu.Lock()")
+	g.line("\tdefer f.mu.Unlock()")
+	g.line("\treturn len(f.%s)", calls(m))
+	g.line("}")
+}
+
+// signature formats the parameter and result types of m.
+func (g *generator) signature(m parser.Method, pkgPath, pkgName string) string {
+	var types []string
+	for _, p := range g.params(m.InputObjects, pkgPath, pkgName) {
+		types = append(types, p.typ)
+	}
+	return "(" + strings.Join(types, ", ") + ")" + resultList(g.params(m.OutputObjects, pkgPath, pkgName))
+}
+
+// params describes the parameters or results of a method. Each is named
+// after its type, like GreetRequest, or Arg0 if it isn't named, with a
+// number added where names repeat.
+func (g *generator) params(fts []parser.FieldType, pkgPath, pkgName string) []param {
+	params := make([]param, len(fts))
+	used := make(map[string]int)
+	for i, ft := range fts {
+		name := fmt.Sprintf("Arg%d", i)
+		if t := gen.TypeOf(ft).Deref(); t.Kind == gen.Named && t.Name != "error" {
+			name = upperFirst(t.Name)
+		}
+		used[name]++
+		if n := used[name]; n > 1 {
+			name = fmt.Sprintf("%s%d", name, n)
+		}
+		params[i] = param{name: name, typ: g.imports.Qualify(ft, pkgPath, pkgName)}
+	}
+	return params
+}
+
+func resultList(results []param) string {
+	switch len(results) {
+	case 0:
+		return ""
+	case 1:
+		return " " + results[0].typ
+	}
+	types := make([]string, len(results))
+	for i, r := range results {
+		types[i] = r.typ
+	}
+	return " (" + strings.Join(types, ", ") + ")"
+}
+
+// calls is the name of the field recording the calls to m.
+func calls(m parser.Method) string {
+	r := []rune(m.Name)
+	r[0] = unicode.ToLower(r[0])
+	return string(r) + "Calls"
+}
+
+func upperFirst(s string) string {
+	r := []rune(s)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+// doc writes a Go doc comment.
+func (g *generator) doc(comment string) {
+	text := gen.ParseComment(comment).Text
+	if text == "" {
+		return
+	}
+	for _, line := range strings.Split(text, "\n") {
+		g.line("// %s", line)
+	}
+}
@@ -0,0 +1,97 @@
+package fake
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gitamped/fertilize/fertilizetest"
+	"github.com/gitamped/fertilize/gen"
+	"github.com/gitamped/fertilize/parser"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGenerate(t *testing.T) {
+	fertilizetest.Test(t, fertilizetest.Case{
+		Name:      "fake",
+		Renderer:  fertilizetest.Generator(Generate, Options{}),
+		Packages:  []string{"../../examples/testdata/services/..."},
+		Golden:    "testdata/{{.PackageName}}.go.golden",
+		TypeCheck: true,
+	}, *update)
+}
+
+func TestGeneratePackage(t *testing.T) {
+	defs := fertilizetest.Parse(t, "../../examples/testdata/services/kinds").Packages
+	for _, tt := range []struct {
+		opts Options
+		want []string
+	}{
+		{Options{}, []string{"package kindsfake\n", "\t\"github.com/gitamped/fertilize/examples/testdata/services/kinds\"\n", "var _ kinds.StatusService = (*FakeStatusService)(nil)\n"}},
+		{Options{Package: "fakes"}, []string{"package fakes\n", "var _ kinds.StatusService = (*FakeStatusService)(nil)\n"}},
+		// in the service package nothing is qualified with it
+		{Options{Package: "kinds"}, []string{"package kinds\n", "var _ StatusService = (*FakeStatusService)(nil)\n", "func (f *FakeStatusService) Check(p0 CheckRequest, p1 server.GenericRequest) CheckResponse {\n"}},
+	} {
+		b, err := Generate(defs, tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(string(b), want) {
+				t.Errorf("with %+v the fakes don't contain:\n%s\nin:\n%s", tt.opts, want, b)
+			}
+		}
+	}
+
+	// without services there is nothing to import
+	b, err := Generate([]*parser.Definition{{PackageName: "empty"}}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "// Code generated by fertilize; DO NOT EDIT.\n\npackage emptyfake\n"; string(b) != want {
+		t.Errorf("fakes without services = %q, want %q", b, want)
+	}
+}
+
+func TestGenerateImportPath(t *testing.T) {
+	defs := []*parser.Definition{{
+		PackageName: "api",
+		Services:    []parser.Service{{Name: "Things", Methods: []parser.Method{{Name: "Ping"}}}},
+	}}
+	if _, err := Generate(defs, Options{}); err == nil || !strings.Contains(err.Error(), "can't find the import path of package api") {
+		t.Errorf("error without an import path = %v", err)
+	}
+	// declared in the service package, the import path isn't needed
+	if _, err := Generate(defs, Options{Package: "api"}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParams(t *testing.T) {
+	g := &generator{imports: gen.NewImports()}
+	fts := []parser.FieldType{
+		{TypeName: "Request"},
+		{TypeName: "*Request"},
+		{TypeName: "string"},
+		{TypeName: "thing"},
+		{TypeName: "error"},
+	}
+	params := g.params(fts, "", "")
+	want := []param{
+		{name: "Request", typ: "Request"},
+		{name: "Request2", typ: "*Request"},
+		{name: "Arg2", typ: "string"},
+		{name: "Thing", typ: "thing"},
+		{name: "Arg4", typ: "error"},
+	}
+	if !reflect.DeepEqual(params, want) {
+		t.Errorf("params = %+v, want %+v", params, want)
+	}
+	for n, want := range map[int]string{0: "", 1: " Request", 2: " (Request, *Request)"} {
+		if got := resultList(params[:n]); got != want {
+			t.Errorf("resultList of %d results = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,369 @@
+// Package gen holds helpers shared by the built-in generators.
+//
+// The generators live in sub packages and turn the Definitions produced
+// by the parser into files such as OpenAPI documents or client code.
+package gen
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/gitamped/fertilize/parser"
+)
+
+// SeedServerPackage is the import path of the seed server package.
+// Parameters of types from this package, such as server.GenericRequest,
+// are supplied by the server rather than sent by clients.
+const SeedServerPackage = "github.com/gitamped/seed/server"
+
+// Objects indexes the objects of a set of Definitions by name.
+type Objects map[string]*parser.Object
+
+// NewObjects indexes the objects of defs. The parser describes each
+// object once, so names are unique across Definitions.
+func NewObjects(defs []*parser.Definition) Objects {
+	objects := make(Objects)
+	for _, d := range defs {
+		for i := range d.Objects {
+			objects[d.Objects[i].Name] = &d.Objects[i]
+		}
+	}
+	return objects
+}
+
+// Lookup gets the object a Named Type refers to, or nil if it isn't one.
+func (o Objects) Lookup(t *Type) *parser.Object {
+	if t == nil || t.Kind != Named {
+		return nil
+	}
+	return o[t.Name]
+}
+
+// Main gets the package defs describe on its own: the first, when none of
+// the others has services, as when a package is generated alone and the
+// others are only there for their objects. Returns nil otherwise.
+func Main(defs []*parser.Definition) *parser.Definition {
+	if len(defs) == 0 {
+		return nil
+	}
+	for _, d := range defs[1:] {
+		if len(d.Services) > 0 {
+			return nil
+		}
+	}
+	return defs[0]
+}
+
+// IsServerParam reports whether ft is supplied by the server rather than
+// the client, like server.GenericRequest.
+func IsServerParam(ft parser.FieldType) bool {
+	return ft.Package == SeedServerPackage
+}
+
+// Request gets the input object clients send to m: the first input
+// that is an object and not a server parameter. Returns nil if there
+// is none.
+func Request(m parser.Method) *parser.FieldType {
+	for i := range m.InputObjects {
+		if m.InputObjects[i].IsObject && !IsServerParam(m.InputObjects[i]) {
+			return &m.InputObjects[i]
+		}
+	}
+	return nil
+}
+
+// IsSeedMethod reports whether m has the shape of the methods seed
+// servers call, func(Request, server.GenericRequest) Response, where
+// Request is an object.
+func IsSeedMethod(m parser.Method) bool {
+	return len(m.InputObjects) == 2 && len(m.OutputObjects) == 1 &&
+		m.InputObjects[0].IsObject && !IsServerParam(m.InputObjects[0]) &&
+		IsServerParam(m.InputObjects[1]) && m.InputObjects[1].ObjectName == "GenericRequest"
+}
+
+// Response gets the first output of m, or nil if it has none.
+func Response(m parser.Method) *parser.FieldType {
+	if len(m.OutputObjects) == 0 {
+		return nil
+	}
+	return &m.OutputObjects[0]
+}
+
+// JSONField describes how encoding/json treats a struct field.
+type JSONField struct {
+	// Name is the key in the JSON object.
+	Name string
+	// OmitEmpty is set for fields with the omitempty option.
+	OmitEmpty bool
+	// String is set for fields with the string option.
+	String bool
+	// Skip is set for fields that are never encoded.
+	Skip bool
+}
+
+// JSON describes how encoding/json treats f.
+func JSON(f parser.Field) JSONField {
+	jf := JSONField{Name: f.Name}
+	if f.Name == "" || !isExported(f.Name) {
+		jf.Skip = true
+		return jf
+	}
+	tag, ok := f.ParsedTags["json"]
+	if !ok {
+		return jf
+	}
+	if tag.Value == "-" && len(tag.Options) == 0 {
+		jf.Skip = true
+		return jf
+	}
+	if tag.Value != "" {
+		jf.Name = tag.Value
+	}
+	for _, option := range tag.Options {
+		switch option {
+		case "omitempty":
+			jf.OmitEmpty = true
+		case "string":
+			jf.String = true
+		}
+	}
+	return jf
+}
+
+// IsRequired reports whether the validate tag of f, as understood by
+// github.com/go-playground/validator, requires it.
+func IsRequired(f parser.Field) bool {
+	tag, ok := f.ParsedTags["validate"]
+	if !ok {
+		return false
+	}
+	for _, rule := range append([]string{tag.Value}, tag.Options...) {
+		switch rule {
+		case "dive":
+			// rules after dive apply to elements
+			return false
+		case "required":
+			return true
+		}
+	}
+	return false
+}
+
+func isExported(name string) bool {
+	return strings.ToUpper(name[:1]) == name[:1] && name[:1] != "_"
+}
+
+// Comment holds the parts of a doc comment.
+//
+// Lines of the form "key: value" where value is valid JSON are metadata,
+// for example:
+//
+//	// Names are the names of the people to greet.
+//	// example: ["Mat", "David"]
+//	// featured: true
+type Comment struct {
+	// Text is the comment without metadata lines.
+	Text string
+	// Metadata are the values of metadata lines.
+	Metadata map[string]interface{}
+}
+
+// ParseComment splits a comment into its text and metadata.
+func ParseComment(comment string) Comment {
+	c := Comment{Metadata: make(map[string]interface{})}
+	var lines []string
+	for _, line := range strings.Split(comment, "\n") {
+		key, value, ok := strings.Cut(line, ":")
+		key = strings.TrimSpace(key)
+		if ok && isMetadataKey(key) {
+			var v interface{}
+			if err := json.Unmarshal([]byte(strings.TrimSpace(value)), &v); err == nil {
+				c.Metadata[key] = v
+				continue
+			}
+		}
+		lines = append(lines, line)
+	}
+	c.Text = strings.TrimSpace(strings.Join(lines, "\n"))
+	return c
+}
+
+// Summary is the first sentence or line of the comment text.
+func (c Comment) Summary() string {
+	line, _, _ := strings.Cut(c.Text, "\n")
+	if i := strings.Index(line, ". "); i >= 0 {
+		line = line[:i+1]
+	}
+	return line
+}
+
+// Example gets the example metadata value.
+func (c Comment) Example() (interface{}, bool) {
+	v, ok := c.Metadata["example"]
+	return v, ok
+}
+
+// isMetadataKey reports whether key looks like a metadata key: a single
+// word of letters, digits, dashes and underscores.
+func isMetadataKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, r := range key {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Reachable returns the objects clients see: those used by the inputs
+// and outputs of every method in defs, except server parameters, and
+// the objects their fields use in turn. time.Time is encoded as a
+// string, so its fields aren't. They are ordered by name.
+func Reachable(defs []*parser.Definition) []*parser.Object {
+	objects := NewObjects(defs)
+	seen := make(map[string]*parser.Object)
+	var visit func(t *Type)
+	visit = func(t *Type) {
+		t = t.JSON()
+		if t.IsTime() {
+			return
+		}
+		switch t.Kind {
+		case Pointer, Slice:
+			visit(t.Elem)
+		case Map:
+			visit(t.Key)
+			visit(t.Elem)
+		case Named:
+			o := objects.Lookup(t)
+			if o == nil || seen[o.Name] != nil {
+				return
+			}
+			seen[o.Name] = o
+			for _, f := range o.Fields {
+				if !JSON(f).Skip {
+					visit(TypeOf(f.Type))
+				}
+			}
+		}
+	}
+	for _, d := range defs {
+		for _, s := range d.Services {
+			for _, m := range s.Methods {
+				for _, ft := range m.InputObjects {
+					if !IsServerParam(ft) {
+						visit(TypeOf(ft))
+					}
+				}
+				for _, ft := range m.OutputObjects {
+					visit(TypeOf(ft))
+				}
+			}
+		}
+	}
+	reachable := make([]*parser.Object, 0, len(seen))
+	for _, o := range seen {
+		reachable = append(reachable, o)
+	}
+	sort.Slice(reachable, func(i, j int) bool {
+		return reachable[i].Name < reachable[j].Name
+	})
+	return reachable
+}
+
+// Member is a key and value of an Ordered object.
+type Member struct {
+	Key   string
+	Value interface{}
+}
+
+// Ordered is a JSON object that keeps its keys in order.
+type Ordered []Member
+
+// MarshalJSON encodes the fields in order.
+func (o Ordered) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(f.Key)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Sample makes a value of type t for example request bodies. Fields use
+// their example metadata if they have it and a zero value otherwise,
+// except that objects are filled in and slices hold one sample element.
+func Sample(objects Objects, t *Type) interface{} {
+	return sample(objects, t, make(map[string]bool))
+}
+
+func sample(objects Objects, t *Type, seen map[string]bool) interface{} {
+	t = t.JSON()
+	switch {
+	case t.IsBytes():
+		return ""
+	case t.IsTime():
+		return "2006-01-02T15:04:05Z"
+	}
+	switch t.Kind {
+	case Pointer:
+		return sample(objects, t.Elem, seen)
+	case Slice:
+		if v := sample(objects, t.Elem, seen); v != nil {
+			return []interface{}{v}
+		}
+		return []interface{}{}
+	case Map:
+		return map[string]interface{}{}
+	case Basic:
+		switch {
+		case t.Name == "string":
+			return ""
+		case t.Name == "bool":
+			return false
+		case t.IsInteger(), t.IsFloat():
+			return 0
+		}
+	case Named:
+		o := objects.Lookup(t)
+		if o == nil || seen[o.Name] {
+			return nil
+		}
+		seen[o.Name] = true
+		defer delete(seen, o.Name)
+		body := Ordered{}
+		for _, f := range o.Fields {
+			jf := JSON(f)
+			if jf.Skip {
+				continue
+			}
+			v, ok := ParseComment(f.Comment).Example()
+			if !ok {
+				v = sample(objects, TypeOf(f.Type), seen)
+			}
+			body = append(body, Member{Key: jf.Name, Value: v})
+		}
+		return body
+	}
+	return nil
+}
@@ -0,0 +1,312 @@
+// Package goclient generates Go clients for seed servers.
+//
+// For each Service the client has an interface with the same methods,
+// without server parameters like server.GenericRequest and with a
+// context and an error added, and a struct implementing it that POSTs
+// the request object as JSON to <baseURL>/<Service>.<Method> and decodes
+// the response object.
+package goclient
+
+import (
+	"fmt"
+	"go/format"
+	"strings"
+
+	"github.com/gitamped/fertilize/gen"
+	"github.com/gitamped/fertilize/parser"
+)
+
+// Options configure the generated code.
+type Options struct {
+	// Package is the name of the generated package. Defaults to the
+	// name of the first package with client appended.
+	Package string `mapstructure:"package"`
+}
+
+// Generate makes a Go client for the services in defs. The request and
+// response types are those of the service packages, which the client
+// imports.
+func Generate(defs []*parser.Definition, opts Options) ([]byte, error) {
+	if opts.Package == "" {
+		opts.Package = "client"
+		if len(defs) > 0 {
+			opts.Package = defs[0].PackageName + "client"
+		}
+	}
+	g := &generator{imports: gen.NewImports(reserved...)}
+	for _, d := range defs {
+		pkgPath := gen.PackagePath(d)
+		for _, s := range d.Services {
+			if err := g.service(pkgPath, d.PackageName, s); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var out strings.Builder
+	fmt.Fprintln(&out, "// Code generated by fertilize; DO NOT EDIT.")
+	fmt.Fprintln(&out)
+	fmt.Fprintf(&out, "package %s\n", opts.Package)
+	fmt.Fprintln(&out)
+	fmt.Fprintln(&out, "import (")
+	for _, pkg := range []string{"bytes", "context", "encoding/json", "fmt", "io", "net/http", "strings", "time"} {
+		fmt.Fprintf(&out, "\t%q\n", pkg)
+	}
+	if g.imports.Len() > 0 {
+		fmt.Fprintln(&out)
+	}
+	for _, spec := range g.imports.Specs() {
+		fmt.Fprintf(&out, "\t%s\n", spec)
+	}
+	fmt.Fprintln(&out, ")")
+	out.WriteString(clientSource)
+	out.WriteString(g.String())
+	b, err := format.Source([]byte(out.String()))
+	if err != nil {
+		return nil, fmt.Errorf("formatting client: %w", err)
+	}
+	return b, nil
+}
+
+type generator struct {
+	strings.Builder
+	imports *gen.Imports
+}
+
+func (g *generator) line(format string, args ...interface{}) {
+	fmt.Fprintf(g, format, args...)
+	g.WriteByte('\n')
+}
+
+func (g *generator) service(pkgPath, pkgName string, s parser.Service) error {
+	type method struct {
+		name, comment, req, res string
+	}
+	var methods []method
+	for _, m := range s.Methods {
+		mm := method{name: m.Name, comment: m.Comment}
+		if in := gen.Request(m); in != nil {
+			typ, err := g.typ(*in, pkgPath, pkgName)
+			if err != nil {
+				return fmt.Errorf("%s.%s: %w", s.Name, m.Name, err)
+			}
+			mm.req = typ
+		}
+		if out := gen.Response(m); out != nil {
+			typ, err := g.typ(*out, pkgPath, pkgName)
+			if err != nil {
+				return fmt.Errorf("%s.%s: %w", s.Name, m.Name, err)
+			}
+			mm.res = typ
+		}
+		methods = append(methods, mm)
+	}
+	client := s.Name + "Client"
+
+	g.line("")
+	g.doc("", s.Comment)
+	g.line("type %s interface {", s.Name)
+	for _, m := range methods {
+		g.doc("\t", m.comment)
+		g.line("\t%s%s", m.name, signature(m.req, m.res))
+	}
+	g.line("}")
+	g.line("")
+	g.line("// %s is a %s that calls a seed server.", client, s.Name)
+	g.line("type %s struct {", client)
+	g.line("\tclient *Client")
+	g.line("}")
+	g.line("")
+	g.line("var _ %s = (*%s)(nil)", s.Name, client)
+	g.line("")
+	g.line("// New%s makes a %s that sends requests with client.", client, client)
+	g.line("func New%s(client *Client) *%s {", client, client)
+	g.line("\treturn &%s{client: client}", client)
+	g.line("}")
+	for _, m := range methods {
+		g.line("")
+		g.doc("", m.comment)
+		g.line("func (c *%s) %s%s {", client, m.name, signature(m.req, m.res))
+		body := "struct{}{}"
+		if m.req != "" {
+			body = "request"
+		}
+		route := s.Name + "." + m.name
+		if m.res == "" {
+			g.line("\treturn c.client.Do(ctx, %q, %s, nil)", route, body)
+		} else {
+			g.line("\tvar response %s", m.res)
+			g.line("\terr := c.client.Do(ctx, %q, %s, &response)", route, body)
+			g.line("\treturn response, err")
+		}
+		g.line("}")
+	}
+	return nil
+}
+
+// signature formats the parameters and results of a client method.
+func signature(req, res string) string {
+	params := "ctx context.Context"
+	if req != "" {
+		params += ", request " + req
+	}
+	if res == "" {
+		return "(" + params + ") error"
+	}
+	return "(" + params + ") (" + res + ", error)"
+}
+
+// typ formats the type of a request or response, qualifying it with the
+// package it is declared in.
+func (g *generator) typ(ft parser.FieldType, pkgPath, pkgName string) (string, error) {
+	if ft.Package == "" && pkgPath == "" {
+		return "", fmt.Errorf("can't find the import path of %s", ft.TypeName)
+	}
+	return g.imports.Qualify(ft, pkgPath, pkgName), nil
+}
+
+// doc writes a Go doc comment.
+func (g *generator) doc(indent, comment string) {
+	text := gen.ParseComment(comment).Text
+	if text == "" {
+		return
+	}
+	for _, line := range strings.Split(text, "\n") {
+		g.line("%s// %s", indent, line)
+	}
+}
+
+// reserved are names package aliases can't take because the generated
+// code uses them.
+var reserved = []string{
+	"bytes", "context", "json", "fmt", "io", "http", "strings", "time",
+	"client", "ctx", "request", "response", "err",
+}
+
+// clientSource is the Client every service client sends requests with.
+const clientSource = `
+// Client sends requests to a seed server.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	header     http.Header
+	retries    int
+	backoff    time.Duration
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient sets the http.Client requests are sent with.
+// Defaults to http.DefaultClient.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithHeader adds a header to every request.
+func WithHeader(key, value string) Option {
+	return func(c *Client) {
+		c.header.Add(key, value)
+	}
+}
+
+// WithRetries retries requests up to retries times when they fail
+// to send or the server responds with 429 or a 5xx status, waiting
+// backoff before the first retry and doubling it each time.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = retries
+		c.backoff = backoff
+	}
+}
+
+// New makes a Client sending requests to baseURL, the URL services
+// are served under, such as https://api.example.com/v1.
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: http.DefaultClient,
+		header:     make(http.Header),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Error is returned when the server responds with an error.
+type Error struct {
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+	// Message is the error the server responded with.
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Do POSTs request as JSON to <baseURL>/<route> and decodes the
+// response into response, unless it is nil.
+func (c *Client) Do(ctx context.Context, route string, request, response interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("%s: encoding request: %w", route, err)
+	}
+	backoff := c.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := c.do(ctx, route, body, response)
+		if err == nil || !retry || attempt >= c.retries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// do sends a request once, reporting whether a failure is worth retrying.
+func (c *Client) do(ctx context.Context, route string, body []byte, response interface{}) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/"+route, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", route, err)
+	}
+	for key, values := range c.header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, fmt.Errorf("%s: %w", route, err)
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return true, fmt.Errorf("%s: reading response: %w", route, err)
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		apiErr := &Error{StatusCode: res.StatusCode, Message: strings.TrimSpace(string(data))}
+		var errBody struct {
+			Error string ` + "`json:\"error\"`" + `
+		}
+		if json.Unmarshal(data, &errBody) == nil && errBody.Error != "" {
+			apiErr.Message = errBody.Error
+		}
+		retry := res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500
+		return retry, fmt.Errorf("%s: %w", route, apiErr)
+	}
+	if response == nil {
+		return false, nil
+	}
+	if err := json.Unmarshal(data, response); err != nil {
+		return false, fmt.Errorf("%s: decoding response: %w", route, err)
+	}
+	return false, nil
+}
+`
@@ -0,0 +1,106 @@
+package goclient
+
+import (
+	"flag"
+	goparser "go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/gitamped/fertilize/fertilizetest"
+	"github.com/gitamped/fertilize/gen"
+	"github.com/gitamped/fertilize/parser"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGenerate(t *testing.T) {
+	fertilizetest.Test(t, fertilizetest.Case{
+		Name:      "goclient",
+		Renderer:  fertilizetest.Generator(Generate, Options{}),
+		Packages:  []string{"../../examples/testdata/services/..."},
+		Golden:    "testdata/{{.PackageName}}.go.golden",
+		TypeCheck: true,
+	}, *update)
+}
+
+// packageName gets the package clause of the Go source src.
+func packageName(t *testing.T, src []byte) string {
+	t.Helper()
+	f, err := goparser.ParseFile(token.NewFileSet(), "client.go", src, goparser.PackageClauseOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f.Name.Name
+}
+
+func TestGeneratePackage(t *testing.T) {
+	defs := fertilizetest.Parse(t, "../../examples/testdata/services/kinds").Packages
+	for opts, want := range map[Options]string{
+		{}:                        "kindsclient",
+		{Package: "statusclient"}: "statusclient",
+	} {
+		b, err := Generate(defs, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := packageName(t, b); got != want {
+			t.Errorf("package with %+v = %s, want %s", opts, got, want)
+		}
+	}
+	b, err := Generate(nil, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := packageName(t, b); got != "client" {
+		t.Errorf("package without definitions = %s, want client", got)
+	}
+}
+
+// object makes a FieldType referring to the object named name in the
+// package being described.
+func object(name string) parser.FieldType {
+	return parser.FieldType{TypeName: name, ObjectName: name, IsObject: true}
+}
+
+func TestGenerateMethods(t *testing.T) {
+	serverParam := parser.FieldType{TypeName: "server.GenericRequest", Package: gen.SeedServerPackage, IsObject: true}
+	defs := []*parser.Definition{{
+		// called like a package the client imports itself
+		PackageName: "http",
+		PackagePath: "example.com/api/http",
+		Services: []parser.Service{{
+			Name: "Things",
+			Methods: []parser.Method{
+				{Name: "Make", Comment: "Make makes a thing.", InputObjects: []parser.FieldType{object("Request"), serverParam}, OutputObjects: []parser.FieldType{object("Response")}},
+				{Name: "Touch", InputObjects: []parser.FieldType{object("Request")}},
+				{Name: "Ping", InputObjects: []parser.FieldType{serverParam}, OutputObjects: []parser.FieldType{object("*Response")}},
+			},
+		}},
+	}}
+	b, err := Generate(defs, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"\thttp2 \"example.com/api/http\"\n",
+		"\t// Make makes a thing.\n\tMake(ctx context.Context, request http2.Request) (http2.Response, error)\n",
+		// without a response only the error is returned
+		"\tTouch(ctx context.Context, request http2.Request) error\n",
+		"\treturn c.client.Do(ctx, \"Things.Touch\", request, nil)\n",
+		// without a request an empty object is sent
+		"\tPing(ctx context.Context) (*http2.Response, error)\n",
+		"\terr := c.client.Do(ctx, \"Things.Ping\", struct{}{}, &response)\n",
+		"var _ Things = (*ThingsClient)(nil)\n",
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("the client doesn't contain:\n%s\nin:\n%s", want, b)
+		}
+	}
+
+	// the import path of the types can't be found
+	defs[0].PackagePath = ""
+	if _, err := Generate(defs, Options{}); err == nil || !strings.Contains(err.Error(), "can't find the import path of Request") {
+		t.Errorf("error without an import path = %v", err)
+	}
+}
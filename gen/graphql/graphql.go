@@ -0,0 +1,339 @@
+// Package graphql generates GraphQL schemas (SDL).
+//
+// Objects methods take become input types and objects they return become
+// object types, along with the objects their fields use. Each method that
+// takes and returns an object is a field of Query or Mutation taking the
+// request object as its input argument and returning the response
+// object; other methods are left out.
+package graphql
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/gitamped/fertilize/gen"
+	"github.com/gitamped/fertilize/parser"
+)
+
+// Options configure the generated schema.
+type Options struct {
+	// QueryPrefixes are the method name prefixes that make a method a
+	// Query; other methods are Mutations. Defaults to Get and List.
+	// A graphql: "query" or graphql: "mutation" comment line on a
+	// method overrides it.
+	QueryPrefixes []string `mapstructure:"queryPrefixes"`
+}
+
+// Operation types methods can be fields of.
+const (
+	Query    = "Query"
+	Mutation = "Mutation"
+)
+
+// Generate makes a GraphQL schema describing defs.
+func Generate(defs []*parser.Definition, opts Options) ([]byte, error) {
+	if opts.QueryPrefixes == nil {
+		opts.QueryPrefixes = []string{"Get", "List"}
+	}
+	g := &generator{
+		objects: gen.NewObjects(defs),
+		scalars: make(map[string]bool),
+		inputs:  make(map[string]bool),
+		outputs: make(map[string]bool),
+	}
+	g.classify(defs)
+
+	var body strings.Builder
+	fields := make(map[string][]string)
+	owners := make(map[string]string)
+	for _, d := range defs {
+		for _, s := range d.Services {
+			for _, m := range s.Methods {
+				if g.object(gen.Request(m)) == nil || g.object(gen.Response(m)) == nil {
+					continue
+				}
+				op, err := Operation(m, opts)
+				if err != nil {
+					return nil, fmt.Errorf("%s.%s: %w", s.Name, m.Name, err)
+				}
+				name := lowerFirst(m.Name)
+				key := op + "." + name
+				if owner, ok := owners[key]; ok {
+					return nil, fmt.Errorf("%s is defined by both %s and %s.%s", key, owner, s.Name, m.Name)
+				}
+				owners[key] = s.Name + "." + m.Name
+				fields[op] = append(fields[op], g.method(name, m))
+			}
+		}
+	}
+	for _, op := range []string{Query, Mutation} {
+		if len(fields[op]) == 0 {
+			continue
+		}
+		fmt.Fprintf(&body, "\ntype %s {\n", op)
+		body.WriteString(strings.Join(fields[op], "\n"))
+		body.WriteString("}\n")
+	}
+	for _, o := range gen.Reachable(defs) {
+		if g.outputs[o.Name] {
+			if err := g.writeObject(&body, "type", o.Name, o, false); err != nil {
+				return nil, err
+			}
+		}
+		if g.inputs[o.Name] {
+			if err := g.writeObject(&body, "input", g.inputName(o), o, true); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var out strings.Builder
+	out.WriteString("# Code generated by fertilize; DO NOT EDIT.\n")
+	if len(g.scalars) > 0 {
+		out.WriteString("\n")
+		scalars := make([]string, 0, len(g.scalars))
+		for scalar := range g.scalars {
+			scalars = append(scalars, scalar)
+		}
+		sort.Strings(scalars)
+		for _, scalar := range scalars {
+			description(&out, "", scalarDescriptions[scalar])
+			fmt.Fprintf(&out, "scalar %s\n", scalar)
+		}
+	}
+	out.WriteString(body.String())
+	return []byte(out.String()), nil
+}
+
+// Operation gets whether m is a Query or a Mutation.
+func Operation(m parser.Method, opts Options) (string, error) {
+	if v, ok := gen.ParseComment(m.Comment).Metadata["graphql"]; ok {
+		switch v {
+		case "query":
+			return Query, nil
+		case "mutation":
+			return Mutation, nil
+		}
+		return "", fmt.Errorf(`graphql: %v is not "query" or "mutation"`, v)
+	}
+	for _, prefix := range opts.QueryPrefixes {
+		if strings.HasPrefix(m.Name, prefix) {
+			return Query, nil
+		}
+	}
+	return Mutation, nil
+}
+
+type generator struct {
+	objects gen.Objects
+	scalars map[string]bool
+	// inputs and outputs are the names of objects used in requests
+	// and responses.
+	inputs  map[string]bool
+	outputs map[string]bool
+}
+
+// classify finds the objects used as inputs and outputs: those methods
+// take or return, and the objects their fields use.
+func (g *generator) classify(defs []*parser.Definition) {
+	for _, o := range g.objects {
+		if strings.HasPrefix(o.TypeID, gen.SeedServerPackage+".") {
+			// server parameters are not sent by clients
+			continue
+		}
+		t := &gen.Type{Kind: gen.Named, Name: o.Name}
+		for _, d := range defs {
+			if d.ObjectIsInput(o.Name) || d.ObjectIsInput("*"+o.Name) {
+				g.mark(g.inputs, t)
+			}
+			if d.ObjectIsOutput(o.Name) || d.ObjectIsOutput("*"+o.Name) {
+				g.mark(g.outputs, t)
+			}
+		}
+	}
+}
+
+// mark adds the object t refers to, and the objects its fields use, to
+// set.
+func (g *generator) mark(set map[string]bool, t *gen.Type) {
+	switch t.Kind {
+	case gen.Pointer, gen.Slice, gen.Map:
+		g.mark(set, t.Elem)
+	case gen.Named:
+		o := g.objects.Lookup(t)
+		if o == nil || set[o.Name] {
+			return
+		}
+		set[o.Name] = true
+		for _, f := range o.Fields {
+			if !gen.JSON(f).Skip {
+				g.mark(set, gen.TypeOf(f.Type))
+			}
+		}
+	}
+}
+
+// inputName is the name of the input type for o. Objects that are
+// also outputs need a different name for each.
+func (g *generator) inputName(o *parser.Object) string {
+	if g.outputs[o.Name] {
+		return o.Name + "Input"
+	}
+	return o.Name
+}
+
+// object gets the object a method takes or returns as ft. Fields of
+// Query and Mutation take an input object and return an object type,
+// so methods without them are left out. Returns nil if ft is nil or not
+// an object.
+func (g *generator) object(ft *parser.FieldType) *parser.Object {
+	if ft == nil {
+		return nil
+	}
+	return g.objects.Lookup(gen.TypeOf(*ft).Deref())
+}
+
+// method formats the Query or Mutation field for m.
+func (g *generator) method(name string, m parser.Method) string {
+	var sb strings.Builder
+	description(&sb, "  ", gen.ParseComment(m.Comment).Text)
+	in, out := gen.Request(m), gen.Response(m)
+	fmt.Fprintf(&sb, "  %s(input: %s): %s\n", name, nonNull(g.typ(gen.TypeOf(*in), true)), g.typ(gen.TypeOf(*out), false))
+	return sb.String()
+}
+
+var validName = regexp.MustCompile(`^[_A-Za-z][_0-9A-Za-z]*$`)
+
+// writeObject writes o as a type or input named typeName. Types need a
+// field, so objects without any get a placeholder.
+func (g *generator) writeObject(w *strings.Builder, kind, typeName string, o *parser.Object, input bool) error {
+	w.WriteString("\n")
+	description(w, "", gen.ParseComment(o.Comment).Text)
+	fmt.Fprintf(w, "%s %s {\n", kind, typeName)
+	fields := 0
+	for _, f := range o.Fields {
+		jf := gen.JSON(f)
+		if jf.Skip {
+			continue
+		}
+		fields++
+		if !validName.MatchString(jf.Name) || strings.HasPrefix(jf.Name, "__") {
+			return fmt.Errorf("%s.%s: %q is not a valid GraphQL name", o.Name, f.Name, jf.Name)
+		}
+		description(w, "  ", gen.ParseComment(f.Comment).Text)
+		typ := g.typ(gen.TypeOf(f.Type), input)
+		if jf.String {
+			typ = "String!"
+		}
+		if jf.OmitEmpty {
+			typ = strings.TrimSuffix(typ, "!")
+		}
+		fmt.Fprintf(w, "  %s: %s\n", jf.Name, typ)
+	}
+	if fields == 0 {
+		description(w, "  ", placeholderDescription)
+		fmt.Fprintf(w, "  %s: Boolean\n", placeholderField)
+	}
+	w.WriteString("}\n")
+	return nil
+}
+
+// The placeholder field of objects without any, which is always null.
+const (
+	placeholderField       = "_"
+	placeholderDescription = "Always null: the object has no fields, and GraphQL types need one."
+)
+
+// Scalars used for Go types GraphQL has no equivalent of.
+const (
+	timeScalar  = "Time"
+	jsonScalar  = "JSON"
+	int64Scalar = "Int64"
+)
+
+var scalarDescriptions = map[string]string{
+	timeScalar:  "Time is an RFC 3339 date and time.",
+	jsonScalar:  "JSON is any JSON value.",
+	int64Scalar: "Int64 is an integer too big for Int, encoded as a JSON number.",
+}
+
+// typ formats the GraphQL type of the values encoding/json makes from t.
+// Pointers, slices and maps can be null; other values can't.
+func (g *generator) typ(t *gen.Type, input bool) string {
+	t = t.JSON()
+	switch {
+	case t.IsBytes():
+		// base64 encoded
+		return "String"
+	case t.IsTime():
+		g.scalars[timeScalar] = true
+		return timeScalar + "!"
+	}
+	switch t.Kind {
+	case gen.Pointer:
+		return strings.TrimSuffix(g.typ(t.Elem, input), "!")
+	case gen.Slice:
+		return "[" + g.typ(t.Elem, input) + "]"
+	case gen.Basic:
+		switch {
+		case t.Name == "string":
+			return "String!"
+		case t.Name == "bool":
+			return "Boolean!"
+		case t.IsInteger() && fitsInt(t):
+			return "Int!"
+		case t.IsInteger():
+			g.scalars[int64Scalar] = true
+			return int64Scalar + "!"
+		case t.IsFloat():
+			return "Float!"
+		}
+	case gen.Named:
+		if o := g.objects.Lookup(t); o != nil {
+			if input {
+				return g.inputName(o) + "!"
+			}
+			return o.Name + "!"
+		}
+	}
+	// maps, interfaces and anything else
+	g.scalars[jsonScalar] = true
+	return jsonScalar
+}
+
+// fitsInt reports whether the values of the integer type t fit in a
+// GraphQL Int, which is 32 bits and signed. int and uint are 64 bits on
+// the platforms servers run on.
+func fitsInt(t *gen.Type) bool {
+	switch t.Name {
+	case "int8", "int16", "int32", "rune", "uint8", "byte", "uint16":
+		return true
+	}
+	return false
+}
+
+func nonNull(typ string) string {
+	return strings.TrimSuffix(typ, "!") + "!"
+}
+
+// description writes text as a GraphQL block string description.
+func description(w *strings.Builder, indent, text string) {
+	if text == "" {
+		return
+	}
+	text = strings.ReplaceAll(text, `"""`, `\"""`)
+	fmt.Fprintf(w, "%s\"\"\"\n", indent)
+	for _, line := range strings.Split(text, "\n") {
+		fmt.Fprintf(w, "%s%s\n", indent, line)
+	}
+	fmt.Fprintf(w, "%s\"\"\"\n", indent)
+}
+
+func lowerFirst(s string) string {
+	r := []rune(s)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
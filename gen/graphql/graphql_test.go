@@ -0,0 +1,180 @@
+package graphql
+
+import (
+	"flag"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gitamped/fertilize/fertilizetest"
+	"github.com/gitamped/fertilize/gen"
+	"github.com/gitamped/fertilize/parser"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGenerate(t *testing.T) {
+	fertilizetest.Test(t, fertilizetest.Case{
+		Name:     "graphql",
+		Renderer: fertilizetest.Generator(Generate, Options{}),
+		Packages: []string{"../../examples/testdata/services/..."},
+		Golden:   "testdata/{{.PackageName}}.graphql.golden",
+	}, *update)
+}
+
+// field makes a field named name of type typ.
+func field(name, typ string) parser.Field {
+	return parser.Field{Name: name, Type: parser.FieldType{TypeName: typ, ObjectName: typ}}
+}
+
+// object makes a FieldType referring to the object named name.
+func object(name string) parser.FieldType {
+	return parser.FieldType{TypeName: name, ObjectName: name, IsObject: true}
+}
+
+// api describes services whose methods take Request and return Response,
+// which share the Shared object, with methods named by service.
+func api(methods map[string][]string) []*parser.Definition {
+	d := &parser.Definition{
+		PackageName: "api",
+		Objects: []parser.Object{
+			{TypeID: "api.Request", Name: "Request", Fields: []parser.Field{field("Shared", "Shared")}},
+			{TypeID: "api.Response", Name: "Response", Fields: []parser.Field{field("Shared", "Shared")}},
+			{TypeID: "api.Shared", Name: "Shared", Fields: []parser.Field{field("Count", "int")}},
+		},
+	}
+	var services []string
+	for s := range methods {
+		services = append(services, s)
+	}
+	sort.Strings(services)
+	for _, s := range services {
+		service := parser.Service{Name: s}
+		for _, m := range methods[s] {
+			service.Methods = append(service.Methods, parser.Method{
+				Name:          m,
+				InputObjects:  []parser.FieldType{object("Request")},
+				OutputObjects: []parser.FieldType{object("Response")},
+			})
+		}
+		d.Services = append(d.Services, service)
+	}
+	return []*parser.Definition{d}
+}
+
+func TestGenerateQueryPrefixes(t *testing.T) {
+	defs := api(map[string][]string{"Things": {"GetThing", "ListThings", "FindThing", "MakeThing"}})
+	for _, tt := range []struct {
+		prefixes []string
+		want     string
+	}{
+		{nil, "\ntype Query {\n  getThing(input: Request!): Response!\n\n  listThings(input: Request!): Response!\n}\n\ntype Mutation {\n  findThing(input: Request!): Response!\n\n  makeThing(input: Request!): Response!\n}\n"},
+		{[]string{"Find"}, "\ntype Query {\n  findThing(input: Request!): Response!\n}\n\ntype Mutation {\n  getThing(input: Request!): Response!\n\n  listThings(input: Request!): Response!\n\n  makeThing(input: Request!): Response!\n}\n"},
+		// no prefixes make everything a Mutation
+		{[]string{}, "\ntype Mutation {\n  getThing(input: Request!): Response!\n\n  listThings(input: Request!): Response!\n\n  findThing(input: Request!): Response!\n\n  makeThing(input: Request!): Response!\n}\n"},
+	} {
+		b, err := Generate(defs, Options{QueryPrefixes: tt.prefixes})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), tt.want) {
+			t.Errorf("with prefixes %q the schema doesn't contain:\n%s\nin:\n%s", tt.prefixes, tt.want, b)
+		}
+	}
+}
+
+func TestOperation(t *testing.T) {
+	opts := Options{QueryPrefixes: []string{"Get"}}
+	for _, tt := range []struct {
+		m    parser.Method
+		want string
+	}{
+		{parser.Method{Name: "GetThing"}, Query},
+		{parser.Method{Name: "MakeThing"}, Mutation},
+		{parser.Method{Name: "GetThing", Comment: "GetThing counts a view.\ngraphql: \"mutation\""}, Mutation},
+		{parser.Method{Name: "Check", Comment: "Check checks.\ngraphql: \"query\""}, Query},
+	} {
+		got, err := Operation(tt.m, opts)
+		if err != nil || got != tt.want {
+			t.Errorf("Operation(%s) = %q, %v, want %q", tt.m.Name, got, err, tt.want)
+		}
+	}
+	if _, err := Operation(parser.Method{Name: "GetThing", Comment: `graphql: "subscription"`}, opts); err == nil {
+		t.Error("no error for graphql: subscription")
+	}
+}
+
+func TestGenerateConflict(t *testing.T) {
+	_, err := Generate(api(map[string][]string{"Apples": {"GetOne"}, "Pears": {"GetOne"}}), Options{})
+	if err == nil || !strings.Contains(err.Error(), "Query.getOne is defined by both Apples.GetOne and Pears.GetOne") {
+		t.Errorf("error = %v, want one about Query.getOne", err)
+	}
+}
+
+func TestGenerateInputAndOutput(t *testing.T) {
+	b, err := Generate(api(map[string][]string{"Things": {"MakeThing"}}), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Shared is sent and received, so it is a type and an input
+	for _, want := range []string{
+		"\ninput Request {\n  Shared: SharedInput!\n}\n",
+		"\ntype Response {\n  Shared: Shared!\n}\n",
+		"\ntype Shared {\n  Count: Int64!\n}\n",
+		"\ninput SharedInput {\n  Count: Int64!\n}\n",
+		"\nscalar Int64\n",
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("the schema doesn't contain:\n%s\nin:\n%s", want, b)
+		}
+	}
+}
+
+func TestWriteObject(t *testing.T) {
+	g := &generator{objects: gen.Objects{}, scalars: make(map[string]bool)}
+	var w strings.Builder
+	if err := g.writeObject(&w, "type", "Empty", &parser.Object{Name: "Empty"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if want := "\ntype Empty {\n  \"\"\"\n  " + placeholderDescription + "\n  \"\"\"\n  _: Boolean\n}\n"; w.String() != want {
+		t.Errorf("empty object:\n%s\nwant:\n%s", w.String(), want)
+	}
+
+	o := &parser.Object{Name: "Named", Fields: []parser.Field{{
+		Name:       "FirstName",
+		Type:       parser.FieldType{TypeName: "string"},
+		ParsedTags: map[string]parser.FieldTag{"json": {Value: "first-name"}},
+	}}}
+	if err := g.writeObject(&w, "type", "Named", o, false); err == nil {
+		t.Error("no error for the field named first-name")
+	}
+}
+
+func TestType(t *testing.T) {
+	g := &generator{objects: gen.Objects{"Page": &parser.Object{Name: "Page"}}, scalars: make(map[string]bool), outputs: map[string]bool{"Page": true}}
+	for _, tt := range []struct {
+		typ   string
+		input bool
+		want  string
+	}{
+		{"string", false, "String!"},
+		{"*string", false, "String"},
+		{"int32", false, "Int!"},
+		{"int", false, "Int64!"},
+		{"float32", false, "Float!"},
+		{"[]*bool", false, "[Boolean]"},
+		{"[]byte", false, "String"},
+		{"time.Time", false, "Time!"},
+		{"map[string]int", false, "JSON"},
+		{"Page", false, "Page!"},
+		{"*Page", true, "PageInput"},
+	} {
+		if got := g.typ(gen.ParseType(tt.typ), tt.input); got != tt.want {
+			t.Errorf("typ(%s, input %t) = %q, want %q", tt.typ, tt.input, got, tt.want)
+		}
+	}
+	if want := map[string]bool{int64Scalar: true, timeScalar: true, jsonScalar: true}; !reflect.DeepEqual(g.scalars, want) {
+		t.Errorf("scalars = %v, want %v", g.scalars, want)
+	}
+}
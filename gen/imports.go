@@ -0,0 +1,197 @@
+package gen
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/gitamped/fertilize/parser"
+)
+
+// PackagePath gets the import path of the package d describes. For
+// Definitions written before they recorded it, it comes from the TypeIDs
+// of its method and object types. Returns "" if there are none.
+func PackagePath(d *parser.Definition) string {
+	if d.PackagePath != "" {
+		return d.PackagePath
+	}
+	for _, s := range d.Services {
+		for _, m := range s.Methods {
+			for _, ft := range append(append([]parser.FieldType{}, m.InputObjects...), m.OutputObjects...) {
+				if ft.IsObject && ft.Package == "" {
+					return typeIDPackage(ft.TypeID)
+				}
+			}
+		}
+	}
+	for _, o := range d.Objects {
+		if !o.Imported {
+			return typeIDPackage(o.TypeID)
+		}
+	}
+	return ""
+}
+
+func typeIDPackage(typeID string) string {
+	i := strings.LastIndex(typeID, ".")
+	if i < 0 {
+		return ""
+	}
+	return typeID[:i]
+}
+
+// Imports gives each package imported by generated Go code a unique
+// name.
+type Imports struct {
+	aliases map[string]string
+	// names are the package names of the imported packages, where
+	// they are known.
+	names map[string]string
+	used  map[string]bool
+}
+
+// NewImports makes an empty set of imports whose names won't clash with
+// the reserved names, which the generated code uses for other things.
+func NewImports(reserved ...string) *Imports {
+	i := &Imports{
+		aliases: make(map[string]string),
+		names:   make(map[string]string),
+		used:    make(map[string]bool),
+	}
+	for _, name := range reserved {
+		i.used[name] = true
+	}
+	return i
+}
+
+// Add imports pkgPath, the package called name, and returns the name to
+// refer to it by. If name is "" it is guessed from the path.
+func (i *Imports) Add(pkgPath, name string) string {
+	if alias, ok := i.aliases[pkgPath]; ok {
+		return alias
+	}
+	base := name
+	if base == "" {
+		base = PackageName(pkgPath)
+	}
+	alias := base
+	for n := 2; i.used[alias]; n++ {
+		alias = fmt.Sprintf("%s%d", base, n)
+	}
+	i.aliases[pkgPath] = alias
+	i.names[pkgPath] = name
+	i.used[alias] = true
+	return alias
+}
+
+// PackageName guesses the name of the package at pkgPath from its last
+// element, skipping a major version suffix like /v2, for packages whose
+// name isn't known.
+func PackageName(pkgPath string) string {
+	name := path.Base(pkgPath)
+	if dir := path.Dir(pkgPath); dir != "." && isMajorVersion(name) {
+		name = path.Base(dir)
+	}
+	return strings.NewReplacer("-", "", ".", "").Replace(name)
+}
+
+// isMajorVersion reports whether elem is a major version suffix of a
+// module path, like v2.
+func isMajorVersion(elem string) bool {
+	if len(elem) < 2 || elem[0] != 'v' {
+		return false
+	}
+	for _, r := range elem[1:] {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Len is the number of imported packages.
+func (i *Imports) Len() int {
+	return len(i.aliases)
+}
+
+// Specs formats the import specs, ordered by path, naming packages
+// only where the name isn't the last element of the path and the
+// package is known to be called that.
+func (i *Imports) Specs() []string {
+	paths := make([]string, 0, len(i.aliases))
+	for pkgPath := range i.aliases {
+		paths = append(paths, pkgPath)
+	}
+	sort.Strings(paths)
+	specs := make([]string, len(paths))
+	for n, pkgPath := range paths {
+		alias := i.aliases[pkgPath]
+		if name := i.names[pkgPath]; alias == path.Base(pkgPath) && (name == "" || name == alias) {
+			specs[n] = fmt.Sprintf("%q", pkgPath)
+		} else {
+			specs[n] = fmt.Sprintf("%s %q", alias, pkgPath)
+		}
+	}
+	return specs
+}
+
+// Qualify formats the Go type of ft for code in another package,
+// importing the packages it needs. Types without a package are declared
+// in pkgPath, the package called pkgName. If pkgPath is "" they are left
+// unqualified, for code in the same package.
+func (i *Imports) Qualify(ft parser.FieldType, pkgPath, pkgName string) string {
+	t := TypeOf(ft)
+	var qualify func(t *Type)
+	qualify = func(t *Type) {
+		switch t.Kind {
+		case Pointer, Slice:
+			qualify(t.Elem)
+		case Map:
+			qualify(t.Key)
+			qualify(t.Elem)
+		case Named:
+			if t.Name == "error" && t.Package == "" {
+				return
+			}
+			switch {
+			case t.Qualifier != "" && t.Package != "":
+				t.Qualifier = i.Add(t.Package, t.Qualifier)
+			case t.Qualifier != "" && ft.Package != "":
+				// parsed from the TypeName, which only records the
+				// last package it uses
+				t.Qualifier = i.Add(ft.Package, t.Qualifier)
+			case t.Qualifier == "" && pkgPath != "":
+				t.Qualifier = i.Add(pkgPath, pkgName)
+			}
+		}
+	}
+	qualify(t)
+	return t.String()
+}
+
+// GoType formats ft as Go, qualified as in the package declaring it.
+func GoType(ft parser.FieldType) string {
+	return TypeOf(ft).String()
+}
+
+// MethodImports gets the packages the method signatures of the services
+// of d use, keyed by import path, with their names.
+func MethodImports(d *parser.Definition) map[string]string {
+	imports := make(map[string]string)
+	for _, s := range d.Services {
+		for _, m := range s.Methods {
+			for _, ft := range append(append([]parser.FieldType{}, m.InputObjects...), m.OutputObjects...) {
+				if ft.Package == "" {
+					continue
+				}
+				name := d.Imports[ft.Package]
+				if name == "" {
+					name = PackageName(ft.Package)
+				}
+				imports[ft.Package] = name
+			}
+		}
+	}
+	return imports
+}
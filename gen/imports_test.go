@@ -0,0 +1,75 @@
+package gen
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/gitamped/fertilize/parser"
+)
+
+func TestImportsAdd(t *testing.T) {
+	i := NewImports("fmt")
+	tests := []struct {
+		pkgPath, name string
+		want          string
+	}{
+		{pkgPath: "example.com/api", name: "api", want: "api"},
+		{pkgPath: "example.com/api", name: "api", want: "api"},
+		{pkgPath: "example.com/store/v2", name: "store", want: "store"},
+		{pkgPath: "example.com/other/store/v3", name: "store", want: "store2"},
+		{pkgPath: "example.com/guessed/v2", want: "guessed"},
+		{pkgPath: "example.com/go-kit", want: "gokit"},
+		{pkgPath: "example.com/fmt", name: "fmt", want: "fmt2"},
+		{pkgPath: "example.com/dir", name: "renamed", want: "renamed"},
+	}
+	for _, tt := range tests {
+		if got := i.Add(tt.pkgPath, tt.name); got != tt.want {
+			t.Errorf("Add(%q, %q) = %q, want %q", tt.pkgPath, tt.name, got, tt.want)
+		}
+	}
+	want := []string{
+		`"example.com/api"`,
+		`renamed "example.com/dir"`,
+		`fmt2 "example.com/fmt"`,
+		`gokit "example.com/go-kit"`,
+		`guessed "example.com/guessed/v2"`,
+		`store2 "example.com/other/store/v3"`,
+		`store "example.com/store/v2"`,
+	}
+	if got := i.Specs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Specs() = %q, want %q", got, want)
+	}
+}
+
+func TestImportsQualify(t *testing.T) {
+	i := NewImports()
+	ft := parser.FieldType{
+		TypeName: "map[string]store.Item",
+		Package:  "example.com/store/v2",
+		Expr: &parser.TypeExpr{
+			Kind: parser.KindMap,
+			Key:  &parser.TypeExpr{Kind: parser.KindBasic, Name: "string"},
+			Elem: &parser.TypeExpr{
+				Kind:      parser.KindNamed,
+				Name:      "Item",
+				Package:   "example.com/store/v2",
+				Qualifier: "store",
+			},
+		},
+	}
+	if got, want := i.Qualify(ft, "example.com/api/v2", "api"), "map[string]store.Item"; got != want {
+		t.Errorf("Qualify(%s) = %q, want %q", ft.TypeName, got, want)
+	}
+	req := parser.FieldType{
+		TypeName: "Request",
+		IsObject: true,
+		Expr:     &parser.TypeExpr{Kind: parser.KindNamed, Name: "Request", Package: "example.com/api/v2"},
+	}
+	if got, want := i.Qualify(req, "example.com/api/v2", "api"), "api.Request"; got != want {
+		t.Errorf("Qualify(%s) = %q, want %q", req.TypeName, got, want)
+	}
+	want := []string{`api "example.com/api/v2"`, `store "example.com/store/v2"`}
+	if got := i.Specs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Specs() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,109 @@
+package jsonschema
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Reflect makes a schema bundle describing the JSON encoding/json makes
+// from values of the type of v. Each struct type is defined in $defs
+// under its Go name, and the root refers to the definition of v.
+// Objects allow properties that aren't described, so documents with
+// properties added later still validate.
+func Reflect(v interface{}) *Schema {
+	r := &reflector{defs: make(map[string]*Schema)}
+	root := r.typ(reflect.TypeOf(v))
+	root.Schema = Draft
+	root.Defs = r.defs
+	return root
+}
+
+type reflector struct {
+	defs map[string]*Schema
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	marshalerType     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func (r *reflector) typ(t reflect.Type) *Schema {
+	// types that encode themselves, checked like encoding/json does
+	// with their pointers as well
+	switch {
+	case t == timeType:
+		return &Schema{Type: Types{"string"}, Format: "date-time"}
+	case t.Kind() != reflect.Pointer && implements(t, marshalerType):
+		// could be any JSON
+		return &Schema{}
+	case t.Kind() != reflect.Pointer && implements(t, textMarshalerType):
+		return &Schema{Type: Types{"string"}}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return Nullable(r.typ(t.Elem()))
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: Types{"string", "null"}, ContentEncoding: "base64"}
+		}
+		return &Schema{Type: Types{"array", "null"}, Items: r.typ(t.Elem())}
+	case reflect.Array:
+		return &Schema{Type: Types{"array"}, Items: r.typ(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: Types{"object", "null"}, AdditionalProperties: r.typ(t.Elem())}
+	case reflect.String:
+		return &Schema{Type: Types{"string"}}
+	case reflect.Bool:
+		return &Schema{Type: Types{"boolean"}}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: Types{"integer"}}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: Types{"number"}}
+	case reflect.Struct:
+		return r.object(t)
+	}
+	return &Schema{}
+}
+
+// object defines a struct type in $defs and refers to it.
+func (r *reflector) object(t reflect.Type) *Schema {
+	ref := &Schema{Ref: "#/$defs/" + escapePointer(t.Name())}
+	if _, ok := r.defs[t.Name()]; ok {
+		return ref
+	}
+	s := &Schema{
+		Title:      t.Name(),
+		Type:       Types{"object"},
+		Properties: &Properties{},
+	}
+	// define it before the fields so recursive types end
+	r.defs[t.Name()] = s
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" && opts == "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		*s.Properties = append(*s.Properties, Property{Name: name, Schema: r.typ(f.Type)})
+		if !strings.Contains(","+opts+",", ",omitempty,") {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return ref
+}
+
+// implements reports whether t or a pointer to it implements iface.
+func implements(t, iface reflect.Type) bool {
+	return t.Implements(iface) || reflect.PointerTo(t).Implements(iface)
+}
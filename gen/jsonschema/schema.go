@@ -0,0 +1,290 @@
+// Package jsonschema describes Objects with JSON Schema (draft 2020-12).
+package jsonschema
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/gitamped/fertilize/gen"
+	"github.com/gitamped/fertilize/parser"
+)
+
+// Draft is the URI of the JSON Schema dialect used.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema.
+type Schema struct {
+	Schema      string `json:"$schema,omitempty"`
+	ID          string `json:"$id,omitempty"`
+	Ref         string `json:"$ref,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	Type                 Types              `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	ContentEncoding      string             `json:"contentEncoding,omitempty"`
+	Properties           *Properties        `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     *float64           `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	MinProperties        *int               `json:"minProperties,omitempty"`
+	MaxProperties        *int               `json:"maxProperties,omitempty"`
+	Examples             []interface{}      `json:"examples,omitempty"`
+	Defs                 map[string]*Schema `json:"$defs,omitempty"`
+}
+
+// Types is the type keyword. A single type is encoded as a string.
+type Types []string
+
+// MarshalJSON encodes a single type as a string and several as a list.
+func (t Types) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// Property is a named property of an object schema.
+type Property struct {
+	Name   string
+	Schema *Schema
+}
+
+// Properties are the properties of an object schema, kept in
+// declaration order.
+type Properties []Property
+
+// MarshalJSON encodes the properties as an object in declaration order.
+func (p Properties) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, prop := range p {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(prop.Name)
+		if err != nil {
+			return nil, err
+		}
+		schema, err := json.Marshal(prop.Schema)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(schema)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Options configure the generated bundle.
+type Options struct {
+	// ID is the $id of the bundle.
+	ID string `mapstructure:"id"`
+	// Title is the title of the bundle.
+	Title string `mapstructure:"title"`
+}
+
+// Generate makes a schema bundle with a definition in $defs for
+// every object in defs, keyed by TypeID. If only one package is
+// described (see gen.Main), that is its objects and the objects of the
+// other packages they refer to.
+func Generate(defs []*parser.Definition, opts Options) ([]byte, error) {
+	// queue holds the objects still to define, seen those defined or
+	// queued
+	var queue []*parser.Object
+	seen := make(map[string]bool)
+	add := func(o *parser.Object) {
+		if !seen[o.TypeID] {
+			seen[o.TypeID] = true
+			queue = append(queue, o)
+		}
+	}
+	b := NewBuilder(defs, func(o *parser.Object) string {
+		add(o)
+		return "#/$defs/" + escapePointer(o.TypeID)
+	})
+	bundle := &Schema{
+		Schema: Draft,
+		ID:     opts.ID,
+		Title:  opts.Title,
+		Defs:   make(map[string]*Schema),
+	}
+	described := defs
+	if d := gen.Main(defs); d != nil {
+		described = defs[:1]
+	}
+	for _, d := range described {
+		for i := range d.Objects {
+			add(&d.Objects[i])
+		}
+	}
+	for len(queue) > 0 {
+		o := queue[0]
+		queue = queue[1:]
+		bundle.Defs[o.TypeID] = b.Object(o)
+	}
+	out, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// escapePointer escapes a JSON Pointer reference token.
+func escapePointer(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+// Builder makes schemas for the objects of a set of Definitions.
+type Builder struct {
+	objects gen.Objects
+	// Ref makes the reference to the schema of an object.
+	Ref func(o *parser.Object) string
+}
+
+// NewBuilder makes a Builder for the objects in defs, which refers
+// to objects with ref.
+func NewBuilder(defs []*parser.Definition, ref func(o *parser.Object) string) *Builder {
+	return &Builder{
+		objects: gen.NewObjects(defs),
+		Ref:     ref,
+	}
+}
+
+// Object makes the schema of an object.
+func (b *Builder) Object(o *parser.Object) *Schema {
+	comment := gen.ParseComment(o.Comment)
+	s := &Schema{
+		Title:       o.Name,
+		Description: comment.Text,
+		Type:        Types{"object"},
+		Properties:  &Properties{},
+	}
+	if example, ok := comment.Example(); ok {
+		s.Examples = []interface{}{example}
+	}
+	for _, f := range o.Fields {
+		jf := gen.JSON(f)
+		if jf.Skip {
+			continue
+		}
+		*s.Properties = append(*s.Properties, Property{
+			Name:   jf.Name,
+			Schema: b.Field(f),
+		})
+		if !jf.OmitEmpty || gen.IsRequired(f) {
+			s.Required = append(s.Required, jf.Name)
+		}
+	}
+	return s
+}
+
+// Field makes the schema of an object field, including the constraints
+// from its validate tag.
+func (b *Builder) Field(f parser.Field) *Schema {
+	t := gen.TypeOf(f.Type)
+	s := b.Type(t)
+	if gen.JSON(f).String && len(s.Type) > 0 {
+		// the string option quotes numbers and bools
+		s = &Schema{Type: Types{"string"}}
+	}
+	applyRules(s, t, validateRules(f))
+	// since draft 2019-09 annotations may sit next to $ref
+	comment := gen.ParseComment(f.Comment)
+	s.Description = comment.Text
+	if example, ok := comment.Example(); ok {
+		s.Examples = []interface{}{example}
+	}
+	if enum, ok := comment.Metadata["enum"].([]interface{}); ok {
+		s.Enum = enum
+	}
+	return s
+}
+
+// Type makes the schema of a type as encoding/json encodes it.
+func (b *Builder) Type(t *gen.Type) *Schema {
+	t = t.JSON()
+	switch {
+	case t.IsBytes():
+		return &Schema{Type: Types{"string", "null"}, ContentEncoding: "base64"}
+	case t.IsTime():
+		return &Schema{Type: Types{"string"}, Format: "date-time"}
+	}
+	switch t.Kind {
+	case gen.Pointer:
+		return Nullable(b.Type(t.Elem))
+	case gen.Slice:
+		return &Schema{Type: Types{"array", "null"}, Items: b.Type(t.Elem)}
+	case gen.Map:
+		return &Schema{Type: Types{"object", "null"}, AdditionalProperties: b.Type(t.Elem)}
+	case gen.Basic:
+		return basic(t)
+	case gen.Named:
+		if o := b.objects.Lookup(t); o != nil {
+			return &Schema{Ref: b.Ref(o)}
+		}
+	}
+	// interfaces and types whose structure isn't known
+	return &Schema{}
+}
+
+// Nullable makes s also accept null.
+func Nullable(s *Schema) *Schema {
+	switch {
+	case s.Ref != "" || len(s.OneOf) > 0:
+		return &Schema{OneOf: []*Schema{s, {Type: Types{"null"}}}}
+	case len(s.Type) == 0:
+		// already accepts anything
+		return s
+	}
+	for _, typ := range s.Type {
+		if typ == "null" {
+			return s
+		}
+	}
+	s.Type = append(s.Type, "null")
+	return s
+}
+
+func basic(t *gen.Type) *Schema {
+	switch {
+	case t.Name == "bool":
+		return &Schema{Type: Types{"boolean"}}
+	case t.Name == "string":
+		return &Schema{Type: Types{"string"}}
+	case t.IsFloat():
+		format := "double"
+		if t.Name == "float32" {
+			format = "float"
+		}
+		return &Schema{Type: Types{"number"}, Format: format}
+	case t.IsInteger():
+		s := &Schema{Type: Types{"integer"}}
+		switch t.Name {
+		case "int32", "rune", "int8", "int16", "uint8", "byte", "uint16":
+			s.Format = "int32"
+		default:
+			s.Format = "int64"
+		}
+		if t.Name[0] == 'u' || t.Name == "byte" {
+			zero := 0.0
+			s.Minimum = &zero
+		}
+		return s
+	}
+	// complex numbers can't be encoded
+	return &Schema{}
+}
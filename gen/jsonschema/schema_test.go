@@ -0,0 +1,154 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"flag"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gitamped/fertilize/fertilizetest"
+	"github.com/gitamped/fertilize/parser"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+const fixtures = "github.com/gitamped/fertilize/examples/testdata/services"
+
+func TestGenerate(t *testing.T) {
+	fertilizetest.Test(t, fertilizetest.Case{
+		Name:     "jsonschema",
+		Renderer: fertilizetest.Generator(Generate, Options{}),
+		Packages: []string{"../../examples/testdata/services/..."},
+		Golden:   "testdata/{{.PackageName}}.schema.json.golden",
+	}, *update)
+}
+
+// bundle generates the schema bundle of defs.
+func bundle(t *testing.T, defs []*parser.Definition, opts Options) map[string]interface{} {
+	t.Helper()
+	b, err := Generate(defs, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s map[string]interface{}
+	if err := json.Unmarshal(b, &s); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// keys gets the keys of the definitions of bundle in order.
+func keys(bundle map[string]interface{}) []string {
+	var keys []string
+	for key := range bundle["$defs"].(map[string]interface{}) {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestGenerateOptions(t *testing.T) {
+	defs := fertilizetest.Parse(t, "../../examples/testdata/services/kinds").Packages
+	s := bundle(t, defs, Options{ID: "https://example.com/kinds.json", Title: "Kinds"})
+	if s["$schema"] != Draft || s["$id"] != "https://example.com/kinds.json" || s["title"] != "Kinds" {
+		t.Errorf("bundle is %v with $id %v and title %v", s["$schema"], s["$id"], s["title"])
+	}
+	s = bundle(t, defs, Options{})
+	if _, ok := s["$id"]; ok {
+		t.Errorf("bundle without options has $id %v", s["$id"])
+	}
+	if _, ok := s["title"]; ok {
+		t.Errorf("bundle without options has title %v", s["title"])
+	}
+}
+
+// fixtureKeys gets the keys of the definitions in bundle of objects of
+// the fixture packages other than pkgPath.
+func fixtureKeys(bundle map[string]interface{}, pkgPath string) []string {
+	var others []string
+	for _, key := range keys(bundle) {
+		if strings.HasPrefix(key, fixtures) && !strings.HasPrefix(key, pkgPath+".") {
+			others = append(others, key)
+		}
+	}
+	return others
+}
+
+func TestGenerateAlone(t *testing.T) {
+	doc := fertilizetest.Parse(t, "../../examples/testdata/services", "../../examples/testdata/services/kinds", "../../examples/testdata/services/pleasantries")
+	// every object of every package
+	if got := fixtureKeys(bundle(t, doc.Packages, Options{}), fixtures+"/pleasantries"); len(got) != 3 {
+		t.Errorf("the bundle of every package defines %q besides pleasantries, want Page and the objects of kinds", got)
+	}
+	// kinds uses nothing of the others
+	kinds := doc.Package(fixtures + "/kinds")
+	if got := fixtureKeys(bundle(t, doc.Alone(kinds), Options{}), kinds.PackagePath); got != nil {
+		t.Errorf("the bundle of kinds defines %q of other packages", got)
+	}
+	// pleasantries refers to services.Page
+	pleasantries := doc.Package(fixtures + "/pleasantries")
+	want := []string{fixtures + ".Page"}
+	if got := fixtureKeys(bundle(t, doc.Alone(pleasantries), Options{}), pleasantries.PackagePath); !reflect.DeepEqual(got, want) {
+		t.Errorf("the bundle of pleasantries defines %q of other packages, want %q", got, want)
+	}
+}
+
+func TestValidateRules(t *testing.T) {
+	d := fertilizetest.Parse(t, "../../examples/testdata/services/validation").Packages[0]
+	o, err := d.Object("SignupRequest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewBuilder([]*parser.Definition{d}, func(o *parser.Object) string { return o.Name })
+	props := make(map[string]*Schema)
+	for _, p := range *b.Object(o).Properties {
+		props[p.Name] = p.Schema
+	}
+	one, five, eighteen := 1.0, 5.0, 18.0
+	for name, want := range map[string]*Schema{
+		// required strings aren't empty, and required pointers aren't null
+		"Name":  {Type: Types{"string"}, MinLength: intPtr(1)},
+		"Email": {Type: Types{"string"}, Format: "email", MinLength: intPtr(1)},
+		"Age":   {Type: Types{"integer"}, Format: "int64", Minimum: &eighteen, Maximum: floatPtr(130)},
+		// rules on named types constrain the type they are declared as
+		"Status": {Type: Types{"string"}, MinLength: intPtr(1)},
+		"Former": {Type: Types{"string"}, MinLength: intPtr(1)},
+		"Ref":    {Type: Types{"string"}, MinLength: intPtr(8), MaxLength: intPtr(8)},
+		"Count":  {Type: Types{"integer"}, Format: "int64", Minimum: &one, Maximum: &five},
+		"Level":  {Type: Types{"integer"}, Format: "int64", Enum: []interface{}{int64(1), int64(2), int64(3)}},
+		// rules after dive constrain the elements
+		"Counts": {Type: Types{"array", "null"}, MinItems: intPtr(2), MaxItems: intPtr(2), Items: &Schema{Type: Types{"integer"}, Format: "int64", Minimum: &one, Maximum: &five}},
+		"Levels": {Type: Types{"array", "null"}, MaxItems: intPtr(3), Items: &Schema{Type: Types{"integer"}, Format: "int64", Enum: []interface{}{int64(1), int64(2)}}},
+		"Limits": {Type: Types{"object", "null"}, AdditionalProperties: &Schema{Type: Types{"integer"}, Format: "int64", Minimum: &one}},
+	} {
+		if got := props[name]; !reflect.DeepEqual(got, want) {
+			g, _ := json.Marshal(got)
+			w, _ := json.Marshal(want)
+			t.Errorf("%s = %s, want %s", name, g, w)
+		}
+	}
+}
+
+func TestNullable(t *testing.T) {
+	ref := &Schema{Ref: "#/$defs/T"}
+	for _, tt := range []struct {
+		name string
+		s    *Schema
+		want *Schema
+	}{
+		{"type", &Schema{Type: Types{"string"}}, &Schema{Type: Types{"string", "null"}}},
+		{"already nullable", &Schema{Type: Types{"array", "null"}}, &Schema{Type: Types{"array", "null"}}},
+		{"anything", &Schema{}, &Schema{}},
+		{"ref", ref, &Schema{OneOf: []*Schema{ref, {Type: Types{"null"}}}}},
+	} {
+		if got := Nullable(tt.s); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Nullable = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func intPtr(i int) *int { return &i }
+
+func floatPtr(f float64) *float64 { return &f }
@@ -0,0 +1,182 @@
+package jsonschema
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gitamped/fertilize/gen"
+	"github.com/gitamped/fertilize/parser"
+)
+
+// rule is a single validate tag rule, like min=1.
+type rule struct {
+	name  string
+	param string
+}
+
+// validateRules gets the rules in the validate tag of f, as understood
+// by github.com/go-playground/validator.
+func validateRules(f parser.Field) []rule {
+	tag, ok := f.ParsedTags["validate"]
+	if !ok {
+		return nil
+	}
+	var rules []rule
+	for _, r := range append([]string{tag.Value}, tag.Options...) {
+		name, param, _ := strings.Cut(r, "=")
+		if name == "" {
+			continue
+		}
+		rules = append(rules, rule{name: name, param: param})
+	}
+	return rules
+}
+
+// applyRules adds the constraints from rules to s, the schema of t.
+// Rules after dive apply to the elements of slices and maps.
+func applyRules(s *Schema, t *gen.Type, rules []rule) {
+	t = encoded(t)
+	for i, r := range rules {
+		switch r.name {
+		case "dive":
+			if s.Items != nil {
+				applyRules(s.Items, t.Elem, rules[i+1:])
+			} else if s.AdditionalProperties != nil {
+				applyRules(s.AdditionalProperties, t.Elem, rules[i+1:])
+			}
+			return
+		case "required":
+			// required values are never the zero value, and so never null
+			removeNull(s)
+			if t.Kind == gen.Basic && t.Name == "string" {
+				setMin(s, t, "1")
+			}
+		case "min", "gte":
+			setMin(s, t, r.param)
+		case "max", "lte":
+			setMax(s, t, r.param)
+		case "gt":
+			if n, err := strconv.ParseFloat(r.param, 64); err == nil && isNumber(t) {
+				s.ExclusiveMinimum = &n
+			}
+		case "lt":
+			if n, err := strconv.ParseFloat(r.param, 64); err == nil && isNumber(t) {
+				s.ExclusiveMaximum = &n
+			}
+		case "len":
+			setMin(s, t, r.param)
+			setMax(s, t, r.param)
+		case "oneof":
+			s.Enum = nil
+			for _, v := range strings.Fields(r.param) {
+				s.Enum = append(s.Enum, enumValue(t, v))
+			}
+		case "email":
+			s.Format = "email"
+		case "url", "uri":
+			s.Format = "uri"
+		case "uuid", "uuid4":
+			s.Format = "uuid"
+		case "ip":
+			s.Format = "ip"
+		case "ipv4":
+			s.Format = "ipv4"
+		case "ipv6":
+			s.Format = "ipv6"
+		case "hostname":
+			s.Format = "hostname"
+		case "datetime":
+			s.Format = "date-time"
+		}
+	}
+}
+
+// encoded gets the type values of t are encoded as, looking through
+// pointers and named types like type Count int, whose rules constrain
+// the type they are declared as.
+func encoded(t *gen.Type) *gen.Type {
+	for {
+		next := t.Deref().JSON()
+		if next == t {
+			return t
+		}
+		t = next
+	}
+}
+
+// setMin sets the lower bound appropriate for the type.
+func setMin(s *Schema, t *gen.Type, param string) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	switch {
+	case isNumber(t):
+		s.Minimum = &n
+	case t.Kind == gen.Basic && t.Name == "string":
+		i := int(n)
+		s.MinLength = &i
+	case t.Kind == gen.Slice:
+		i := int(n)
+		s.MinItems = &i
+	case t.Kind == gen.Map:
+		i := int(n)
+		s.MinProperties = &i
+	}
+}
+
+// setMax sets the upper bound appropriate for the type.
+func setMax(s *Schema, t *gen.Type, param string) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	switch {
+	case isNumber(t):
+		s.Maximum = &n
+	case t.Kind == gen.Basic && t.Name == "string":
+		i := int(n)
+		s.MaxLength = &i
+	case t.Kind == gen.Slice:
+		i := int(n)
+		s.MaxItems = &i
+	case t.Kind == gen.Map:
+		i := int(n)
+		s.MaxProperties = &i
+	}
+}
+
+func isNumber(t *gen.Type) bool {
+	return t.IsInteger() || t.IsFloat()
+}
+
+// enumValue converts a oneof value to the JSON type of t.
+func enumValue(t *gen.Type, v string) interface{} {
+	switch {
+	case t.IsInteger():
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	case t.IsFloat():
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	}
+	return v
+}
+
+// removeNull stops s accepting null.
+func removeNull(s *Schema) {
+	if len(s.OneOf) == 2 && len(s.OneOf[1].Type) == 1 && s.OneOf[1].Type[0] == "null" {
+		// undo Nullable
+		*s = *s.OneOf[0]
+		return
+	}
+	types := s.Type[:0]
+	for _, typ := range s.Type {
+		if typ != "null" {
+			types = append(types, typ)
+		}
+	}
+	s.Type = types
+}
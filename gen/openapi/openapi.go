@@ -0,0 +1,275 @@
+// Package openapi generates OpenAPI 3.1 documents.
+//
+// Each Service.Method becomes a POST operation on
+// <BasePath><Service>.<Method>, the route seed servers register it on,
+// and every Object clients send or receive becomes a schema under
+// components/schemas.
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/gitamped/fertilize/gen"
+	"github.com/gitamped/fertilize/gen/jsonschema"
+	"github.com/gitamped/fertilize/parser"
+	"gopkg.in/yaml.v3"
+)
+
+// Version is the OpenAPI version documents are written in.
+const Version = "3.1.0"
+
+// Options configure the generated document.
+type Options struct {
+	// Title is the title of the API. Defaults to the name of the
+	// first package.
+	Title string `mapstructure:"title"`
+	// Version is the version of the API. Defaults to 0.0.0.
+	Version string `mapstructure:"version"`
+	// Description describes the API.
+	Description string `mapstructure:"description"`
+	// BasePath is prefixed to each route. Defaults to /v1/, the seed
+	// server default.
+	BasePath string `mapstructure:"basePath"`
+	// Servers are the URLs the API is served from.
+	Servers []string `mapstructure:"servers"`
+	// YAML writes the document as YAML instead of JSON.
+	YAML bool `mapstructure:"yaml"`
+}
+
+// Document is an OpenAPI document.
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Servers    []Server             `json:"servers,omitempty"`
+	Tags       []Tag                `json:"tags,omitempty"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+// Info describes the API.
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Server is a URL the API is served from.
+type Server struct {
+	URL string `json:"url"`
+}
+
+// Tag groups operations; there is one per Service.
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations of a path.
+type PathItem struct {
+	Post *Operation `json:"post,omitempty"`
+}
+
+// Operation describes a Method.
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Tags        []string             `json:"tags,omitempty"`
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// RequestBody describes the input object of a Method.
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response describes the output object of a Method.
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a body.
+type MediaType struct {
+	Schema  *jsonschema.Schema `json:"schema"`
+	Example interface{}        `json:"example,omitempty"`
+}
+
+// Components holds the reusable parts of the document.
+type Components struct {
+	Schemas map[string]*jsonschema.Schema `json:"schemas"`
+}
+
+// Generate makes an OpenAPI document describing defs.
+func Generate(defs []*parser.Definition, opts Options) ([]byte, error) {
+	doc := Build(defs, opts)
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if !opts.YAML {
+		return append(b, '\n'), nil
+	}
+	// JSON is YAML, so decoding it into a node keeps the key order
+	var node yaml.Node
+	if err := yaml.Unmarshal(b, &node); err != nil {
+		return nil, err
+	}
+	clearStyle(&node)
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Build makes the OpenAPI document describing defs.
+func Build(defs []*parser.Definition, opts Options) *Document {
+	if opts.BasePath == "" {
+		opts.BasePath = "/v1/"
+	}
+	if opts.Version == "" {
+		opts.Version = "0.0.0"
+	}
+	if opts.Title == "" && len(defs) > 0 {
+		opts.Title = defs[0].PackageName
+	}
+	doc := &Document{
+		OpenAPI: Version,
+		Info: Info{
+			Title:       opts.Title,
+			Version:     opts.Version,
+			Description: opts.Description,
+		},
+		Paths: make(map[string]*PathItem),
+		Components: Components{
+			Schemas: make(map[string]*jsonschema.Schema),
+		},
+	}
+	for _, url := range opts.Servers {
+		doc.Servers = append(doc.Servers, Server{URL: url})
+	}
+	schemas := jsonschema.NewBuilder(defs, func(o *parser.Object) string {
+		return "#/components/schemas/" + SchemaKey(o.TypeID)
+	})
+	objects := gen.NewObjects(defs)
+	for _, d := range defs {
+		for _, s := range d.Services {
+			comment := gen.ParseComment(s.Comment)
+			doc.Tags = append(doc.Tags, Tag{Name: s.Name, Description: comment.Text})
+			for _, m := range s.Methods {
+				path := opts.BasePath + s.Name + "." + m.Name
+				doc.Paths[path] = &PathItem{Post: operation(s, m, schemas, objects)}
+			}
+		}
+	}
+	for _, o := range gen.Reachable(defs) {
+		doc.Components.Schemas[SchemaKey(o.TypeID)] = schemas.Object(o)
+	}
+	return doc
+}
+
+func operation(s parser.Service, m parser.Method, schemas *jsonschema.Builder, objects gen.Objects) *Operation {
+	comment := gen.ParseComment(m.Comment)
+	op := &Operation{
+		OperationID: s.Name + "." + m.Name,
+		Tags:        []string{s.Name},
+		Summary:     comment.Summary(),
+		Responses:   make(map[string]*Response),
+	}
+	if comment.Text != op.Summary {
+		op.Description = comment.Text
+	}
+	if in := gen.Request(m); in != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content: map[string]*MediaType{
+				"application/json": {
+					Schema:  schemas.Type(gen.TypeOf(*in)),
+					Example: example(objects.Lookup(gen.TypeOf(*in).Deref())),
+				},
+			},
+		}
+	}
+	ok := &Response{Description: "OK"}
+	if out := gen.Response(m); out != nil {
+		ok.Content = map[string]*MediaType{
+			"application/json": {
+				Schema:  schemas.Type(gen.TypeOf(*out)),
+				Example: example(objects.Lookup(gen.TypeOf(*out).Deref())),
+			},
+		}
+	}
+	op.Responses["200"] = ok
+	op.Responses["default"] = &Response{
+		Description: "Error",
+		Content: map[string]*MediaType{
+			"application/json": {Schema: errorSchema()},
+		},
+	}
+	return op
+}
+
+// errorSchema describes the body seed servers respond with on error.
+func errorSchema() *jsonschema.Schema {
+	return &jsonschema.Schema{
+		Type: jsonschema.Types{"object"},
+		Properties: &jsonschema.Properties{
+			{Name: "error", Schema: &jsonschema.Schema{Type: jsonschema.Types{"string"}}},
+		},
+		Required: []string{"error"},
+	}
+}
+
+// example builds an example body for o from the example metadata of its
+// fields. Returns nil if no field has an example.
+func example(o *parser.Object) interface{} {
+	if o == nil {
+		return nil
+	}
+	body := make(map[string]interface{})
+	for _, f := range o.Fields {
+		jf := gen.JSON(f)
+		if jf.Skip {
+			continue
+		}
+		if v, ok := gen.ParseComment(f.Comment).Example(); ok {
+			body[jf.Name] = v
+		}
+	}
+	if len(body) == 0 {
+		return nil
+	}
+	return body
+}
+
+// SchemaKey makes the components/schemas key for a TypeID. Keys may only
+// contain letters, digits, dots, dashes and underscores, so anything else,
+// like the slashes in package paths, becomes an underscore.
+func SchemaKey(typeID string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, typeID)
+}
+
+// clearStyle clears the flow and quoting styles decoding JSON leaves on
+// nodes so the YAML is written in block style, quoting only where needed.
+func clearStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearStyle(child)
+	}
+}
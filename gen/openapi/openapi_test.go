@@ -0,0 +1,118 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"reflect"
+	"testing"
+
+	"github.com/gitamped/fertilize/fertilizetest"
+	"gopkg.in/yaml.v3"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGenerate(t *testing.T) {
+	fertilizetest.Test(t, fertilizetest.Case{
+		Name:     "openapi",
+		Renderer: fertilizetest.Generator(Generate, Options{}),
+		Packages: []string{"../../examples/testdata/services/..."},
+		Golden:   "testdata/{{.PackageName}}.json.golden",
+	}, *update)
+}
+
+func TestBuildOptions(t *testing.T) {
+	defs := fertilizetest.Parse(t, "../../examples/testdata/services/kinds").Packages
+	doc := Build(defs, Options{})
+	if want := (Info{Title: "kinds", Version: "0.0.0"}); doc.Info != want {
+		t.Errorf("default Info = %+v, want %+v", doc.Info, want)
+	}
+	if doc.Paths["/v1/StatusService.Check"] == nil {
+		t.Errorf("no default path for StatusService.Check in %v", reflect.ValueOf(doc.Paths).MapKeys())
+	}
+	if doc.Servers != nil {
+		t.Errorf("default Servers = %v, want none", doc.Servers)
+	}
+
+	doc = Build(defs, Options{
+		Title:       "Status",
+		Version:     "1.2.0",
+		Description: "Status of things.",
+		BasePath:    "/api/",
+		Servers:     []string{"https://a.example.com", "https://b.example.com"},
+	})
+	if want := (Info{Title: "Status", Version: "1.2.0", Description: "Status of things."}); doc.Info != want {
+		t.Errorf("Info = %+v, want %+v", doc.Info, want)
+	}
+	if len(doc.Paths) != 1 || doc.Paths["/api/StatusService.Check"] == nil {
+		t.Errorf("paths = %v, want /api/StatusService.Check", reflect.ValueOf(doc.Paths).MapKeys())
+	}
+	if want := []Server{{URL: "https://a.example.com"}, {URL: "https://b.example.com"}}; !reflect.DeepEqual(doc.Servers, want) {
+		t.Errorf("Servers = %v, want %v", doc.Servers, want)
+	}
+}
+
+func TestBuildOperation(t *testing.T) {
+	defs := fertilizetest.Parse(t, "../../examples/testdata/services/kinds").Packages
+	op := Build(defs, Options{}).Paths["/v1/StatusService.Check"].Post
+	if op.OperationID != "StatusService.Check" || !reflect.DeepEqual(op.Tags, []string{"StatusService"}) {
+		t.Errorf("operation %s tagged %v", op.OperationID, op.Tags)
+	}
+	// the server.GenericRequest parameter isn't sent by clients
+	body := op.RequestBody.Content["application/json"].Schema
+	if want := "#/components/schemas/" + SchemaKey(defs[0].PackagePath+".CheckRequest"); body.Ref != want {
+		t.Errorf("request body refers to %q, want %q", body.Ref, want)
+	}
+	ok := op.Responses["200"].Content["application/json"].Schema
+	if want := "#/components/schemas/" + SchemaKey(defs[0].PackagePath+".CheckResponse"); ok.Ref != want {
+		t.Errorf("response refers to %q, want %q", ok.Ref, want)
+	}
+	if errBody := op.Responses["default"].Content["application/json"].Schema; !reflect.DeepEqual(errBody.Required, []string{"error"}) {
+		t.Errorf("error response requires %v, want error", errBody.Required)
+	}
+}
+
+func TestGenerateYAML(t *testing.T) {
+	defs := fertilizetest.Parse(t, "../../examples/testdata/services/kinds").Packages
+	j, err := Generate(defs, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	y, err := Generate(defs, Options{YAML: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(y, []byte("openapi: "+Version+"\n")) {
+		t.Errorf("YAML doesn't start with the version in block style:\n%s", y)
+	}
+	// both describe the same document
+	var fromJSON, fromYAML interface{}
+	if err := json.Unmarshal(j, &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal(y, &fromYAML); err != nil {
+		t.Fatal(err)
+	}
+	a, _ := json.Marshal(fromJSON)
+	b, err := json.Marshal(fromYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(a, b) {
+		t.Errorf("YAML differs from JSON:\n%s\n%s", a, b)
+	}
+}
+
+func TestSchemaKey(t *testing.T) {
+	for typeID, want := range map[string]string{
+		"example.com/api.Request":      "example.com_api.Request",
+		"example.com/api/v1.Page-2":    "example.com_api_v1.Page-2",
+		"example.com/api.Pair[int]":    "example.com_api.Pair_int_",
+		"example.com/my api.Über_type": "example.com_my_api._ber_type",
+	} {
+		if got := SchemaKey(typeID); got != want {
+			t.Errorf("SchemaKey(%q) = %q, want %q", typeID, got, want)
+		}
+	}
+}
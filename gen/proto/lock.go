@@ -0,0 +1,120 @@
+package proto
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// Lock records the field numbers given to each message so they stay
+// the same as fields are added, removed and reordered. It should be
+// committed next to the generated .proto file.
+type Lock struct {
+	// Messages are keyed by the TypeID of the object.
+	Messages map[string]*MessageLock `json:"messages"`
+}
+
+// MessageLock holds the field numbers of a message.
+type MessageLock struct {
+	// Fields maps field names to numbers.
+	Fields map[string]int `json:"fields"`
+	// Reserved are the fields that have been removed, whose names and
+	// numbers must not be reused.
+	Reserved []Reserved `json:"reserved,omitempty"`
+}
+
+// Reserved is a removed field. Its Name is "" once a field with the
+// same name is added back.
+type Reserved struct {
+	Name   string `json:"name,omitempty"`
+	Number int    `json:"number"`
+}
+
+// ParseLock decodes a lock file. Empty data is an empty lock.
+func ParseLock(data []byte) (*Lock, error) {
+	l := &Lock{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, l); err != nil {
+			return nil, err
+		}
+	}
+	if l.Messages == nil {
+		l.Messages = make(map[string]*MessageLock)
+	}
+	return l, nil
+}
+
+// Marshal encodes the lock file.
+func (l *Lock) Marshal() ([]byte, error) {
+	b, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// number gets the number of a field, assigning the next free one to
+// fields that don't have one yet. A field that was removed and is added
+// back gets a new number too, since its type may have changed; only its
+// name stops being reserved.
+func (m *MessageLock) number(name string) int {
+	if n, ok := m.Fields[name]; ok {
+		return n
+	}
+	for i := range m.Reserved {
+		if m.Reserved[i].Name == name {
+			m.Reserved[i].Name = ""
+		}
+	}
+	n := m.next()
+	m.Fields[name] = n
+	return n
+}
+
+// next gets the lowest number above every used and reserved number,
+// skipping the range protobuf reserves for itself.
+func (m *MessageLock) next() int {
+	max := 0
+	for _, n := range m.Fields {
+		if n > max {
+			max = n
+		}
+	}
+	for _, r := range m.Reserved {
+		if r.Number > max {
+			max = r.Number
+		}
+	}
+	n := max + 1
+	if n >= 19000 && n <= 19999 {
+		n = 20000
+	}
+	return n
+}
+
+// retain reserves every field not in names.
+func (m *MessageLock) retain(names map[string]bool) {
+	var removed []string
+	for name := range m.Fields {
+		if !names[name] {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(removed)
+	for _, name := range removed {
+		m.Reserved = append(m.Reserved, Reserved{Name: name, Number: m.Fields[name]})
+		delete(m.Fields, name)
+	}
+}
+
+// message gets the lock of a message, adding it if it is new.
+func (l *Lock) message(typeID string) *MessageLock {
+	m, ok := l.Messages[typeID]
+	if !ok {
+		m = &MessageLock{Fields: make(map[string]int)}
+		l.Messages[typeID] = m
+	}
+	if m.Fields == nil {
+		m.Fields = make(map[string]int)
+	}
+	return m
+}
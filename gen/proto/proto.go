@@ -0,0 +1,311 @@
+// Package proto generates Protocol Buffers (proto3) definitions.
+//
+// Each Service becomes a service with an rpc per method that takes and
+// returns an object, and each object clients send or receive becomes a
+// message. Field numbers are kept in a
+// Lock so they don't change when fields are reordered or removed.
+package proto
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/gitamped/fertilize/gen"
+	"github.com/gitamped/fertilize/parser"
+)
+
+// Options configure the generated file.
+type Options struct {
+	// Package is the proto package. Defaults to the name of the first
+	// Go package.
+	Package string `mapstructure:"package"`
+	// GoPackage sets the go_package option.
+	GoPackage string `mapstructure:"goPackage"`
+	// LockFile is where field numbers are kept, relative to the
+	// generated file. Defaults to the name of the generated file
+	// with .lock appended.
+	LockFile string `mapstructure:"lockFile"`
+}
+
+// Well known types used for Go types without a direct equivalent.
+const (
+	timestampType = "google.protobuf.Timestamp"
+	valueType     = "google.protobuf.Value"
+)
+
+var wellKnownImports = map[string]string{
+	timestampType: "google/protobuf/timestamp.proto",
+	valueType:     "google/protobuf/struct.proto",
+}
+
+// Generate makes a .proto file describing defs. Field numbers are read
+// from lock, which is updated with the numbers of new fields and the
+// fields that were removed.
+func Generate(defs []*parser.Definition, opts Options, lock *Lock) ([]byte, error) {
+	if opts.Package == "" && len(defs) > 0 {
+		opts.Package = defs[0].PackageName
+	}
+	g := &generator{
+		objects: gen.NewObjects(defs),
+		imports: make(map[string]bool),
+		lock:    lock,
+	}
+	var body strings.Builder
+	for _, d := range defs {
+		for _, s := range d.Services {
+			g.service(&body, s)
+		}
+	}
+	for _, o := range gen.Reachable(defs) {
+		g.message(&body, o)
+	}
+
+	var out strings.Builder
+	fmt.Fprintln(&out, "// Code generated by fertilize; DO NOT EDIT.")
+	fmt.Fprintln(&out)
+	fmt.Fprintln(&out, `syntax = "proto3";`)
+	fmt.Fprintln(&out)
+	fmt.Fprintf(&out, "package %s;\n", opts.Package)
+	if len(g.imports) > 0 {
+		fmt.Fprintln(&out)
+		imports := make([]string, 0, len(g.imports))
+		for path := range g.imports {
+			imports = append(imports, path)
+		}
+		sort.Strings(imports)
+		for _, path := range imports {
+			fmt.Fprintf(&out, "import %q;\n", path)
+		}
+	}
+	if opts.GoPackage != "" {
+		fmt.Fprintln(&out)
+		fmt.Fprintf(&out, "option go_package = %q;\n", opts.GoPackage)
+	}
+	out.WriteString(body.String())
+	return []byte(out.String()), nil
+}
+
+type generator struct {
+	objects gen.Objects
+	imports map[string]bool
+	lock    *Lock
+}
+
+// use records that a well known type is used and returns its name.
+func (g *generator) use(typ string) string {
+	g.imports[wellKnownImports[typ]] = true
+	return typ
+}
+
+func (g *generator) service(w *strings.Builder, s parser.Service) {
+	var methods []parser.Method
+	for _, m := range s.Methods {
+		if g.object(gen.Request(m)) != nil && g.object(gen.Response(m)) != nil {
+			methods = append(methods, m)
+		}
+	}
+	if len(methods) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	comment(w, "", gen.ParseComment(s.Comment))
+	fmt.Fprintf(w, "service %s {\n", s.Name)
+	for i, m := range methods {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		comment(w, "  ", gen.ParseComment(m.Comment))
+		req, res := g.object(gen.Request(m)), g.object(gen.Response(m))
+		fmt.Fprintf(w, "  rpc %s(%s) returns (%s);\n", m.Name, req.Name, res.Name)
+	}
+	fmt.Fprintln(w, "}")
+}
+
+// object gets the object a method takes or returns as ft. rpcs can only
+// use messages, so methods without one are left out. Returns nil if ft
+// is nil or not an object.
+func (g *generator) object(ft *parser.FieldType) *parser.Object {
+	if ft == nil {
+		return nil
+	}
+	return g.objects.Lookup(gen.TypeOf(*ft).Deref())
+}
+
+func (g *generator) message(w *strings.Builder, o *parser.Object) {
+	lock := g.lock.message(o.TypeID)
+	names := make(map[string]bool)
+
+	fmt.Fprintln(w)
+	comment(w, "", gen.ParseComment(o.Comment))
+	fmt.Fprintf(w, "message %s {\n", o.Name)
+	var fields []string
+	for _, f := range o.Fields {
+		jf := gen.JSON(f)
+		if jf.Skip {
+			continue
+		}
+		name := snakeCase(f.Name)
+		names[name] = true
+		var sb strings.Builder
+		comment(&sb, "  ", gen.ParseComment(f.Comment))
+		fmt.Fprintf(&sb, "  %s %s = %d", g.fieldType(gen.TypeOf(f.Type)), name, lock.number(name))
+		if jf.Name != lowerCamel(name) {
+			fmt.Fprintf(&sb, " [json_name = %q]", jf.Name)
+		}
+		sb.WriteString(";\n")
+		fields = append(fields, sb.String())
+	}
+	lock.retain(names)
+	for _, r := range lock.Reserved {
+		fmt.Fprintf(w, "  reserved %d;\n", r.Number)
+		if r.Name != "" {
+			fmt.Fprintf(w, "  reserved %q;\n", r.Name)
+		}
+	}
+	if len(lock.Reserved) > 0 && len(fields) > 0 {
+		fmt.Fprintln(w)
+	}
+	for i, f := range fields {
+		if i > 0 && strings.HasPrefix(strings.TrimSpace(f), "//") {
+			fmt.Fprintln(w)
+		}
+		w.WriteString(f)
+	}
+	fmt.Fprintln(w, "}")
+}
+
+// fieldType gets the type of a message field with its label.
+func (g *generator) fieldType(t *gen.Type) string {
+	t = t.JSON()
+	switch {
+	case t.IsBytes():
+		return "bytes"
+	case t.Kind == gen.Pointer:
+		elem := t.Deref().JSON()
+		typ := g.singular(elem)
+		if elem.Kind == gen.Basic || elem.IsBytes() {
+			// scalars need optional to track presence
+			return "optional " + typ
+		}
+		return typ
+	case t.Kind == gen.Slice:
+		elem := t.Elem.Deref().JSON()
+		if elem.Kind == gen.Slice && !elem.IsBytes() || elem.Kind == gen.Map {
+			// repeated fields can't hold lists or maps
+			return "repeated " + g.use(valueType)
+		}
+		return "repeated " + g.singular(elem)
+	case t.Kind == gen.Map:
+		key, elem := t.Key.JSON(), t.Elem.Deref().JSON()
+		if !validMapKey(key) || elem.Kind == gen.Map || elem.Kind == gen.Slice && !elem.IsBytes() {
+			return g.use(valueType)
+		}
+		return "map<" + g.singular(key) + ", " + g.singular(elem) + ">"
+	}
+	return g.singular(t)
+}
+
+// singular gets the type of a single value.
+func (g *generator) singular(t *gen.Type) string {
+	t = t.JSON()
+	switch {
+	case t.IsBytes():
+		return "bytes"
+	case t.IsTime():
+		return g.use(timestampType)
+	}
+	switch t.Kind {
+	case gen.Basic:
+		if scalar, ok := scalars[t.Name]; ok {
+			return scalar
+		}
+	case gen.Named:
+		if o := g.objects.Lookup(t); o != nil {
+			return o.Name
+		}
+	}
+	return g.use(valueType)
+}
+
+// scalars maps Go basic types to proto3 scalars.
+var scalars = map[string]string{
+	"bool":    "bool",
+	"string":  "string",
+	"int":     "int64",
+	"int8":    "int32",
+	"int16":   "int32",
+	"int32":   "int32",
+	"rune":    "int32",
+	"int64":   "int64",
+	"uint":    "uint64",
+	"uint8":   "uint32",
+	"byte":    "uint32",
+	"uint16":  "uint32",
+	"uint32":  "uint32",
+	"uint64":  "uint64",
+	"uintptr": "uint64",
+	"float32": "float",
+	"float64": "double",
+}
+
+// validMapKey reports whether t can be the key of a proto map: any
+// integral or string scalar.
+func validMapKey(t *gen.Type) bool {
+	if t.Kind != gen.Basic {
+		return false
+	}
+	switch scalars[t.Name] {
+	case "", "float", "double":
+		return false
+	}
+	return true
+}
+
+// comment writes c as a proto comment.
+func comment(w *strings.Builder, indent string, c gen.Comment) {
+	if c.Text == "" {
+		return
+	}
+	for _, line := range strings.Split(c.Text, "\n") {
+		fmt.Fprintf(w, "%s// %s\n", indent, line)
+	}
+}
+
+// snakeCase converts a Go field name to a proto field name, for
+// example CustomerDetails to customer_details and UserID to user_id.
+func snakeCase(s string) string {
+	r := []rune(s)
+	var sb strings.Builder
+	for i, c := range r {
+		if unicode.IsUpper(c) {
+			prevLower := i > 0 && (unicode.IsLower(r[i-1]) || unicode.IsDigit(r[i-1]))
+			nextLower := i > 0 && i+1 < len(r) && unicode.IsLower(r[i+1]) && unicode.IsUpper(r[i-1])
+			if prevLower || nextLower {
+				sb.WriteByte('_')
+			}
+			c = unicode.ToLower(c)
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
+
+// lowerCamel is the JSON name protobuf gives a field by default.
+func lowerCamel(s string) string {
+	var sb strings.Builder
+	upper := false
+	for _, c := range s {
+		if c == '_' {
+			upper = true
+			continue
+		}
+		if upper {
+			c = unicode.ToUpper(c)
+			upper = false
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String()
+}
@@ -0,0 +1,174 @@
+package proto
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gitamped/fertilize/fertilizetest"
+	"github.com/gitamped/fertilize/gen"
+	"github.com/gitamped/fertilize/parser"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// generate generates each package with an empty lock, as the first
+// time it is generated.
+func generate(defs []*parser.Definition, opts Options) ([]byte, error) {
+	lock, err := ParseLock(nil)
+	if err != nil {
+		return nil, err
+	}
+	return Generate(defs, opts, lock)
+}
+
+func TestGenerate(t *testing.T) {
+	fertilizetest.Test(t, fertilizetest.Case{
+		Name:     "proto",
+		Renderer: fertilizetest.Generator(generate, Options{}),
+		Packages: []string{"../../examples/testdata/services/..."},
+		Golden:   "testdata/{{.PackageName}}.proto.golden",
+	}, *update)
+}
+
+func TestGenerateOptions(t *testing.T) {
+	defs := fertilizetest.Parse(t, "../../examples/testdata/services/kinds").Packages
+	for _, tt := range []struct {
+		opts Options
+		want []string
+	}{
+		{Options{}, []string{"\npackage kinds;\n"}},
+		{
+			Options{Package: "status.v1", GoPackage: "example.com/status/v1;statusv1"},
+			[]string{"\npackage status.v1;\n", "\noption go_package = \"example.com/status/v1;statusv1\";\n"},
+		},
+	} {
+		b, err := generate(defs, tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(string(b), want) {
+				t.Errorf("with %+v the file doesn't contain %q:\n%s", tt.opts, want, b)
+			}
+		}
+		if tt.opts.GoPackage == "" && strings.Contains(string(b), "go_package") {
+			t.Errorf("go_package is set without the option:\n%s", b)
+		}
+	}
+}
+
+// object makes an object with string fields named names.
+func object(names ...string) *parser.Object {
+	o := &parser.Object{TypeID: "example.com/api.Thing", Name: "Thing"}
+	for _, name := range names {
+		o.Fields = append(o.Fields, parser.Field{Name: name, Type: parser.FieldType{TypeName: "string"}})
+	}
+	return o
+}
+
+func TestMessageLock(t *testing.T) {
+	lock, err := ParseLock(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	render := func(o *parser.Object) string {
+		g := &generator{objects: gen.Objects{}, imports: make(map[string]bool), lock: lock}
+		var w strings.Builder
+		g.message(&w, o)
+		// the lock is read back as it would be from its file
+		b, err := lock.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lock, err = ParseLock(b); err != nil {
+			t.Fatal(err)
+		}
+		return w.String()
+	}
+	render(object("A", "B", "C"))
+
+	// reordered, with B removed and D added
+	got := render(object("D", "C", "A"))
+	want := "\nmessage Thing {\n  reserved 2;\n  reserved \"b\";\n\n  string d = 4 [json_name = \"D\"];\n  string c = 3 [json_name = \"C\"];\n  string a = 1 [json_name = \"A\"];\n}\n"
+	if got != want {
+		t.Errorf("after removing B:\n%s\nwant:\n%s", got, want)
+	}
+
+	// B is added back with a new number, and its name is free again
+	got = render(object("A", "B", "C", "D"))
+	want = "\nmessage Thing {\n  reserved 2;\n\n  string a = 1 [json_name = \"A\"];\n  string b = 5 [json_name = \"B\"];\n  string c = 3 [json_name = \"C\"];\n  string d = 4 [json_name = \"D\"];\n}\n"
+	if got != want {
+		t.Errorf("after adding B back:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestLockNext(t *testing.T) {
+	for _, tt := range []struct {
+		m    MessageLock
+		want int
+	}{
+		{MessageLock{}, 1},
+		{MessageLock{Fields: map[string]int{"a": 1, "b": 7}}, 8},
+		{MessageLock{Fields: map[string]int{"a": 1}, Reserved: []Reserved{{Number: 9}}}, 10},
+		// protobuf reserves 19000 to 19999 for itself
+		{MessageLock{Fields: map[string]int{"a": 18999}}, 20000},
+	} {
+		if got := tt.m.next(); got != tt.want {
+			t.Errorf("next of %+v = %d, want %d", tt.m, got, tt.want)
+		}
+	}
+}
+
+func TestFieldType(t *testing.T) {
+	g := &generator{objects: gen.Objects{"Page": &parser.Object{Name: "Page"}}, imports: make(map[string]bool)}
+	for typ, want := range map[string]string{
+		"string":           "string",
+		"uint8":            "uint32",
+		"*string":          "optional string",
+		"[]byte":           "bytes",
+		"*[]byte":          "optional bytes",
+		"*Page":            "Page",
+		"[]string":         "repeated string",
+		"[]*Page":          "repeated Page",
+		"[][]int":          "repeated google.protobuf.Value",
+		"map[string]int":   "map<string, int64>",
+		"map[int32]*Page":  "map<int32, Page>",
+		"map[float64]int":  "google.protobuf.Value",
+		"map[string][]int": "google.protobuf.Value",
+		"time.Time":        "google.protobuf.Timestamp",
+		"interface{}":      "google.protobuf.Value",
+	} {
+		if got := g.fieldType(gen.ParseType(typ)); got != want {
+			t.Errorf("fieldType(%s) = %q, want %q", typ, got, want)
+		}
+	}
+	if want := map[string]bool{"google/protobuf/struct.proto": true, "google/protobuf/timestamp.proto": true}; !reflect.DeepEqual(g.imports, want) {
+		t.Errorf("imports = %v, want %v", g.imports, want)
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	for name, want := range map[string]string{
+		"Name":            "name",
+		"CustomerDetails": "customer_details",
+		"UserID":          "user_id",
+		"HTTPServer":      "http_server",
+		"ID":              "id",
+		"Page2Size":       "page2_size",
+	} {
+		if got := snakeCase(name); got != want {
+			t.Errorf("snakeCase(%q) = %q, want %q", name, got, want)
+		}
+	}
+	for name, want := range map[string]string{
+		"name":             "name",
+		"customer_details": "customerDetails",
+		"user_id":          "userId",
+	} {
+		if got := lowerCamel(name); got != want {
+			t.Errorf("lowerCamel(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
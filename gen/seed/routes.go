@@ -0,0 +1,177 @@
+// Package seed generates route registration for seed servers.
+//
+// For each Service it declares a <Service>Handlers interface with the
+// <Method>Handler methods the seed-handlers template generates, which
+// its <Service>Handler types implement, and a Register<Service>Routes
+// function registering each of them with a server.Server as
+// "<Service>.<Method>". The code goes in the service package, next to
+// the handlers. Only methods with the shape seed servers call, as
+// reported by gen.IsSeedMethod, have routes.
+//
+// Routes are configured with comment metadata on the service or method,
+// the method taking precedence:
+//
+//	// roles: ["admin"]
+//	// http: "POST"
+//
+// roles are the roles allowed to call the method, anyone if there are
+// none. http documents the HTTP method, which must be POST as that is
+// all seed servers serve.
+package seed
+
+import (
+	"errors"
+	"fmt"
+	"go/format"
+	"strings"
+
+	"github.com/gitamped/fertilize/gen"
+	"github.com/gitamped/fertilize/parser"
+)
+
+// Options configure the generated code.
+type Options struct{}
+
+// Route is the configuration of a method's route.
+type Route struct {
+	Service string
+	Method  string
+	// Roles are the roles allowed to call the method.
+	Roles []string
+}
+
+// Routes gets the routes of the methods of s that seed servers can
+// call.
+func Routes(s parser.Service) ([]Route, error) {
+	serviceMeta := gen.ParseComment(s.Comment).Metadata
+	defaultRoles, err := roles(serviceMeta)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", s.Name, err)
+	}
+	if err := checkHTTP(serviceMeta); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.Name, err)
+	}
+	routes := make([]Route, 0, len(s.Methods))
+	for _, m := range s.Methods {
+		if !gen.IsSeedMethod(m) {
+			continue
+		}
+		meta := gen.ParseComment(m.Comment).Metadata
+		r := Route{Service: s.Name, Method: m.Name, Roles: defaultRoles}
+		if _, ok := meta["roles"]; ok {
+			if r.Roles, err = roles(meta); err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", s.Name, m.Name, err)
+			}
+		}
+		if err := checkHTTP(meta); err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", s.Name, m.Name, err)
+		}
+		routes = append(routes, r)
+	}
+	return routes, nil
+}
+
+// roles reads the roles metadata: a role or a list of them.
+func roles(meta map[string]interface{}) ([]string, error) {
+	switch v := meta["roles"].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		roles := make([]string, len(v))
+		for i, role := range v {
+			s, ok := role.(string)
+			if !ok {
+				return nil, fmt.Errorf("roles: %v is not a string", role)
+			}
+			roles[i] = s
+		}
+		return roles, nil
+	}
+	return nil, errors.New("roles must be a string or a list of strings")
+}
+
+// checkHTTP checks the http metadata, if there is any, is POST.
+func checkHTTP(meta map[string]interface{}) error {
+	v, ok := meta["http"]
+	if !ok {
+		return nil
+	}
+	method, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("http: %v is not a string", v)
+	}
+	if method = strings.ToUpper(method); method != "POST" {
+		return fmt.Errorf("http: seed servers only serve POST, not %s", method)
+	}
+	return nil
+}
+
+// Generate makes the route registration for the services of the package
+// defs describe, which must be only one (see gen.Main). Services without
+// routes are left out, so if none have any the file only declares the
+// package.
+func Generate(defs []*parser.Definition, opts Options) ([]byte, error) {
+	if len(defs) == 0 {
+		return nil, errors.New("no packages")
+	}
+	d := gen.Main(defs)
+	if d == nil {
+		return nil, errors.New("routes are registered in the package of the services; use an out path with {{.PackageName}} to generate a file per package")
+	}
+	var body strings.Builder
+	for _, s := range d.Services {
+		routes, err := Routes(s)
+		if err != nil {
+			return nil, err
+		}
+		if len(routes) == 0 {
+			continue
+		}
+		writeService(&body, s, routes)
+	}
+	var w strings.Builder
+	fmt.Fprintln(&w, "// Code generated by fertilize; DO NOT EDIT.")
+	fmt.Fprintln(&w)
+	fmt.Fprintf(&w, "package %s\n", d.PackageName)
+	if body.Len() > 0 {
+		fmt.Fprintln(&w)
+		fmt.Fprintf(&w, "import %q\n", gen.SeedServerPackage)
+		w.WriteString(body.String())
+	}
+	b, err := format.Source([]byte(w.String()))
+	if err != nil {
+		return nil, fmt.Errorf("formatting routes: %w", err)
+	}
+	return b, nil
+}
+
+// writeService writes the handlers interface and route registration of
+// s.
+func writeService(w *strings.Builder, s parser.Service, routes []Route) {
+	handlers := s.Name + "Handlers"
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "// %s are the handlers of the methods of %s.\n", handlers, s.Name)
+	fmt.Fprintf(w, "type %s interface {\n", handlers)
+	for _, r := range routes {
+		fmt.Fprintf(w, "\t%sHandler(server.GenericRequest, []byte) (any, error)\n", r.Method)
+	}
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "// Register%sRoutes registers the handlers of %s with s.\n", s.Name, s.Name)
+	fmt.Fprintf(w, "func Register%sRoutes(s *server.Server, h %s) {\n", s.Name, handlers)
+	for _, r := range routes {
+		fmt.Fprintf(w, "\ts.Register(%q, %q, server.RPCEndpoint{\n", r.Service, r.Method)
+		if len(r.Roles) > 0 {
+			quoted := make([]string, len(r.Roles))
+			for i, role := range r.Roles {
+				quoted[i] = fmt.Sprintf("%q", role)
+			}
+			fmt.Fprintf(w, "\t\tRoles: []string{%s},\n", strings.Join(quoted, ", "))
+		}
+		fmt.Fprintf(w, "\t\tHandler: h.%sHandler,\n", r.Method)
+		fmt.Fprintln(w, "\t})")
+	}
+	fmt.Fprintln(w, "}")
+}
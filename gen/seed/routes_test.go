@@ -0,0 +1,126 @@
+package seed
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gitamped/fertilize/fertilizetest"
+	"github.com/gitamped/fertilize/gen"
+	"github.com/gitamped/fertilize/parser"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGenerate(t *testing.T) {
+	fertilizetest.Test(t, fertilizetest.Case{
+		Name:     "seed",
+		Renderer: fertilizetest.Generator(Generate, Options{}),
+		// services has no seed methods, so its file only declares the
+		// package
+		Packages:  []string{"../../examples/testdata/services/..."},
+		Golden:    "testdata/{{.PackageName}}.go.golden",
+		TypeCheck: true,
+	}, *update)
+}
+
+// seedMethod makes a method named name with the shape seed servers call
+// and comment.
+func seedMethod(name, comment string) parser.Method {
+	return parser.Method{
+		Name:    name,
+		Comment: comment,
+		InputObjects: []parser.FieldType{
+			{TypeName: name + "Request", ObjectName: name + "Request", IsObject: true},
+			{TypeName: "server.GenericRequest", ObjectName: "GenericRequest", Package: gen.SeedServerPackage, IsObject: true},
+		},
+		OutputObjects: []parser.FieldType{{TypeName: name + "Response", ObjectName: name + "Response", IsObject: true}},
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	s := parser.Service{
+		Name:    "Things",
+		Comment: "Things are things.\nroles: [\"admin\", \"owner\"]",
+		Methods: []parser.Method{
+			seedMethod("Make", ""),
+			seedMethod("Look", "Look looks at a thing.\nroles: \"viewer\"\nhttp: \"post\""),
+			seedMethod("Open", "roles: []"),
+			// not called by seed servers
+			{Name: "Close", InputObjects: []parser.FieldType{{TypeName: "CloseRequest", IsObject: true}}},
+		},
+	}
+	got, err := Routes(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Route{
+		{Service: "Things", Method: "Make", Roles: []string{"admin", "owner"}},
+		{Service: "Things", Method: "Look", Roles: []string{"viewer"}},
+		{Service: "Things", Method: "Open", Roles: []string{}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Routes = %+v, want %+v", got, want)
+	}
+}
+
+func TestRoutesErrors(t *testing.T) {
+	for _, tt := range []struct {
+		s    parser.Service
+		want string
+	}{
+		{parser.Service{Name: "Things", Comment: "roles: 5"}, "Things: roles must be a string or a list of strings"},
+		{parser.Service{Name: "Things", Methods: []parser.Method{seedMethod("Make", `roles: ["admin", 1]`)}}, "Things.Make: roles: 1 is not a string"},
+		{parser.Service{Name: "Things", Comment: `http: "GET"`}, "Things: http: seed servers only serve POST, not GET"},
+		{parser.Service{Name: "Things", Methods: []parser.Method{seedMethod("Make", "http: true")}}, "Things.Make: http: true is not a string"},
+	} {
+		if _, err := Routes(tt.s); err == nil || err.Error() != tt.want {
+			t.Errorf("Routes error = %v, want %s", err, tt.want)
+		}
+	}
+}
+
+func TestGenerateRoles(t *testing.T) {
+	defs := []*parser.Definition{{
+		PackageName: "api",
+		Services: []parser.Service{
+			{Name: "Things", Methods: []parser.Method{seedMethod("Make", `roles: ["admin"]`), seedMethod("Look", "")}},
+			// without routes, so left out
+			{Name: "Other"},
+		},
+	}}
+	b, err := Generate(defs, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"\ts.Register(\"Things\", \"Make\", server.RPCEndpoint{\n\t\tRoles:   []string{\"admin\"},\n\t\tHandler: h.MakeHandler,\n\t})\n",
+		"\ts.Register(\"Things\", \"Look\", server.RPCEndpoint{\n\t\tHandler: h.LookHandler,\n\t})\n",
+	} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("the routes don't contain:\n%s\nin:\n%s", want, b)
+		}
+	}
+	if strings.Contains(string(b), "Other") {
+		t.Errorf("the service without routes is registered:\n%s", b)
+	}
+}
+
+func TestGeneratePackages(t *testing.T) {
+	doc := fertilizetest.Parse(t, "../../examples/testdata/services/filters", "../../examples/testdata/services/kinds")
+	if _, err := Generate(nil, Options{}); err == nil {
+		t.Error("no error without packages")
+	}
+	if _, err := Generate(doc.Packages, Options{}); err == nil || !strings.Contains(err.Error(), "{{.PackageName}}") {
+		t.Errorf("error for two packages with services = %v, want one suggesting a file per package", err)
+	}
+	// the other packages are only there for their objects
+	b, err := Generate(doc.Alone(doc.Packages[1]), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), "// Code generated by fertilize; DO NOT EDIT.\n\npackage kinds\n") || !strings.Contains(string(b), "func RegisterStatusServiceRoutes(") {
+		t.Errorf("routes of kinds:\n%s", b)
+	}
+}
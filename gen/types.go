@@ -0,0 +1,270 @@
+package gen
+
+import (
+	"strings"
+
+	"github.com/gitamped/fertilize/parser"
+)
+
+// Kind is the kind of a Type.
+type Kind int
+
+const (
+	// Invalid is a type expression that couldn't be understood,
+	// such as a func or chan.
+	Invalid Kind = iota
+	// Basic is a predeclared type like string or int64.
+	Basic
+	// Named is a declared type; an Object if it is a struct.
+	Named
+	// Pointer is a pointer to Elem.
+	Pointer
+	// Slice is a slice or array of Elem.
+	Slice
+	// Map is a map from Key to Elem.
+	Map
+	// Any is an interface type.
+	Any
+)
+
+// Type is a Go type expression from a FieldType.
+type Type struct {
+	Kind Kind
+	// Name is the name of Basic and Named types. Named types from
+	// other packages are qualified with the package name in
+	// Qualifier.
+	Name      string
+	Qualifier string
+	// Package is the import path of Named types, if it is known.
+	Package string
+	// Underlying is the type a Named type that isn't a struct is
+	// declared as, like the string of type Status string, if it is
+	// known.
+	Underlying *Type
+	// Text reports whether a Named type implements
+	// encoding.TextMarshaler.
+	Text bool
+	// Elem is the element type of pointers, slices and maps.
+	Elem *Type
+	// Key is the key type of maps.
+	Key *Type
+}
+
+// TypeOf gets the type of a field or parameter. Types of documents
+// written before FieldTypes had an Expr are parsed from the TypeName.
+func TypeOf(ft parser.FieldType) *Type {
+	var t *Type
+	if ft.Expr != nil {
+		t = typeOfExpr(ft.Expr)
+	} else {
+		t = ParseType(ft.TypeName)
+	}
+	if ft.Multiple {
+		t = &Type{Kind: Slice, Elem: t}
+	}
+	return t
+}
+
+var kinds = map[string]Kind{
+	parser.KindBasic:     Basic,
+	parser.KindNamed:     Named,
+	parser.KindPointer:   Pointer,
+	parser.KindSlice:     Slice,
+	parser.KindMap:       Map,
+	parser.KindInterface: Any,
+}
+
+func typeOfExpr(expr *parser.TypeExpr) *Type {
+	if expr == nil {
+		return nil
+	}
+	return &Type{
+		Kind:       kinds[expr.Kind],
+		Name:       expr.Name,
+		Qualifier:  expr.Qualifier,
+		Package:    expr.Package,
+		Underlying: typeOfExpr(expr.Underlying),
+		Text:       expr.Text,
+		Elem:       typeOfExpr(expr.Elem),
+		Key:        typeOfExpr(expr.Key),
+	}
+}
+
+// ParseType parses a Go type expression as written by go/types, for
+// example "map[string]*services.Page".
+func ParseType(s string) *Type {
+	t, rest := parseType(strings.TrimSpace(s))
+	if rest != "" {
+		return &Type{Kind: Invalid, Name: s}
+	}
+	return t
+}
+
+func parseType(s string) (*Type, string) {
+	switch {
+	case strings.HasPrefix(s, "*"):
+		elem, rest := parseType(s[1:])
+		return &Type{Kind: Pointer, Elem: elem}, rest
+	case strings.HasPrefix(s, "["):
+		end := strings.Index(s, "]")
+		if end < 0 {
+			return &Type{Kind: Invalid, Name: s}, ""
+		}
+		elem, rest := parseType(s[end+1:])
+		return &Type{Kind: Slice, Elem: elem}, rest
+	case strings.HasPrefix(s, "map["):
+		key, rest := parseType(s[len("map["):])
+		if !strings.HasPrefix(rest, "]") {
+			return &Type{Kind: Invalid, Name: s}, ""
+		}
+		elem, rest := parseType(rest[1:])
+		return &Type{Kind: Map, Key: key, Elem: elem}, rest
+	case strings.HasPrefix(s, "interface{"):
+		return &Type{Kind: Any}, s[matchingBrace(s):]
+	case strings.HasPrefix(s, "any") && endOfName(s, len("any")):
+		return &Type{Kind: Any}, s[len("any"):]
+	case strings.HasPrefix(s, "struct{"), strings.HasPrefix(s, "func("), strings.HasPrefix(s, "chan "):
+		return &Type{Kind: Invalid, Name: s}, ""
+	}
+	end := 0
+	for end < len(s) && !strings.ContainsRune("[]{}(),* ", rune(s[end])) {
+		end++
+	}
+	name := s[:end]
+	if name == "" {
+		return &Type{Kind: Invalid, Name: s}, ""
+	}
+	t := &Type{Kind: Named, Name: name}
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		t.Qualifier, t.Name = name[:i], name[i+1:]
+	} else if isBasic(name) {
+		t.Kind = Basic
+	}
+	// skip type arguments of generic types
+	rest := s[end:]
+	if strings.HasPrefix(rest, "[") {
+		depth := 0
+		for i, r := range rest {
+			if r == '[' {
+				depth++
+			} else if r == ']' {
+				depth--
+				if depth == 0 {
+					rest = rest[i+1:]
+					break
+				}
+			}
+		}
+	}
+	return t, rest
+}
+
+// endOfName reports whether s[i:] doesn't continue an identifier.
+func endOfName(s string, i int) bool {
+	if i >= len(s) {
+		return true
+	}
+	return strings.ContainsRune("[]{}(),* ", rune(s[i]))
+}
+
+// matchingBrace returns the index after the brace closing the first
+// brace in s.
+func matchingBrace(s string) int {
+	depth := 0
+	for i, r := range s {
+		switch r {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(s)
+}
+
+// String formats the type as Go source.
+func (t *Type) String() string {
+	switch t.Kind {
+	case Pointer:
+		return "*" + t.Elem.String()
+	case Slice:
+		return "[]" + t.Elem.String()
+	case Map:
+		return "map[" + t.Key.String() + "]" + t.Elem.String()
+	case Any:
+		return "interface{}"
+	case Named:
+		if t.Qualifier != "" {
+			return t.Qualifier + "." + t.Name
+		}
+	}
+	return t.Name
+}
+
+// JSON returns the type encoding/json encodes values of t as: a string
+// for types implementing encoding.TextMarshaler, and the underlying
+// type of other Named types that aren't structs. time.Time, and every
+// other type, is returned as it is.
+func (t *Type) JSON() *Type {
+	switch {
+	case t.Kind != Named || t.IsTime():
+		return t
+	case t.Text:
+		return &Type{Kind: Basic, Name: "string"}
+	case t.Underlying != nil:
+		return t.Underlying
+	}
+	return t
+}
+
+// Deref returns the type a pointer points to, or t itself.
+func (t *Type) Deref() *Type {
+	for t.Kind == Pointer {
+		t = t.Elem
+	}
+	return t
+}
+
+// IsBytes reports whether t is a []byte, which encoding/json encodes
+// as a base64 string.
+func (t *Type) IsBytes() bool {
+	return t.Kind == Slice && t.Elem.Kind == Basic && (t.Elem.Name == "byte" || t.Elem.Name == "uint8")
+}
+
+// IsTime reports whether t is time.Time.
+func (t *Type) IsTime() bool {
+	return t.Kind == Named && t.Qualifier == "time" && t.Name == "Time"
+}
+
+// IsInteger reports whether t is a basic integer type.
+func (t *Type) IsInteger() bool {
+	if t.Kind != Basic {
+		return false
+	}
+	switch t.Name {
+	case "int", "int8", "int16", "int32", "int64",
+		"uint", "uint8", "uint16", "uint32", "uint64", "uintptr",
+		"byte", "rune":
+		return true
+	}
+	return false
+}
+
+// IsFloat reports whether t is a basic floating point type.
+func (t *Type) IsFloat() bool {
+	return t.Kind == Basic && (t.Name == "float32" || t.Name == "float64")
+}
+
+func isBasic(name string) bool {
+	switch name {
+	case "bool", "string",
+		"int", "int8", "int16", "int32", "int64",
+		"uint", "uint8", "uint16", "uint32", "uint64", "uintptr",
+		"byte", "rune", "float32", "float64", "complex64", "complex128":
+		return true
+	}
+	return false
+}
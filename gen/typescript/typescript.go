@@ -0,0 +1,251 @@
+// Package typescript generates TypeScript type definitions and clients.
+//
+// Every object clients send or receive becomes an interface, and every
+// Service becomes a client class whose methods POST the request object
+// to <basePath><Service>.<Method> through a swappable Transport. Methods
+// that don't take a request object are left out, like services without
+// any other methods.
+package typescript
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/gitamped/fertilize/gen"
+	"github.com/gitamped/fertilize/parser"
+)
+
+// Options configure the generated code.
+type Options struct {
+	// BasePath is the default prefix of each route. Defaults to /v1/,
+	// the seed server default.
+	BasePath string `mapstructure:"basePath"`
+}
+
+// Generate makes a TypeScript module describing defs.
+func Generate(defs []*parser.Definition, opts Options) ([]byte, error) {
+	if opts.BasePath == "" {
+		opts.BasePath = "/v1/"
+	}
+	g := &generator{objects: gen.NewObjects(defs)}
+	g.line("// Code generated by fertilize; DO NOT EDIT.")
+	g.line("")
+	g.transport(opts)
+	for _, o := range gen.Reachable(defs) {
+		g.object(o)
+	}
+	for _, d := range defs {
+		for _, s := range d.Services {
+			g.service(s)
+		}
+	}
+	return []byte(g.String()), nil
+}
+
+type generator struct {
+	strings.Builder
+	objects gen.Objects
+}
+
+func (g *generator) line(format string, args ...interface{}) {
+	fmt.Fprintf(g, format, args...)
+	g.WriteByte('\n')
+}
+
+// transport writes the Transport interface and the fetch based default.
+func (g *generator) transport(opts Options) {
+	g.line(`/** Transport sends requests to the server. */`)
+	g.line(`export interface Transport {`)
+	g.line(`	/** post sends body as JSON to path and decodes the JSON response. */`)
+	g.line(`	post<Req, Res>(path: string, body: Req): Promise<Res>;`)
+	g.line(`}`)
+	g.line(``)
+	g.line(`/** APIError is thrown when the server responds with an error. */`)
+	g.line(`export class APIError extends Error {`)
+	g.line(`	constructor(public readonly status: number, message: string) {`)
+	g.line(`		super(message);`)
+	g.line(`		this.name = "APIError";`)
+	g.line(`	}`)
+	g.line(`}`)
+	g.line(``)
+	g.line(`/** FetchTransport is a Transport using the Fetch API. */`)
+	g.line(`export class FetchTransport implements Transport {`)
+	g.line(`	constructor(`)
+	g.line(`		private readonly baseURL: string = "",`)
+	g.line(`		private readonly init: RequestInit = {},`)
+	g.line(`		private readonly fetcher: typeof fetch = (input, init) => fetch(input, init),`)
+	g.line(`	) {}`)
+	g.line(``)
+	g.line(`	async post<Req, Res>(path: string, body: Req): Promise<Res> {`)
+	g.line(`		const res = await this.fetcher(this.baseURL + path, {`)
+	g.line(`			...this.init,`)
+	g.line(`			method: "POST",`)
+	g.line(`			headers: { "Content-Type": "application/json", ...this.init.headers },`)
+	g.line(`			body: JSON.stringify(body),`)
+	g.line(`		});`)
+	g.line(`		if (!res.ok) {`)
+	g.line(`			let message = res.statusText;`)
+	g.line(`			try {`)
+	g.line(`				const err = await res.json();`)
+	g.line(`				if (typeof err?.error === "string") {`)
+	g.line(`					message = err.error;`)
+	g.line(`				}`)
+	g.line(`			} catch {`)
+	g.line(`				// not a JSON error body`)
+	g.line(`			}`)
+	g.line(`			throw new APIError(res.status, message);`)
+	g.line(`		}`)
+	g.line(`		return (await res.json()) as Res;`)
+	g.line(`	}`)
+	g.line(`}`)
+	g.line(``)
+	g.line(`/** defaultBasePath is the prefix of every route. */`)
+	g.line(`export const defaultBasePath = %q;`, opts.BasePath)
+	g.line(``)
+}
+
+// object writes the interface for o.
+func (g *generator) object(o *parser.Object) {
+	g.doc("", gen.ParseComment(o.Comment))
+	g.line("export interface %s {", o.Name)
+	for _, f := range o.Fields {
+		jf := gen.JSON(f)
+		if jf.Skip {
+			continue
+		}
+		g.doc("\t", gen.ParseComment(f.Comment))
+		optional := ""
+		if jf.OmitEmpty {
+			optional = "?"
+		}
+		typ := g.typ(gen.TypeOf(f.Type))
+		if jf.String {
+			typ = "string"
+		}
+		g.line("\t%s%s: %s;", propertyName(jf.Name), optional, typ)
+	}
+	g.line("}")
+	g.line("")
+}
+
+// service writes the client class for s.
+func (g *generator) service(s parser.Service) {
+	var methods []parser.Method
+	for _, m := range s.Methods {
+		if gen.Request(m) != nil {
+			methods = append(methods, m)
+		}
+	}
+	if len(methods) == 0 {
+		return
+	}
+	g.doc("", gen.ParseComment(s.Comment))
+	g.line("export class %sClient {", s.Name)
+	g.line("\tconstructor(")
+	g.line("\t\tprivate readonly transport: Transport = new FetchTransport(),")
+	g.line("\t\tprivate readonly basePath: string = defaultBasePath,")
+	g.line("\t) {}")
+	for _, m := range methods {
+		in, out := gen.Request(m), gen.Response(m)
+		resType := "void"
+		if out != nil {
+			resType = g.typ(gen.TypeOf(*out))
+		}
+		g.line("")
+		g.doc("\t", gen.ParseComment(m.Comment))
+		g.line("\t%s(request: %s): Promise<%s> {", lowerFirst(m.Name), g.typ(gen.TypeOf(*in)), resType)
+		g.line("\t\treturn this.transport.post(this.basePath + %q, request);", s.Name+"."+m.Name)
+		g.line("\t}")
+	}
+	g.line("}")
+	g.line("")
+}
+
+// typ formats the TypeScript type of values encoding/json makes from t.
+func (g *generator) typ(t *gen.Type) string {
+	t = t.JSON()
+	switch {
+	case t.IsBytes():
+		// base64 encoded
+		return "string | null"
+	case t.IsTime():
+		return "string"
+	}
+	switch t.Kind {
+	case gen.Pointer:
+		return nullable(g.typ(t.Elem))
+	case gen.Slice:
+		elem := g.typ(t.Elem)
+		if strings.Contains(elem, " ") {
+			elem = "(" + elem + ")"
+		}
+		return elem + "[] | null"
+	case gen.Map:
+		return "Record<string, " + g.typ(t.Elem) + "> | null"
+	case gen.Basic:
+		switch {
+		case t.Name == "string":
+			return "string"
+		case t.Name == "bool":
+			return "boolean"
+		case t.IsInteger(), t.IsFloat():
+			return "number"
+		}
+	case gen.Named:
+		if o := g.objects.Lookup(t); o != nil {
+			return o.Name
+		}
+	}
+	return "unknown"
+}
+
+// nullable adds null to a type unless it already allows it.
+func nullable(typ string) string {
+	if typ == "unknown" || strings.HasSuffix(typ, "| null") {
+		return typ
+	}
+	return typ + " | null"
+}
+
+// doc writes c as a TSDoc comment.
+func (g *generator) doc(indent string, c gen.Comment) {
+	var lines []string
+	if c.Text != "" {
+		lines = strings.Split(c.Text, "\n")
+	}
+	if example, ok := c.Example(); ok {
+		b, err := json.Marshal(example)
+		if err == nil {
+			lines = append(lines, "@example "+string(b))
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+	g.line("%s/**", indent)
+	for _, line := range lines {
+		line = strings.ReplaceAll(line, "*/", "*\\/")
+		g.line("%s * %s", indent, line)
+	}
+	g.line("%s */", indent)
+}
+
+var identifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// propertyName quotes name if it isn't a valid identifier.
+func propertyName(name string) string {
+	if identifier.MatchString(name) {
+		return name
+	}
+	b, _ := json.Marshal(name)
+	return string(b)
+}
+
+func lowerFirst(s string) string {
+	r := []rune(s)
+	r[0] = unicode.ToLower(r[0])
+	return string(r)
+}
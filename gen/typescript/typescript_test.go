@@ -0,0 +1,118 @@
+package typescript
+
+import (
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/gitamped/fertilize/fertilizetest"
+	"github.com/gitamped/fertilize/gen"
+	"github.com/gitamped/fertilize/parser"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGenerate(t *testing.T) {
+	fertilizetest.Test(t, fertilizetest.Case{
+		Name:     "typescript",
+		Renderer: fertilizetest.Generator(Generate, Options{}),
+		Packages: []string{"../../examples/testdata/services/..."},
+		Golden:   "testdata/{{.PackageName}}.ts.golden",
+	}, *update)
+}
+
+func TestGenerateBasePath(t *testing.T) {
+	defs := fertilizetest.Parse(t, "../../examples/testdata/services/kinds").Packages
+	for basePath, want := range map[string]string{
+		"":      `export const defaultBasePath = "/v1/";`,
+		"/api/": `export const defaultBasePath = "/api/";`,
+	} {
+		b, err := Generate(defs, Options{BasePath: basePath})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), want+"\n") {
+			t.Errorf("with base path %q the module doesn't contain %s", basePath, want)
+		}
+	}
+}
+
+func TestType(t *testing.T) {
+	g := &generator{objects: gen.Objects{"Page": &parser.Object{Name: "Page"}}}
+	for typ, want := range map[string]string{
+		"string":              "string",
+		"bool":                "boolean",
+		"uint8":               "number",
+		"float32":             "number",
+		"*string":             "string | null",
+		"**string":            "string | null",
+		"[]*int":              "(number | null)[] | null",
+		"[]byte":              "string | null",
+		"map[string][]string": "Record<string, string[] | null> | null",
+		"time.Time":           "string",
+		"*time.Time":          "string | null",
+		"Page":                "Page",
+		"[]Page":              "Page[] | null",
+		"Unknown":             "unknown",
+		"*interface{}":        "unknown",
+		"complex128":          "unknown",
+	} {
+		if got := g.typ(gen.ParseType(typ)); got != want {
+			t.Errorf("typ(%s) = %q, want %q", typ, got, want)
+		}
+	}
+}
+
+func TestService(t *testing.T) {
+	request := parser.FieldType{TypeName: "Request", ObjectName: "Request", IsObject: true}
+	serverParam := parser.FieldType{TypeName: "server.GenericRequest", Package: gen.SeedServerPackage, IsObject: true}
+	g := &generator{objects: gen.Objects{"Request": &parser.Object{Name: "Request"}}}
+	g.service(parser.Service{
+		Name: "Things",
+		Methods: []parser.Method{
+			{Name: "Touch", InputObjects: []parser.FieldType{request, serverParam}},
+			// clients can't call methods without a request object
+			{Name: "Ping", InputObjects: []parser.FieldType{serverParam}},
+		},
+	})
+	got := g.String()
+	if !strings.Contains(got, "\ttouch(request: Request): Promise<void> {\n") {
+		t.Errorf("the method without a response doesn't resolve to void:\n%s", got)
+	}
+	if strings.Contains(got, "ping") {
+		t.Errorf("the method without a request is in the client:\n%s", got)
+	}
+
+	g.Reset()
+	g.service(parser.Service{
+		Name:    "Pinger",
+		Methods: []parser.Method{{Name: "Ping", InputObjects: []parser.FieldType{serverParam}}},
+	})
+	if g.Len() != 0 {
+		t.Errorf("a client was written for a service without requests:\n%s", g.String())
+	}
+}
+
+func TestDoc(t *testing.T) {
+	g := &generator{}
+	g.doc("\t", gen.ParseComment("Ends a comment */ early.\nexample: {\"a\": 1}"))
+	want := "\t/**\n\t * Ends a comment *\\/ early.\n\t * @example {\"a\":1}\n\t */\n"
+	if got := g.String(); got != want {
+		t.Errorf("doc = %q, want %q", got, want)
+	}
+}
+
+func TestPropertyName(t *testing.T) {
+	for name, want := range map[string]string{
+		"Name":       "Name",
+		"$ref":       "$ref",
+		"first_name": "first_name",
+		"first-name": `"first-name"`,
+		"1st":        `"1st"`,
+		"":           `""`,
+	} {
+		if got := propertyName(name); got != want {
+			t.Errorf("propertyName(%q) = %s, want %s", name, got, want)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package main
 
+//go:generate go run . schema --out schema.json
+
 import (
 	"github.com/gitamped/fertilize/cmd"
 )
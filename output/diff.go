@@ -0,0 +1,246 @@
+package output
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+// edit is a single line of a line based diff.
+type edit struct {
+	// op is ' ' for unchanged lines, '-' for removed and '+' for added.
+	op   byte
+	line string
+}
+
+// UnifiedDiff returns a unified diff turning a into b, or an empty
+// string if they are the same.
+func UnifiedDiff(aName, bName string, a, b []byte) string {
+	if string(a) == string(b) {
+		return ""
+	}
+	edits := diffLines(splitLines(string(a)), splitLines(string(b)))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", aName, bName)
+	for start := 0; start < len(edits); {
+		// find the next change
+		for start < len(edits) && edits[start].op == ' ' {
+			start++
+		}
+		if start == len(edits) {
+			break
+		}
+		// extend the hunk until there is a run of unchanged
+		// lines long enough to separate it from the next change
+		end := start
+		for end < len(edits) {
+			if edits[end].op != ' ' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(edits) && edits[run].op == ' ' {
+				run++
+			}
+			if run == len(edits) || run-end > 2*diffContext {
+				break
+			}
+			end = run
+		}
+		from := start - diffContext
+		if from < 0 {
+			from = 0
+		}
+		to := end + diffContext
+		if to > len(edits) {
+			to = len(edits)
+		}
+		writeHunk(&sb, edits, from, to)
+		start = to
+	}
+	return sb.String()
+}
+
+// writeHunk writes edits[from:to] as a hunk with its header.
+func writeHunk(sb *strings.Builder, edits []edit, from, to int) {
+	aStart, bStart := 1, 1
+	for _, e := range edits[:from] {
+		if e.op != '+' {
+			aStart++
+		}
+		if e.op != '-' {
+			bStart++
+		}
+	}
+	var aLen, bLen int
+	for _, e := range edits[from:to] {
+		if e.op != '+' {
+			aLen++
+		}
+		if e.op != '-' {
+			bLen++
+		}
+	}
+	if aLen == 0 {
+		aStart--
+	}
+	if bLen == 0 {
+		bStart--
+	}
+	fmt.Fprintf(sb, "@@ -%d,%d +%d,%d @@\n", aStart, aLen, bStart, bLen)
+	for _, e := range edits[from:to] {
+		sb.WriteByte(e.op)
+		sb.WriteString(e.line)
+		if !strings.HasSuffix(e.line, "\n") {
+			sb.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// diffLines computes the edits turning a into b with Myers' O(ND)
+// algorithm, in linear space by bisecting on the middle snake.
+func diffLines(a, b []string) []edit {
+	edits := make([]edit, 0, len(a)+len(b))
+	return diffRange(edits, a, b)
+}
+
+// diffRange appends the edits turning a into b to edits.
+func diffRange(edits []edit, a, b []string) []edit {
+	// generated files mostly change in the middle, so the common
+	// prefix and suffix are trimmed before searching
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	for _, line := range a[:prefix] {
+		edits = append(edits, edit{' ', line})
+	}
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	switch {
+	case len(midA) == 0:
+		for _, line := range midB {
+			edits = append(edits, edit{'+', line})
+		}
+	case len(midB) == 0:
+		for _, line := range midA {
+			edits = append(edits, edit{'-', line})
+		}
+	default:
+		x, y, ok := middleSnake(midA, midB)
+		if !ok {
+			for _, line := range midA {
+				edits = append(edits, edit{'-', line})
+			}
+			for _, line := range midB {
+				edits = append(edits, edit{'+', line})
+			}
+			break
+		}
+		edits = diffRange(edits, midA[:x], midB[:y])
+		edits = diffRange(edits, midA[x:], midB[y:])
+	}
+	for _, line := range a[len(a)-suffix:] {
+		edits = append(edits, edit{' ', line})
+	}
+	return edits
+}
+
+// middleSnake finds where the forward and backward searches for the
+// shortest edit script of a and b meet, which splits it in two. a and b
+// must be non-empty. ok is false when they have no line in common.
+func middleSnake(a, b []string) (x, y int, ok bool) {
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	offset := maxD
+	// vf[offset+k] is the furthest x reached on diagonal k searching
+	// forwards, vb the same searching backwards from the ends
+	vf := make([]int, 2*maxD+2)
+	vb := make([]int, 2*maxD+2)
+	for i := range vf {
+		vf[i], vb[i] = -1, -1
+	}
+	vf[offset+1], vb[offset+1] = 0, 0
+	delta := n - m
+	// with an odd delta the forward search finds the overlap,
+	// otherwise the backward one
+	front := delta%2 != 0
+	var fStart, fEnd, bStart, bEnd int
+	for d := 0; d < maxD; d++ {
+		for k := -d + fStart; k <= d-fEnd; k += 2 {
+			i := offset + k
+			var x1 int
+			if k == -d || (k != d && vf[i-1] < vf[i+1]) {
+				x1 = vf[i+1]
+			} else {
+				x1 = vf[i-1] + 1
+			}
+			y1 := x1 - k
+			for x1 < n && y1 < m && a[x1] == b[y1] {
+				x1++
+				y1++
+			}
+			vf[i] = x1
+			switch {
+			case x1 > n:
+				fEnd += 2
+			case y1 > m:
+				fStart += 2
+			case front:
+				j := offset + delta - k
+				if j >= 0 && j < len(vb) && vb[j] != -1 && x1 >= n-vb[j] {
+					return x1, y1, true
+				}
+			}
+		}
+		for k := -d + bStart; k <= d-bEnd; k += 2 {
+			i := offset + k
+			var x2 int
+			if k == -d || (k != d && vb[i-1] < vb[i+1]) {
+				x2 = vb[i+1]
+			} else {
+				x2 = vb[i-1] + 1
+			}
+			y2 := x2 - k
+			for x2 < n && y2 < m && a[n-x2-1] == b[m-y2-1] {
+				x2++
+				y2++
+			}
+			vb[i] = x2
+			switch {
+			case x2 > n:
+				bEnd += 2
+			case y2 > m:
+				bStart += 2
+			case !front:
+				j := offset + delta - k
+				if j >= 0 && j < len(vf) && vf[j] != -1 {
+					x1 := vf[j]
+					y1 := offset + x1 - j
+					if x1 >= n-x2 {
+						return x1, y1, true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// splitLines splits s into lines, keeping the line endings.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,98 @@
+package output
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	a := []byte("one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nten\n")
+	b := []byte("one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\nnine\nTEN\neleven")
+	want := `--- a
++++ b
+@@ -7,4 +7,5 @@
+ seven
+ eight
+ nine
+-ten
++TEN
++eleven
+\ No newline at end of file
+`
+	if got := UnifiedDiff("a", "b", a, b); got != want {
+		t.Errorf("UnifiedDiff =\n%s\nwant\n%s", got, want)
+	}
+	if got := UnifiedDiff("a", "b", a, a); got != "" {
+		t.Errorf("UnifiedDiff of equal files = %q, want nothing", got)
+	}
+}
+
+// lcsLen is the length of the longest common subsequence of a and b.
+func lcsLen(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	for i := range a {
+		cur := make([]int, len(b)+1)
+		for j := range b {
+			switch {
+			case a[i] == b[j]:
+				cur[j+1] = prev[j] + 1
+			case prev[j+1] >= cur[j]:
+				cur[j+1] = prev[j+1]
+			default:
+				cur[j+1] = cur[j]
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func randomLines(r *rand.Rand, n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = string(rune('a'+r.Intn(4))) + "\n"
+	}
+	return lines
+}
+
+func TestDiffLinesMinimal(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		a, b := randomLines(r, r.Intn(20)), randomLines(r, r.Intn(20))
+		edits := diffLines(a, b)
+		var gotA, gotB []string
+		changes := 0
+		for _, e := range edits {
+			if e.op != '+' {
+				gotA = append(gotA, e.line)
+			}
+			if e.op != '-' {
+				gotB = append(gotB, e.line)
+			}
+			if e.op != ' ' {
+				changes++
+			}
+		}
+		if strings.Join(gotA, "") != strings.Join(a, "") || strings.Join(gotB, "") != strings.Join(b, "") {
+			t.Fatalf("edits of %q to %q don't reproduce them: %q", a, b, edits)
+		}
+		if want := len(a) + len(b) - 2*lcsLen(a, b); changes != want {
+			t.Fatalf("%d changes turning %q into %q, want %d", changes, a, b, want)
+		}
+	}
+}
+
+func TestDiffLinesLarge(t *testing.T) {
+	// a quadratic table for these would need about 800MB
+	a := make([]string, 10000)
+	for i := range a {
+		a[i] = strings.Repeat("x", i%7) + "\n"
+	}
+	b := append(append([]string{}, a[:5000]...), "changed\n")
+	b = append(b, a[5001:]...)
+	edits := diffLines(a, b)
+	if len(edits) != len(a)+1 {
+		t.Errorf("%d edits, want %d", len(edits), len(a)+1)
+	}
+}
@@ -0,0 +1,66 @@
+// Package output writes generated files.
+package output
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// createTemp and rename are variables so the tests can make writing
+// fail.
+var (
+	createTemp = os.CreateTemp
+	rename     = os.Rename
+)
+
+// WriteFile writes data to path, leaving the file untouched if it
+// already holds exactly data so modification times only change when
+// the content does.
+//
+// The data is written to a temporary file in the same directory which
+// then replaces path, so readers never see a partially written file.
+// New files get perm, existing files keep their mode. Reports whether
+// the file was written.
+func WriteFile(path string, data []byte, perm fs.FileMode) (bool, error) {
+	current, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if bytes.Equal(current, data) {
+			return false, nil
+		}
+		if info, err := os.Stat(path); err == nil {
+			perm = info.Mode().Perm()
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return false, err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return false, err
+	}
+	tmp, err := createTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return false, err
+	}
+	// clean up after any failure below; once renamed this is a no-op
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return false, err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return false, err
+	}
+	if err := tmp.Close(); err != nil {
+		return false, err
+	}
+	if err := rename(tmp.Name(), path); err != nil {
+		return false, err
+	}
+	return true, nil
+}
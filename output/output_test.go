@@ -0,0 +1,150 @@
+package output
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// readFile reads the file at path, failing the test if it can't.
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// checkOnly checks that dir only holds the files names.
+func checkOnly(t *testing.T, dir string, names ...string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+	if !reflect.DeepEqual(got, names) {
+		t.Errorf("%s holds %q, want %q", dir, got, names)
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "gen", "api.go")
+	written, err := WriteFile(path, []byte("package api\n"), 0640)
+	if err != nil || !written {
+		t.Fatalf("WriteFile of a new file = %v, %v, want it written", written, err)
+	}
+	if got := readFile(t, path); got != "package api\n" {
+		t.Errorf("content = %q", got)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("new file mode = %v, want %v", info.Mode().Perm(), fs.FileMode(0640))
+	}
+	checkOnly(t, filepath.Dir(path), "api.go")
+}
+
+func TestWriteFileUnchanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.go")
+	if err := os.WriteFile(path, []byte("package api\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	written, err := WriteFile(path, []byte("package api\n"), 0644)
+	if err != nil || written {
+		t.Fatalf("WriteFile of the same content = %v, %v, want it skipped", written, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(old) {
+		t.Errorf("modification time = %v, want it kept at %v", info.ModTime(), old)
+	}
+}
+
+func TestWriteFileKeepsMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0750); err != nil {
+		t.Fatal(err)
+	}
+	written, err := WriteFile(path, []byte("#!/bin/sh\necho hi\n"), 0644)
+	if err != nil || !written {
+		t.Fatalf("WriteFile = %v, %v, want it written", written, err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0750 {
+		t.Errorf("mode = %v, want the existing %v", info.Mode().Perm(), fs.FileMode(0750))
+	}
+}
+
+func TestWriteFileFailures(t *testing.T) {
+	errRename := errors.New("rename failed")
+	tests := []struct {
+		name string
+		// createTemp and rename replace those of the package.
+		createTemp func(dir, pattern string) (*os.File, error)
+		rename     func(oldpath, newpath string) error
+	}{
+		{
+			name: "write",
+			// the temporary file is opened read only, so writing
+			// to it fails
+			createTemp: func(dir, pattern string) (*os.File, error) {
+				f, err := os.CreateTemp(dir, pattern)
+				if err != nil {
+					return nil, err
+				}
+				f.Close()
+				return os.Open(f.Name())
+			},
+			rename: os.Rename,
+		},
+		{
+			name:       "rename",
+			createTemp: os.CreateTemp,
+			rename:     func(oldpath, newpath string) error { return errRename },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			createTemp, rename = tt.createTemp, tt.rename
+			t.Cleanup(func() { createTemp, rename = os.CreateTemp, os.Rename })
+			dir := t.TempDir()
+			path := filepath.Join(dir, "api.go")
+			if err := os.WriteFile(path, []byte("package old\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			written, err := WriteFile(path, []byte("package api\n"), 0644)
+			if err == nil || written {
+				t.Fatalf("WriteFile = %v, %v, want an error", written, err)
+			}
+			if got := readFile(t, path); got != "package old\n" {
+				t.Errorf("content = %q, want the old content", got)
+			}
+			// the temporary file is removed
+			checkOnly(t, dir, "api.go")
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package parser
+
+import (
+	"sort"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// Cache keeps what a Parser found in each package, so packages that
+// haven't changed aren't loaded and parsed again. Invalidate the
+// packages whose sources changed before parsing again. A Cache is for
+// one Parser configuration; the zero Cache is empty.
+type Cache struct {
+	// packages are the parsed packages by import path, and order
+	// their import paths in the order they were loaded.
+	packages map[string]*parsedPackage
+	order    []string
+	// stale are the import paths of the packages to parse again.
+	stale map[string]bool
+}
+
+// parsedPackage is what parsing a package on its own found, before
+// objects are shared between packages and those only excluded services
+// use are dropped.
+type parsedPackage struct {
+	def *Definition
+	// excluded are the services of the interfaces left out.
+	excluded    []Service
+	diagnostics []Diagnostic
+	// module is the main module, if the package is in it.
+	module *Module
+	// imports are the import paths of the parsed packages the
+	// package imports, directly or not. It describes the objects of
+	// those it uses, so it is parsed again when they change.
+	imports map[string]bool
+}
+
+// Invalidate marks the packages with the import paths pkgPaths, and the
+// parsed packages importing them, to be parsed again.
+func (c *Cache) Invalidate(pkgPaths ...string) {
+	if c.stale == nil {
+		c.stale = make(map[string]bool)
+	}
+	for _, changed := range pkgPaths {
+		c.stale[changed] = true
+		for path, pp := range c.packages {
+			if pp.imports[changed] {
+				c.stale[path] = true
+			}
+		}
+	}
+}
+
+// patterns gets the patterns to load: every one the first time, and
+// then the import paths of the stale packages.
+func (c *Cache) patterns(all []string) []string {
+	if c.packages == nil {
+		return all
+	}
+	paths := make([]string, 0, len(c.stale))
+	for path := range c.stale {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// roots gets the import paths of the packages parsed: those loaded along
+// with those parsed before.
+func (c *Cache) roots(pkgs []*packages.Package) map[string]bool {
+	roots := make(map[string]bool, len(c.order)+len(pkgs))
+	for _, path := range c.order {
+		roots[path] = true
+	}
+	for _, pkg := range pkgs {
+		roots[pkg.PkgPath] = true
+	}
+	return roots
+}
+
+// importsOf gets the import paths of the packages in roots that pkg
+// imports, directly or not.
+func importsOf(pkg *packages.Package, roots map[string]bool) map[string]bool {
+	imports := make(map[string]bool)
+	seen := make(map[string]bool)
+	var visit func(pkg *packages.Package)
+	visit = func(pkg *packages.Package) {
+		for _, imp := range pkg.Imports {
+			if seen[imp.PkgPath] {
+				continue
+			}
+			seen[imp.PkgPath] = true
+			if roots[imp.PkgPath] {
+				imports[imp.PkgPath] = true
+			}
+			visit(imp)
+		}
+	}
+	visit(pkg)
+	return imports
+}
+
+// update keeps the packages parsed, in the order they were loaded in
+// if they are new, and forgets they were stale.
+func (c *Cache) update(parsed map[string]*parsedPackage, order []string) {
+	if c.packages == nil {
+		c.packages = make(map[string]*parsedPackage, len(parsed))
+	}
+	for _, path := range order {
+		if _, ok := c.packages[path]; !ok {
+			c.order = append(c.order, path)
+		}
+		c.packages[path] = parsed[path]
+	}
+	c.stale = nil
+}
+
+// document fills in the packages of doc and their diagnostics from the
+// packages in c, or those parsed instead, in the order they were loaded.
+// Each object is described by the first package that found it, as if
+// they were parsed together. Returns the services left out.
+func (c *Cache) document(doc *Document, parsed map[string]*parsedPackage, order []string) []Service {
+	paths := append([]string{}, c.order...)
+	for _, path := range order {
+		if _, ok := c.packages[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	doc.Diagnostics = []Diagnostic{}
+	doc.Dropped = []Dropped{}
+	doc.Packages = make([]*Definition, 0, len(paths))
+	var excluded []Service
+	described := make(map[string]bool)
+	for _, path := range paths {
+		pp, ok := parsed[path]
+		if !ok {
+			pp = c.packages[path]
+		}
+		if pp.module != nil && doc.Module == nil {
+			doc.Module = pp.module
+		}
+		doc.Diagnostics = append(doc.Diagnostics, pp.diagnostics...)
+		excluded = append(excluded, pp.excluded...)
+		// the Definition is copied so changes to the Document don't
+		// change the cache
+		d := *pp.def
+		d.Services = append([]Service(nil), pp.def.Services...)
+		d.Objects = nil
+		for _, obj := range pp.def.Objects {
+			if !described[obj.Name] {
+				described[obj.Name] = true
+				d.Objects = append(d.Objects, obj)
+			}
+		}
+		doc.Packages = append(doc.Packages, &d)
+	}
+	return excluded
+}
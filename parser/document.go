@@ -0,0 +1,163 @@
+package parser
+
+import (
+	"runtime/debug"
+	"sort"
+	"time"
+)
+
+// SchemaVersion is the version of the JSON form of Document, as described
+// by the JSON Schema fertilize schema prints. The minor version goes up
+// when properties are added, which consumers must ignore if they don't
+// know them. The major version goes up when properties are removed or
+// renamed, or their types or meaning change.
+const SchemaVersion = "2.3"
+
+// modulePath is the path of the fertilize module, for finding its
+// version in the build info.
+const modulePath = "github.com/gitamped/fertilize"
+
+// Document describes the packages a Parser parsed and how they were
+// parsed.
+type Document struct {
+	// SchemaVersion is the SchemaVersion the document was written with.
+	SchemaVersion string `json:"schemaVersion"`
+	// FertilizeVersion is the version of fertilize that parsed the
+	// packages, or "(devel)" if it isn't known.
+	FertilizeVersion string `json:"fertilizeVersion"`
+	// Generated is when the packages were parsed. It is left out of
+	// output that should only change when the packages do.
+	Generated *time.Time `json:"generated,omitempty"`
+	// Module is the main module the packages were loaded in, if any.
+	Module *Module `json:"module,omitempty"`
+	// Options are the options the packages were parsed with.
+	Options Options `json:"options"`
+	// Diagnostics are the problems found loading the packages. Packages
+	// with errors are still described, as far as they could be.
+	Diagnostics []Diagnostic `json:"diagnostics"`
+	// Dropped are the objects that were left out because none of the
+	// services described use them.
+	Dropped []Dropped `json:"dropped"`
+	// Packages are the Definitions of the packages ordered by import
+	// path.
+	Packages []*Definition `json:"packages"`
+}
+
+// Module describes a Go module.
+type Module struct {
+	// Path is the module path.
+	Path string `json:"path"`
+	// GoVersion is the version in the go directive of the go.mod file.
+	GoVersion string `json:"goVersion"`
+}
+
+// Options are the options of a Parser.
+type Options struct {
+	// Patterns are the package patterns that were loaded.
+	Patterns []string `json:"patterns"`
+	// ExcludeInterfaces are the interfaces that were left out.
+	ExcludeInterfaces []string `json:"excludeInterfaces"`
+	// Filter selected the interfaces and structs described.
+	Filter Filter `json:"filter"`
+}
+
+// Diagnostic is a problem found loading a package.
+type Diagnostic struct {
+	// Package is the import path of the package.
+	Package string `json:"package"`
+	// Position is where the problem is, as file:line:column, if known.
+	Position string `json:"position,omitempty"`
+	// Message describes the problem.
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	if d.Position == "" {
+		return d.Package + ": " + d.Message
+	}
+	return d.Position + ": " + d.Message
+}
+
+// Dropped is an object left out of a Document.
+type Dropped struct {
+	// Package is the import path of the package the object would have
+	// been described in.
+	Package string `json:"package"`
+	// TypeID is the TypeID of the object.
+	TypeID string `json:"typeID"`
+	// Reason says why the object was left out.
+	Reason string `json:"reason"`
+}
+
+func (d Dropped) String() string {
+	return d.TypeID + ": " + d.Reason
+}
+
+// Package gets the Definition of the package with the import path
+// pkgPath, or nil if there is none.
+func (d *Document) Package(pkgPath string) *Definition {
+	i := sort.Search(len(d.Packages), func(i int) bool {
+		return d.Packages[i].PackagePath >= pkgPath
+	})
+	if i < len(d.Packages) && d.Packages[i].PackagePath == pkgPath {
+		return d.Packages[i]
+	}
+	return nil
+}
+
+// Map gets the Definitions of the packages keyed by import path.
+func (d *Document) Map() map[string]*Definition {
+	def := make(map[string]*Definition, len(d.Packages))
+	for _, pkg := range d.Packages {
+		def[pkg.PackagePath] = pkg
+	}
+	return def
+}
+
+// Alone gets the Definitions to generate pkg on its own with: pkg,
+// followed by the other packages without their services, so the objects
+// of theirs pkg uses are still found.
+func (d *Document) Alone(pkg *Definition) []*Definition {
+	defs := []*Definition{pkg}
+	for _, other := range d.Packages {
+		if other == pkg {
+			continue
+		}
+		objects := *other
+		objects.Services = nil
+		defs = append(defs, &objects)
+	}
+	return defs
+}
+
+// sortPackages orders the packages by import path.
+func (d *Document) sortPackages() {
+	sort.Slice(d.Packages, func(i, j int) bool {
+		return d.Packages[i].PackagePath < d.Packages[j].PackagePath
+	})
+}
+
+// fertilizeVersion gets the version of the fertilize module from the
+// build info.
+func fertilizeVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(devel)"
+	}
+	var version string
+	if info.Main.Path == modulePath {
+		version = info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			version = dep.Version
+			if dep.Replace != nil {
+				version = dep.Replace.Version
+			}
+		}
+	}
+	if version == "" {
+		return "(devel)"
+	}
+	return version
+}
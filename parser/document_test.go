@@ -0,0 +1,47 @@
+package parser
+
+import (
+	"testing"
+)
+
+func TestDocumentPackage(t *testing.T) {
+	doc := &Document{
+		Packages: []*Definition{
+			{PackagePath: "a", PackageName: "old"},
+			{PackagePath: "c"},
+		},
+	}
+	doc.Packages = append(doc.Packages, &Definition{PackagePath: "b", PackageName: "new"})
+	doc.sortPackages()
+	if got := doc.Package("b").PackageName; got != "new" {
+		t.Errorf("package b = %q, want new", got)
+	}
+	if got := doc.Package("a").PackageName; got != "old" {
+		t.Errorf("package a = %q, want old", got)
+	}
+	if doc.Package("d") != nil {
+		t.Error("found package d")
+	}
+	if m := doc.Map(); len(m) != 3 || m["c"] != doc.Package("c") {
+		t.Errorf("Map() = %v, want the three packages", m)
+	}
+}
+
+func TestDocumentAlone(t *testing.T) {
+	doc := &Document{
+		Packages: []*Definition{
+			{PackagePath: "a", Services: []Service{{Name: "A"}}, Objects: []Object{{Name: "Shared"}}},
+			{PackagePath: "b", Services: []Service{{Name: "B"}}},
+		},
+	}
+	defs := doc.Alone(doc.Packages[1])
+	if len(defs) != 2 || defs[0] != doc.Packages[1] {
+		t.Fatalf("Alone(b) = %v, want b first and then a", defs)
+	}
+	if defs[1].PackagePath != "a" || len(defs[1].Services) != 0 || len(defs[1].Objects) != 1 {
+		t.Errorf("a = %+v, want its objects without its services", defs[1])
+	}
+	if len(doc.Packages[0].Services) != 1 {
+		t.Error("the services of a were removed from the document")
+	}
+}
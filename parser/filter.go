@@ -0,0 +1,267 @@
+package parser
+
+import (
+	"go/ast"
+	goparser "go/parser"
+	"go/types"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Directives are comments on a type declaration, like go:generate
+// directives, that the parser understands. They aren't part of the
+// Comment.
+const (
+	// DirectiveService marks an interface as a service, for Filters
+	// with Directive set.
+	DirectiveService = "fertilize:service"
+	// DirectiveIgnore leaves out an interface, or a struct unless a
+	// service uses it.
+	DirectiveIgnore = "fertilize:ignore"
+	// DirectiveTags tags a service with the space or comma separated
+	// names that follow it, for Filters with Tags.
+	DirectiveTags = "fertilize:tags"
+)
+
+// Filter selects the interfaces of the packages that are described as
+// services, and the structs that are described as objects.
+//
+// Patterns match the qualified name of a type, such as
+// pleasantries.GreeterService, or with the import path in place of the
+// package name. A pattern without a dot matches the type name alone.
+// Patterns are globs, where * matches any run of characters other than
+// / and ? matches one, unless they are enclosed in slashes, like
+// /Service$/, when they are regular expressions.
+type Filter struct {
+	// Include are the patterns of the interfaces to describe. Empty
+	// means every interface.
+	Include []string `json:"include,omitempty"`
+	// Exclude are the patterns of interfaces to leave out, even if
+	// they are included.
+	Exclude []string `json:"exclude,omitempty"`
+	// Shapes are method signatures every method of a service must
+	// match one of, such as "(_, server.GenericRequest) _". Types are
+	// written as in the package declaring the interface, and _
+	// matches any type. Empty means any signature.
+	Shapes []string `json:"shapes,omitempty"`
+	// Tags only describes services tagged with one of them by a
+	// fertilize:tags directive.
+	Tags []string `json:"tags,omitempty"`
+	// Directive only describes interfaces with a fertilize:service
+	// directive.
+	Directive bool `json:"directive,omitempty"`
+	// Reachable only describes the structs used by services, directly
+	// or through the fields of other structs, instead of every struct
+	// declared in the packages.
+	Reachable bool `json:"reachable,omitempty"`
+}
+
+// filter is a compiled Filter.
+type filter struct {
+	Filter
+	include, exclude []matcher
+	shapes           []*ast.FuncType
+}
+
+// matcher matches the qualified names of a type.
+type matcher func(pkgName, pkgPath, name string) bool
+
+func (f Filter) compile() (*filter, error) {
+	c := &filter{Filter: f}
+	var err error
+	if c.include, err = compilePatterns(f.Include); err != nil {
+		return nil, errors.Wrap(err, "include")
+	}
+	if c.exclude, err = compilePatterns(f.Exclude); err != nil {
+		return nil, errors.Wrap(err, "exclude")
+	}
+	for _, shape := range f.Shapes {
+		expr, err := goparser.ParseExpr("func" + shape)
+		if err != nil {
+			return nil, errors.Wrapf(err, "shape %q", shape)
+		}
+		fn, ok := expr.(*ast.FuncType)
+		if !ok {
+			return nil, errors.Errorf("shape %q isn't a method signature", shape)
+		}
+		c.shapes = append(c.shapes, fn)
+	}
+	return c, nil
+}
+
+func compilePatterns(patterns []string) ([]matcher, error) {
+	matchers := make([]matcher, 0, len(patterns))
+	for _, pattern := range patterns {
+		if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+			if err != nil {
+				return nil, errors.Wrapf(err, "pattern %q", pattern)
+			}
+			matchers = append(matchers, func(pkgName, pkgPath, name string) bool {
+				return re.MatchString(pkgName+"."+name) || re.MatchString(pkgPath+"."+name)
+			})
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, errors.Wrapf(err, "pattern %q", pattern)
+		}
+		pattern := pattern
+		if !strings.Contains(pattern, ".") {
+			matchers = append(matchers, func(pkgName, pkgPath, name string) bool {
+				ok, _ := path.Match(pattern, name)
+				return ok
+			})
+			continue
+		}
+		matchers = append(matchers, func(pkgName, pkgPath, name string) bool {
+			if ok, _ := path.Match(pattern, pkgName+"."+name); ok {
+				return true
+			}
+			ok, _ := path.Match(pattern, pkgPath+"."+name)
+			return ok
+		})
+	}
+	return matchers, nil
+}
+
+func matchAny(matchers []matcher, pkgName, pkgPath, name string) bool {
+	for _, m := range matchers {
+		if m(pkgName, pkgPath, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// service reports whether the interface obj with the directives is
+// described as a service.
+func (f *filter) service(obj types.Object, iface *types.Interface, directives map[string][]string) bool {
+	if _, ok := directives[DirectiveIgnore]; ok {
+		return false
+	}
+	if _, ok := directives[DirectiveService]; f.Directive && !ok {
+		return false
+	}
+	pkg := obj.Pkg()
+	if len(f.include) > 0 && !matchAny(f.include, pkg.Name(), pkg.Path(), obj.Name()) {
+		return false
+	}
+	if matchAny(f.exclude, pkg.Name(), pkg.Path(), obj.Name()) {
+		return false
+	}
+	if len(f.Tags) > 0 && !hasAny(directives[DirectiveTags], f.Tags) {
+		return false
+	}
+	if len(f.shapes) > 0 {
+		for i := 0; i < iface.NumMethods(); i++ {
+			if !f.matchShape(pkg, iface.Method(i).Type().(*types.Signature)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// object reports whether the struct with the directives is described
+// without a service using it. With Reachable, those no service uses are
+// dropped once every package is parsed.
+func (f *filter) object(directives map[string][]string) bool {
+	_, ok := directives[DirectiveIgnore]
+	return !ok
+}
+
+// matchShape reports whether the signature of a method of an interface
+// in pkg matches one of the shapes.
+func (f *filter) matchShape(pkg *types.Package, sig *types.Signature) bool {
+	qualifier := func(other *types.Package) string {
+		if other == pkg {
+			return ""
+		}
+		return other.Name()
+	}
+	for _, shape := range f.shapes {
+		if matchTuple(shape.Params, sig.Params(), qualifier) && matchTuple(shape.Results, sig.Results(), qualifier) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchTuple(fields *ast.FieldList, tuple *types.Tuple, qualifier types.Qualifier) bool {
+	var want []string
+	if fields != nil {
+		for _, field := range fields.List {
+			n := len(field.Names)
+			if n == 0 {
+				n = 1
+			}
+			for i := 0; i < n; i++ {
+				want = append(want, types.ExprString(field.Type))
+			}
+		}
+	}
+	if len(want) != tuple.Len() {
+		return false
+	}
+	for i, typ := range want {
+		if typ != "_" && typ != types.TypeString(tuple.At(i).Type(), qualifier) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasAny(values, wanted []string) bool {
+	for _, v := range values {
+		if isInSlice(wanted, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// typeDirectives gets the fertilize directives on the type declarations
+// of files, keyed by type name and then by directive, with the arguments
+// following each directive.
+func typeDirectives(files []*ast.File) map[string]map[string][]string {
+	byType := make(map[string]map[string][]string)
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				spec, ok := spec.(*ast.TypeSpec)
+				if !ok {
+					continue
+				}
+				directives := make(map[string][]string)
+				groups := []*ast.CommentGroup{spec.Doc}
+				if len(gen.Specs) == 1 {
+					groups = append(groups, gen.Doc)
+				}
+				for _, group := range groups {
+					if group == nil {
+						continue
+					}
+					for _, c := range group.List {
+						text, ok := strings.CutPrefix(c.Text, "//")
+						if !ok || !strings.HasPrefix(text, "fertilize:") {
+							continue
+						}
+						directive, args, _ := strings.Cut(text, " ")
+						directives[directive] = append(directives[directive], strings.FieldsFunc(args, func(r rune) bool {
+							return r == ',' || r == ' ' || r == '\t'
+						})...)
+					}
+				}
+				byType[spec.Name.Name] = directives
+			}
+		}
+	}
+	return byType
+}
@@ -18,6 +18,8 @@ var ErrNotFound = errors.New("not found")
 type Definition struct {
 	// PackageName is the name of the package.
 	PackageName string `json:"packageName"`
+	// PackagePath is the import path of the package.
+	PackagePath string `json:"packagePath"`
 	// Services are the services described in this definition.
 	Services []Service `json:"services"`
 	// Objects are the structures that are used throughout this definition.
@@ -88,6 +90,9 @@ type Service struct {
 	Name    string   `json:"name"`
 	Methods []Method `json:"methods"`
 	Comment string   `json:"comment"`
+	// Tags are the tags of the fertilize:tags directive on the
+	// interface.
+	Tags []string `json:"tags,omitempty"`
 }
 
 // Method describes a method that a Service can perform.
@@ -128,4 +133,45 @@ type FieldType struct {
 	Multiple        bool   `json:"multiple"`
 	Package         string `json:"package"`
 	IsObject        bool   `json:"isObject"`
+	// Expr describes the type named by TypeName, as go/types sees it,
+	// so it needn't be parsed from TypeName. Documents written before
+	// SchemaVersion 2.3 don't have it.
+	Expr *TypeExpr `json:"expr,omitempty"`
+}
+
+// Kinds of TypeExpr.
+const (
+	KindBasic     = "basic"
+	KindNamed     = "named"
+	KindPointer   = "pointer"
+	KindSlice     = "slice"
+	KindMap       = "map"
+	KindInterface = "interface"
+	// KindInvalid is a type that can't be encoded, like a func or
+	// chan.
+	KindInvalid = "invalid"
+)
+
+// TypeExpr is the structure of a Go type.
+type TypeExpr struct {
+	// Kind is one of the Kind constants. Arrays are slices.
+	Kind string `json:"kind"`
+	// Name is the name of basic and named types, or the Go source of
+	// invalid ones.
+	Name string `json:"name,omitempty"`
+	// Package is the import path of a named type, and Qualifier is the
+	// name it is qualified with in TypeName, or "" if it is declared in
+	// the package of the FieldType.
+	Package   string `json:"package,omitempty"`
+	Qualifier string `json:"qualifier,omitempty"`
+	// Underlying is the type a named type that isn't a struct is
+	// declared as, like the string of type Status string.
+	Underlying *TypeExpr `json:"underlying,omitempty"`
+	// Text reports whether a named type implements
+	// encoding.TextMarshaler, so it is encoded as a JSON string.
+	Text bool `json:"text,omitempty"`
+	// Elem is the element type of pointers, slices and maps, and Key
+	// the key type of maps.
+	Elem *TypeExpr `json:"elem,omitempty"`
+	Key  *TypeExpr `json:"key,omitempty"`
 }
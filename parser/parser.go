@@ -9,6 +9,10 @@ package parser
 	Removed oto specific fields and functions.
 	Removed the 1 param 1 return value constraint.
 	Changes def to map[string]*Definition.
+	Added ParseDocument returning a Document.
+	Parsed each package on its own, so a Cache can keep them.
+	Added Filter selecting the interfaces and structs described.
+	Excluded objects by reachability from the services described.
 	Moved structs to models.go file.
 */
 
@@ -21,6 +25,7 @@ import (
 	"os"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/fatih/structtag"
 	"github.com/pkg/errors"
@@ -32,8 +37,19 @@ import (
 type Parser struct {
 	Verbose bool
 
+	// Dir is the directory the patterns are resolved in.
+	// Empty means the current directory.
+	Dir string
+
 	ExcludeInterfaces []string
 
+	// Filter selects the interfaces and structs to describe.
+	Filter Filter
+
+	// Cache, if set, keeps what ParseDocument found in each package,
+	// so it only parses the packages invalidated since.
+	Cache *Cache
+
 	patterns []string
 	def      map[string]*Definition
 
@@ -44,6 +60,9 @@ type Parser struct {
 
 	// docs are the docs for extracting comments.
 	docs *doc.Package
+	// directives are the fertilize directives on the types of the
+	// package, keyed by type name.
+	directives map[string]map[string][]string
 }
 
 // New makes a fresh parser using the specified patterns.
@@ -55,84 +74,150 @@ func New(patterns ...string) *Parser {
 	}
 }
 
+// Parse describes the packages keyed by import path. Use ParseDocument
+// for the packages in order along with how they were parsed.
 func (p Parser) Parse() (map[string]*Definition, error) {
-	cfg := &packages.Config{
-		Mode:  packages.NeedTypes | packages.NeedName | packages.NeedTypesInfo | packages.NeedDeps | packages.NeedName | packages.NeedSyntax,
-		Tests: false,
+	doc, err := p.ParseDocument()
+	if doc == nil {
+		return nil, err
 	}
+	return doc.Map(), err
+}
 
-	pkgs, err := packages.Load(cfg, p.patterns...)
+// ParseDocument describes the packages. If a service can't be parsed the
+// Document describes the packages parsed so far along with the error.
+//
+// With a Cache, only the packages invalidated since the last call are
+// loaded and parsed again.
+func (p Parser) ParseDocument() (*Document, error) {
+	filter, err := p.Filter.compile()
 	if err != nil {
-		fmt.Println(fmt.Errorf("error loading packages %s", err))
-		os.Exit(1)
+		return nil, errors.Wrap(err, "filter")
+	}
+	cache := p.Cache
+	if cache == nil {
+		cache = &Cache{}
+	}
+	var pkgs []*packages.Package
+	if patterns := cache.patterns(p.patterns); len(patterns) > 0 {
+		cfg := &packages.Config{
+			Mode:  packages.NeedTypes | packages.NeedName | packages.NeedTypesInfo | packages.NeedImports | packages.NeedDeps | packages.NeedSyntax | packages.NeedModule,
+			Tests: false,
+			Dir:   p.Dir,
+		}
+		pkgs, err = packages.Load(cfg, patterns...)
+		if err != nil {
+			fmt.Println(fmt.Errorf("error loading packages %s", err))
+			os.Exit(1)
+		}
 	}
 
+	now := time.Now().UTC()
+	document := &Document{
+		SchemaVersion:    SchemaVersion,
+		FertilizeVersion: fertilizeVersion(),
+		Generated:        &now,
+		Options: Options{
+			Patterns:          p.patterns,
+			ExcludeInterfaces: p.ExcludeInterfaces,
+			Filter:            p.Filter,
+		},
+	}
+	roots := cache.roots(pkgs)
+	parsed := make(map[string]*parsedPackage, len(pkgs))
+	order := make([]string, 0, len(pkgs))
 	p.def = make(map[string]*Definition)
 	p.outputObjects = make(map[string]struct{})
-	p.objects = make(map[string]struct{})
-	var excludedObjectsTypeIDs []string
 	for _, pkg := range pkgs {
-		p.docs, err = doc.NewFromFiles(pkg.Fset, pkg.Syntax, "")
+		pp, err := p.parsePackage(pkg, filter)
+		parsed[pkg.PkgPath] = pp
+		order = append(order, pkg.PkgPath)
 		if err != nil {
-			panic(err)
-		}
-		d := &Definition{}
-		p.def[pkg.PkgPath] = d
-		d.PackageName = pkg.Name
-		scope := pkg.Types.Scope()
-		for _, name := range scope.Names() {
-			obj := scope.Lookup(name)
-			switch item := obj.Type().Underlying().(type) {
-			case *types.Interface:
-				s, err := p.parseService(pkg, obj, item)
-				if err != nil {
-					return p.def, err
-				}
-				if isInSlice(p.ExcludeInterfaces, name) {
-					for _, method := range s.Methods {
-						for i := range method.InputObjects {
-							excludedObjectsTypeIDs = append(excludedObjectsTypeIDs, method.InputObjects[i].TypeID)
-						}
-						for i := range method.OutputObjects {
-							excludedObjectsTypeIDs = append(excludedObjectsTypeIDs, method.OutputObjects[i].TypeID)
-						}
-
-					}
-					continue
-				}
-				d.Services = append(d.Services, s)
-			case *types.Struct:
-				p.parseObject(pkg, obj, item)
-			}
+			cache.document(document, parsed, order)
+			document.sortPackages()
+			return document, err
 		}
+		pp.imports = importsOf(pkg, roots)
+	}
+	cache.update(parsed, order)
+	excluded := cache.document(document, nil, nil)
+	// objects are shared between packages and services, so they are
+	// only dropped once every package is parsed
+	document.Dropped = document.dropUnreachable(excluded, filter.Reachable)
+	document.sortPackages()
+	return document, nil
+}
 
-		// remove any excluded objects
-		nonExcludedObjects := make([]Object, 0, len(d.Objects))
-		for _, object := range d.Objects {
-			excluded := false
-			for _, excludedTypeID := range excludedObjectsTypeIDs {
-				if object.TypeID == excludedTypeID {
-					excluded = true
-					break
+// parsePackage describes pkg on its own, as if no other package was
+// parsed.
+func (p *Parser) parsePackage(pkg *packages.Package, filter *filter) (*parsedPackage, error) {
+	// go/doc removes the comments it reads from the AST, so
+	// directives are found first
+	p.directives = typeDirectives(pkg.Syntax)
+	var err error
+	p.docs, err = doc.NewFromFiles(pkg.Fset, pkg.Syntax, "")
+	if err != nil {
+		panic(err)
+	}
+	p.objects = make(map[string]struct{})
+	pp := &parsedPackage{diagnostics: []Diagnostic{}}
+	if pkg.Module != nil && pkg.Module.Main {
+		pp.module = &Module{Path: pkg.Module.Path, GoVersion: pkg.Module.GoVersion}
+	}
+	for _, e := range pkg.Errors {
+		pp.diagnostics = append(pp.diagnostics, Diagnostic{
+			Package:  pkg.PkgPath,
+			Position: e.Pos,
+			Message:  e.Msg,
+		})
+	}
+	d := &Definition{}
+	p.def[pkg.PkgPath] = d
+	pp.def = d
+	d.PackageName = pkg.Name
+	d.PackagePath = pkg.PkgPath
+	scope := pkg.Types.Scope()
+	for _, name := range scope.Names() {
+		obj := scope.Lookup(name)
+		switch item := obj.Type().Underlying().(type) {
+		case *types.Interface:
+			directives := p.directives[name]
+			if !filter.service(obj, item, directives) {
+				// the objects only the interfaces the filter
+				// leaves out use are dropped like those of
+				// ExcludeInterfaces. One that can't be parsed
+				// isn't described, so it doesn't fail the parse.
+				if s, err := p.parseService(pkg, obj, item); err == nil {
+					pp.excluded = append(pp.excluded, s)
 				}
+				continue
+			}
+			s, err := p.parseService(pkg, obj, item)
+			if err != nil {
+				return pp, err
 			}
-			if excluded {
+			s.Tags = directives[DirectiveTags]
+			if isInSlice(p.ExcludeInterfaces, name) {
+				pp.excluded = append(pp.excluded, s)
 				continue
 			}
-			nonExcludedObjects = append(nonExcludedObjects, object)
+			d.Services = append(d.Services, s)
+		case *types.Struct:
+			if !filter.object(p.directives[name]) {
+				continue
+			}
+			p.parseObject(pkg, obj, item)
 		}
-		d.Objects = nonExcludedObjects
-		// sort services
-		sort.Slice(d.Services, func(i, j int) bool {
-			return d.Services[i].Name < d.Services[j].Name
-		})
-		// sort objects
-		sort.Slice(d.Objects, func(i, j int) bool {
-			return d.Objects[i].Name < d.Objects[j].Name
-		})
 	}
-
-	return p.def, nil
+	// sort services
+	sort.Slice(d.Services, func(i, j int) bool {
+		return d.Services[i].Name < d.Services[j].Name
+	})
+	// sort objects
+	sort.Slice(d.Objects, func(i, j int) bool {
+		return d.Objects[i].Name < d.Objects[j].Name
+	})
+	return pp, nil
 }
 
 func (p *Parser) parseService(pkg *packages.Package, obj types.Object, interfaceType *types.Interface) (Service, error) {
@@ -230,10 +315,68 @@ func (p *Parser) parseFieldType(pkg *packages.Package, obj types.Object) (FieldT
 	ftype.ObjectName = types.TypeString(originalTyp, func(other *types.Package) string { return "" })
 	ftype.TypeID = pkgPath + "." + ftype.ObjectName
 	ftype.CleanObjectName = strings.TrimPrefix(ftype.ObjectName, "*")
+	ftype.Expr = typeExpr(originalTyp, pkg.Name, make(map[*types.Named]bool))
 
 	return ftype, nil
 }
 
+// typeExpr describes typ, qualifying named types declared outside the
+// package named pkgName. seen are the named types being described, so
+// recursive types don't describe their underlying types forever.
+func typeExpr(typ types.Type, pkgName string, seen map[*types.Named]bool) *TypeExpr {
+	switch t := types.Unalias(typ).(type) {
+	case *types.Basic:
+		return &TypeExpr{Kind: KindBasic, Name: t.Name()}
+	case *types.Named:
+		expr := &TypeExpr{Kind: KindNamed, Name: t.Obj().Name(), Text: isTextMarshaler(t)}
+		if pkg := t.Obj().Pkg(); pkg != nil {
+			expr.Package = pkg.Path()
+			if pkg.Name() != pkgName {
+				expr.Qualifier = pkg.Name()
+			}
+		}
+		if _, ok := t.Underlying().(*types.Struct); !ok && !seen[t] {
+			seen[t] = true
+			expr.Underlying = typeExpr(t.Underlying(), pkgName, seen)
+			delete(seen, t)
+		}
+		return expr
+	case *types.TypeParam:
+		return &TypeExpr{Kind: KindNamed, Name: t.Obj().Name()}
+	case *types.Pointer:
+		return &TypeExpr{Kind: KindPointer, Elem: typeExpr(t.Elem(), pkgName, seen)}
+	case *types.Slice:
+		return &TypeExpr{Kind: KindSlice, Elem: typeExpr(t.Elem(), pkgName, seen)}
+	case *types.Array:
+		return &TypeExpr{Kind: KindSlice, Elem: typeExpr(t.Elem(), pkgName, seen)}
+	case *types.Map:
+		return &TypeExpr{
+			Kind: KindMap,
+			Key:  typeExpr(t.Key(), pkgName, seen),
+			Elem: typeExpr(t.Elem(), pkgName, seen),
+		}
+	case *types.Interface:
+		return &TypeExpr{Kind: KindInterface}
+	}
+	return &TypeExpr{Kind: KindInvalid, Name: typ.String()}
+}
+
+// isTextMarshaler reports whether t or a pointer to it implements
+// encoding.TextMarshaler.
+func isTextMarshaler(t *types.Named) bool {
+	obj, _, _ := types.LookupFieldOrMethod(types.NewPointer(t), true, nil, "MarshalText")
+	fn, ok := obj.(*types.Func)
+	if !ok {
+		return false
+	}
+	sig := fn.Type().(*types.Signature)
+	if sig.Params().Len() != 0 || sig.Results().Len() != 2 {
+		return false
+	}
+	return types.TypeString(sig.Results().At(0).Type(), nil) == "[]byte" &&
+		types.TypeString(sig.Results().At(1).Type(), nil) == "error"
+}
+
 // parseObject parses a struct type and adds it to the Definition.
 func (p *Parser) parseObject(pkg *packages.Package, o types.Object, v *types.Struct) error {
 	var obj Object
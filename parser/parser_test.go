@@ -0,0 +1,660 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gitamped/fertilize/output"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+const (
+	services     = "github.com/gitamped/fertilize/examples/testdata/services"
+	pleasantries = services + "/pleasantries"
+	filters      = services + "/filters"
+	kinds        = services + "/kinds"
+)
+
+// parse parses the packages matching patterns, failing the test if it
+// can't.
+func parse(t *testing.T, exclude []string, patterns ...string) *Document {
+	t.Helper()
+	p := New(patterns...)
+	p.ExcludeInterfaces = exclude
+	return parseWith(t, p)
+}
+
+// parseFilter parses the packages matching patterns with filter.
+func parseFilter(t *testing.T, filter Filter, patterns ...string) *Document {
+	t.Helper()
+	p := New(patterns...)
+	p.Filter = filter
+	return parseWith(t, p)
+}
+
+func parseWith(t *testing.T, p *Parser) *Document {
+	t.Helper()
+	doc, err := p.ParseDocument()
+	if err != nil {
+		t.Fatalf("ParseDocument: %v", err)
+	}
+	if len(doc.Diagnostics) > 0 {
+		t.Fatalf("ParseDocument: %v", doc.Diagnostics)
+	}
+	return doc
+}
+
+// pkg gets the Definition of pkgPath from doc.
+func pkg(t *testing.T, doc *Document, pkgPath string) *Definition {
+	t.Helper()
+	d := doc.Package(pkgPath)
+	if d == nil {
+		t.Fatalf("no package %s", pkgPath)
+	}
+	return d
+}
+
+func service(d *Definition, name string) *Service {
+	for i := range d.Services {
+		if d.Services[i].Name == name {
+			return &d.Services[i]
+		}
+	}
+	return nil
+}
+
+func object(t *testing.T, d *Definition, name string) *Object {
+	t.Helper()
+	o, err := d.Object(name)
+	if err != nil {
+		t.Fatalf("object %s: %v", name, err)
+	}
+	return o
+}
+
+func field(t *testing.T, o *Object, name string) Field {
+	t.Helper()
+	for _, f := range o.Fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	t.Fatalf("%s has no field %s", o.Name, name)
+	return Field{}
+}
+
+func TestParseGolden(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		exclude  []string
+	}{
+		{
+			name:     "pleasantries",
+			patterns: []string{pleasantries},
+		},
+		{
+			name:     "pleasantries_exclude",
+			patterns: []string{pleasantries},
+			exclude:  []string{"Ignorer", "Welcomer"},
+		},
+		{
+			name:     "services",
+			patterns: []string{services, pleasantries},
+			exclude:  []string{"Ignorer"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := parse(t, tt.exclude, tt.patterns...)
+			got, err := json.MarshalIndent(doc.Packages, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, '\n')
+			golden := filepath.Join("testdata", tt.name+".golden.json")
+			if *update {
+				if _, err := output.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run with -update to write it)", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("Definitions differ from %s (run with -update to accept them):\n%s", golden, output.UnifiedDiff(golden, "got", want, got))
+			}
+		})
+	}
+}
+
+func TestParseDocument(t *testing.T) {
+	doc := parse(t, []string{"Ignorer"}, pleasantries, services)
+	if doc.SchemaVersion != SchemaVersion {
+		t.Errorf("SchemaVersion = %q, want %q", doc.SchemaVersion, SchemaVersion)
+	}
+	if doc.Module == nil || doc.Module.Path != "github.com/gitamped/fertilize" {
+		t.Errorf("Module = %+v, want github.com/gitamped/fertilize", doc.Module)
+	}
+	var paths []string
+	for _, d := range doc.Packages {
+		paths = append(paths, d.PackagePath)
+	}
+	if want := []string{services, pleasantries}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("packages = %q, want %q", paths, want)
+	}
+	wantOptions := Options{Patterns: []string{pleasantries, services}, ExcludeInterfaces: []string{"Ignorer"}}
+	if !reflect.DeepEqual(doc.Options, wantOptions) {
+		t.Errorf("Options = %+v, want %+v", doc.Options, wantOptions)
+	}
+	if doc.Generated == nil {
+		t.Error("Generated isn't set")
+	}
+}
+
+func TestParseCache(t *testing.T) {
+	newParser := func() *Parser {
+		p := New(services, pleasantries)
+		p.ExcludeInterfaces = []string{"Ignorer"}
+		p.Filter = Filter{Reachable: true}
+		return p
+	}
+	want := parseWith(t, newParser())
+	want.Generated = nil
+
+	cache := &Cache{}
+	for _, invalidate := range [][]string{nil, {}, {services}, {pleasantries}} {
+		if invalidate != nil {
+			cache.Invalidate(invalidate...)
+		}
+		if len(invalidate) == 1 && invalidate[0] == services && !cache.stale[pleasantries] {
+			t.Error("pleasantries isn't stale when services, which it imports, is")
+		}
+		if len(invalidate) == 1 && invalidate[0] == pleasantries && cache.stale[services] {
+			t.Error("services is stale when pleasantries, which it doesn't import, is")
+		}
+		p := newParser()
+		p.Cache = cache
+		got := parseWith(t, p)
+		got.Generated = nil
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("cached parse after invalidating %q differs from a parse without a cache", invalidate)
+		}
+		// the cache isn't changed through the Document
+		got.Packages[0].Objects = nil
+	}
+}
+
+func TestParseExcludeInterfaces(t *testing.T) {
+	tests := []struct {
+		name    string
+		exclude []string
+		// services are the services expected.
+		services []string
+		// excluded are objects expected to be left out.
+		excluded []string
+	}{
+		{
+			name:     "none",
+			services: []string{"GreeterService", "Ignorer", "StrangeTypesService", "Welcomer"},
+		},
+		{
+			name:     "Ignorer",
+			exclude:  []string{"Ignorer"},
+			services: []string{"GreeterService", "StrangeTypesService", "Welcomer"},
+			excluded: []string{"IgnoreRequest", "IgnoreResponse"},
+		},
+		{
+			name:     "Ignorer and Welcomer",
+			exclude:  []string{"Ignorer", "Welcomer"},
+			services: []string{"GreeterService", "StrangeTypesService"},
+			excluded: []string{"CustomerDetails", "IgnoreRequest", "IgnoreResponse", "WelcomeRequest", "WelcomeResponse"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := pkg(t, parse(t, tt.exclude, pleasantries), pleasantries)
+			var names []string
+			for _, s := range d.Services {
+				names = append(names, s.Name)
+			}
+			if !reflect.DeepEqual(names, tt.services) {
+				t.Errorf("services = %q, want %q", names, tt.services)
+			}
+			for _, name := range tt.excluded {
+				if _, err := d.Object(name); err == nil {
+					t.Errorf("object %s wasn't excluded", name)
+				}
+			}
+		})
+	}
+}
+
+func TestParseExcludeShared(t *testing.T) {
+	const seed = "github.com/gitamped/seed/server."
+	tests := []struct {
+		name    string
+		exclude []string
+		// kept are objects expected to be described.
+		kept []string
+		// dropped are the objects expected to be dropped, by TypeID,
+		// with the reasons.
+		dropped map[string]string
+	}{
+		{
+			name:    "shared with a service",
+			exclude: []string{"OrderService"},
+			kept:    []string{"GenericRequest", "Item", "Price", "RefundRequest"},
+			dropped: map[string]string{
+				filters + ".Note":          "only used by the excluded interface OrderService",
+				filters + ".PlaceRequest":  "only used by the excluded interface OrderService",
+				filters + ".PlaceResponse": "only used by the excluded interface OrderService",
+			},
+		},
+		{
+			name:    "shared by excluded interfaces",
+			exclude: []string{"OrderService", "RefundService"},
+			kept:    []string{"Config"},
+			dropped: map[string]string{
+				filters + ".SyncRequest":    "only used by the excluded interface Internal",
+				seed + "GenericRequest":     "only used by the excluded interfaces Internal, OrderService, RefundService",
+				filters + ".Item":           "only used by the excluded interfaces OrderService, RefundService",
+				filters + ".Price":          "only used by the excluded interfaces OrderService, RefundService",
+				filters + ".Note":           "only used by the excluded interface OrderService",
+				filters + ".PlaceRequest":   "only used by the excluded interface OrderService",
+				filters + ".PlaceResponse":  "only used by the excluded interface OrderService",
+				filters + ".RefundRequest":  "only used by the excluded interface RefundService",
+				filters + ".RefundResponse": "only used by the excluded interface RefundService",
+				"time.Time":                 "only used by the excluded interfaces Internal, OrderService, RefundService",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := parse(t, tt.exclude, filters)
+			d := pkg(t, doc, filters)
+			for _, name := range tt.kept {
+				object(t, d, name)
+			}
+			dropped := make(map[string]string)
+			for _, drop := range doc.Dropped {
+				if drop.Package != filters {
+					t.Errorf("%s dropped from %s, want %s", drop.TypeID, drop.Package, filters)
+				}
+				dropped[drop.TypeID] = drop.Reason
+				if _, err := d.Object(drop.TypeID[strings.LastIndex(drop.TypeID, ".")+1:]); err == nil {
+					t.Errorf("%s is dropped and described", drop.TypeID)
+				}
+			}
+			for id, reason := range tt.dropped {
+				if got := dropped[id]; got != reason {
+					t.Errorf("%s dropped for %q, want %q", id, got, reason)
+				}
+			}
+		})
+	}
+}
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		// services are the services expected.
+		services []string
+		// objects are the objects expected.
+		objects []string
+		// dropped are the reasons some of the objects are expected to be
+		// dropped for, by name.
+		dropped map[string]string
+	}{
+		{
+			name:     "none",
+			services: []string{"OrderService", "RefundService", "Source"},
+			dropped: map[string]string{
+				"SyncRequest": "only used by the excluded interface Internal",
+			},
+			objects: []string{
+				"Claims", "Config", "GenericRequest", "Item", "Location", "Note", "NumericDate", "PlaceRequest", "PlaceResponse", "Price",
+				"RefundRequest", "RefundResponse", "RegisteredClaims", "Time", "Values", "zone", "zoneTrans",
+			},
+		},
+		{
+			name:     "include glob",
+			filter:   Filter{Include: []string{"filters.*Service"}},
+			services: []string{"OrderService", "RefundService"},
+		},
+		{
+			name:     "include import path",
+			filter:   Filter{Include: []string{"github.com/*/fertilize/examples/testdata/services/filters.Order*"}},
+			services: []string{"OrderService"},
+		},
+		{
+			name:     "include regexp",
+			filter:   Filter{Include: []string{"/^filters\\.(Order|Source)/"}},
+			services: []string{"OrderService", "Source"},
+		},
+		{
+			name:     "exclude name",
+			filter:   Filter{Include: []string{"*Service"}, Exclude: []string{"Refund*"}},
+			services: []string{"OrderService"},
+			dropped: map[string]string{
+				"RefundRequest":  "only used by the excluded interface RefundService",
+				"RefundResponse": "only used by the excluded interface RefundService",
+			},
+		},
+		{
+			name:     "shape",
+			filter:   Filter{Shapes: []string{"(_, server.GenericRequest) _"}},
+			services: []string{"OrderService", "RefundService"},
+		},
+		{
+			name:     "shapes",
+			filter:   Filter{Shapes: []string{"(p []byte) (n int, err error)", "() string"}},
+			services: []string{"Source"},
+			dropped: map[string]string{
+				"PlaceRequest": "only used by the excluded interface OrderService",
+				"Item":         "only used by the excluded interfaces OrderService, RefundService",
+				"SyncResponse": "only used by the excluded interface Internal",
+			},
+		},
+		{
+			name:     "tags",
+			filter:   Filter{Tags: []string{"public"}},
+			services: []string{"OrderService"},
+			dropped: map[string]string{
+				"RefundRequest": "only used by the excluded interface RefundService",
+			},
+		},
+		{
+			name:     "directive",
+			filter:   Filter{Directive: true},
+			services: []string{"OrderService"},
+			dropped: map[string]string{
+				"RefundRequest": "only used by the excluded interface RefundService",
+				"SyncRequest":   "only used by the excluded interface Internal",
+			},
+		},
+		{
+			name:     "reachable",
+			filter:   Filter{Include: []string{"OrderService"}, Reachable: true},
+			services: []string{"OrderService"},
+			// Note is reached through a map, and the rest through
+			// server.GenericRequest
+			objects: []string{
+				"Claims", "GenericRequest", "Item", "Location", "Note", "NumericDate", "PlaceRequest", "PlaceResponse", "Price",
+				"RegisteredClaims", "Time", "Values", "zone", "zoneTrans",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := parseFilter(t, tt.filter, filters)
+			if tt.filter.Reachable {
+				want := Dropped{Package: filters, TypeID: filters + ".Config", Reason: "not used by any service"}
+				if !containsDropped(doc.Dropped, want) {
+					t.Errorf("Dropped = %+v, want it to contain %+v", doc.Dropped, want)
+				}
+			}
+			if !reflect.DeepEqual(doc.Options.Filter, tt.filter) {
+				t.Errorf("Options.Filter = %+v, want %+v", doc.Options.Filter, tt.filter)
+			}
+			for name, reason := range tt.dropped {
+				want := Dropped{Package: filters, TypeID: filters + "." + name, Reason: reason}
+				if !containsDropped(doc.Dropped, want) {
+					t.Errorf("Dropped = %+v, want it to contain %+v", doc.Dropped, want)
+				}
+			}
+			d := pkg(t, doc, filters)
+			var names []string
+			for _, s := range d.Services {
+				names = append(names, s.Name)
+			}
+			if !reflect.DeepEqual(names, tt.services) {
+				t.Errorf("services = %q, want %q", names, tt.services)
+			}
+			if tt.objects == nil {
+				return
+			}
+			names = nil
+			for _, o := range d.Objects {
+				names = append(names, o.Name)
+			}
+			if !reflect.DeepEqual(names, tt.objects) {
+				t.Errorf("objects = %q, want %q", names, tt.objects)
+			}
+		})
+	}
+}
+
+func containsDropped(dropped []Dropped, want Dropped) bool {
+	for _, drop := range dropped {
+		if drop == want {
+			return true
+		}
+	}
+	return false
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	for _, filter := range []Filter{
+		{Include: []string{"[Service"}},
+		{Exclude: []string{"/(/"}},
+		{Shapes: []string{"(_"}},
+	} {
+		p := New(filters)
+		p.Filter = filter
+		if _, err := p.ParseDocument(); err == nil {
+			t.Errorf("%+v: no error", filter)
+		}
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	d := pkg(t, parseFilter(t, Filter{}, filters), filters)
+	if got, want := service(d, "OrderService").Tags, []string{"public", "admin"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("OrderService tags = %q, want %q", got, want)
+	}
+	if got, want := service(d, "OrderService").Comment, "OrderService manages orders."; got != want {
+		t.Errorf("OrderService comment = %q, want %q", got, want)
+	}
+}
+
+func TestParseImportedObjects(t *testing.T) {
+	d := pkg(t, parse(t, nil, pleasantries), pleasantries)
+	page := object(t, d, "Page")
+	if !page.Imported {
+		t.Error("Page isn't marked imported")
+	}
+	if want := services + ".Page"; page.TypeID != want {
+		t.Errorf("Page.TypeID = %q, want %q", page.TypeID, want)
+	}
+	f := field(t, object(t, d, "GetGreetingsRequest"), "Page")
+	wantType := FieldType{
+		TypeID:          services + ".Page",
+		TypeName:        "services.Page",
+		ObjectName:      "Page",
+		CleanObjectName: "Page",
+		Package:         services,
+		IsObject:        true,
+		Expr: &TypeExpr{
+			Kind:      KindNamed,
+			Name:      "Page",
+			Package:   services,
+			Qualifier: "services",
+		},
+	}
+	if !reflect.DeepEqual(f.Type, wantType) {
+		t.Errorf("Page field type = %+v, want %+v", f.Type, wantType)
+	}
+	if got := d.Imports[services]; got != "services" {
+		t.Errorf("Imports[%s] = %q, want services", services, got)
+	}
+	greet := service(d, "GreeterService").Methods[1]
+	if got := greet.InputObjects[1].Package; got != "github.com/gitamped/seed/server" {
+		t.Errorf("Greet second input package = %q, want the seed server", got)
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	d := pkg(t, parse(t, nil, pleasantries), pleasantries)
+	tests := []struct {
+		object, field string
+		typeName      string
+		tags          map[string]FieldTag
+		comment       string
+	}{
+		{
+			object:   "GetGreetingsRequest",
+			field:    "Page",
+			typeName: "services.Page",
+			tags:     map[string]FieldTag{"tagtest": {Value: "value", Options: []string{"option1", "option2"}}},
+			comment:  "Page describes which page of data to get.",
+		},
+		{
+			object:   "WelcomeRequest",
+			field:    "To",
+			typeName: "string",
+			tags:     map[string]FieldTag{"json": {Value: "recipients"}},
+			comment:  "To is the address of the person to send the message to.\nexample: \"your@email.com\"\nfeatured: true",
+		},
+		{
+			object:   "WelcomeRequest",
+			field:    "Name",
+			typeName: "*string",
+			tags:     map[string]FieldTag{},
+			comment:  "Name is the name of the person to welcome.\nexample: \"John Smith\"",
+		},
+		{
+			object:   "WelcomeRequest",
+			field:    "CustomerDetails",
+			typeName: "*CustomerDetails",
+			tags:     map[string]FieldTag{},
+			comment:  "CustomerDetails are the details about the customer.",
+		},
+		{
+			object:   "GetGreetingsResponse",
+			field:    "Greetings",
+			typeName: "Greeting",
+			tags:     map[string]FieldTag{"json": {Value: "greetings"}},
+		},
+		{
+			object:   "DoSomethingStrangeRequest",
+			field:    "Anything",
+			typeName: "interface{}",
+			tags:     map[string]FieldTag{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.object+"."+tt.field, func(t *testing.T) {
+			f := field(t, object(t, d, tt.object), tt.field)
+			if f.Type.TypeName != tt.typeName {
+				t.Errorf("TypeName = %q, want %q", f.Type.TypeName, tt.typeName)
+			}
+			if !reflect.DeepEqual(f.ParsedTags, tt.tags) {
+				t.Errorf("ParsedTags = %+v, want %+v", f.ParsedTags, tt.tags)
+			}
+			if f.Comment != tt.comment {
+				t.Errorf("Comment = %q, want %q", f.Comment, tt.comment)
+			}
+		})
+	}
+	greetings := field(t, object(t, d, "GetGreetingsResponse"), "Greetings")
+	if !greetings.Type.Multiple {
+		t.Error("Greetings isn't marked Multiple")
+	}
+}
+
+func TestParseTypeExprs(t *testing.T) {
+	doc := parse(t, nil, pleasantries, kinds)
+	p, k := pkg(t, doc, pleasantries), pkg(t, doc, kinds)
+	str := &TypeExpr{Kind: KindBasic, Name: "string"}
+	status := &TypeExpr{Kind: KindNamed, Name: "Status", Package: kinds, Underlying: str}
+	tests := []struct {
+		d             *Definition
+		object, field string
+		want          *TypeExpr
+	}{
+		{
+			d:      p,
+			object: "WelcomeRequest",
+			field:  "Name",
+			want:   &TypeExpr{Kind: KindPointer, Elem: str},
+		},
+		{
+			d:      p,
+			object: "GetGreetingsRequest",
+			field:  "Page",
+			want:   &TypeExpr{Kind: KindNamed, Name: "Page", Package: services, Qualifier: "services"},
+		},
+		{
+			d:      p,
+			object: "DoSomethingStrangeRequest",
+			field:  "Anything",
+			want:   &TypeExpr{Kind: KindInterface},
+		},
+		{
+			d:      k,
+			object: "CheckResponse",
+			field:  "Status",
+			want:   status,
+		},
+		{
+			d:      k,
+			object: "CheckResponse",
+			field:  "Timeout",
+			want: &TypeExpr{
+				Kind:       KindNamed,
+				Name:       "Duration",
+				Package:    "time",
+				Qualifier:  "time",
+				Underlying: &TypeExpr{Kind: KindBasic, Name: "int64"},
+			},
+		},
+		{
+			d:      k,
+			object: "CheckResponse",
+			field:  "Counts",
+			want:   &TypeExpr{Kind: KindMap, Key: status, Elem: &TypeExpr{Kind: KindBasic, Name: "int"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.object+"."+tt.field, func(t *testing.T) {
+			f := field(t, object(t, tt.d, tt.object), tt.field)
+			if !reflect.DeepEqual(f.Type.Expr, tt.want) {
+				got, _ := json.Marshal(f.Type.Expr)
+				want, _ := json.Marshal(tt.want)
+				t.Errorf("Expr = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestParseComments(t *testing.T) {
+	d := pkg(t, parse(t, nil, pleasantries), pleasantries)
+	s := service(d, "GreeterService")
+	if want := "GreeterService is a polite API.\nYou will love it.\nstrapline: \"A lovely greeter service\""; s.Comment != want {
+		t.Errorf("service comment = %q, want %q", s.Comment, want)
+	}
+	var methods []string
+	for _, m := range s.Methods {
+		methods = append(methods, m.Name+": "+m.Comment)
+	}
+	want := []string{
+		"GetGreetings: GetGreetings gets a range of saved Greetings.\nfeatured: false",
+		"Greet: Greet creates a Greeting for one or more people.\nfeatured: true",
+	}
+	if !reflect.DeepEqual(methods, want) {
+		t.Errorf("method comments = %q, want %q", methods, want)
+	}
+	if got, want := object(t, d, "GreetResponse").Comment, "GreetResponse is the response object containing a\nperson's greeting."; got != want {
+		t.Errorf("object comment = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,96 @@
+package parser
+
+import "strings"
+
+// reachable gets the TypeIDs of the objects the services use, directly in
+// their method signatures or through the fields of other objects, along
+// with the pointers, slices and maps of them and the named types declared
+// as those.
+func reachable(objects map[string]*Object, services []Service) map[string]bool {
+	seen := make(map[string]bool)
+	var visit func(expr *TypeExpr)
+	visit = func(expr *TypeExpr) {
+		if expr == nil {
+			return
+		}
+		visit(expr.Key)
+		visit(expr.Elem)
+		visit(expr.Underlying)
+		if expr.Kind != KindNamed || expr.Package == "" {
+			return
+		}
+		id := expr.Package + "." + expr.Name
+		if seen[id] {
+			return
+		}
+		seen[id] = true
+		obj, ok := objects[id]
+		if !ok {
+			return
+		}
+		for _, f := range obj.Fields {
+			visit(f.Type.Expr)
+		}
+	}
+	for _, s := range services {
+		for _, m := range s.Methods {
+			for _, ft := range m.InputObjects {
+				visit(ft.Expr)
+			}
+			for _, ft := range m.OutputObjects {
+				visit(ft.Expr)
+			}
+		}
+	}
+	return seen
+}
+
+// dropUnreachable removes the objects of the packages only used by the
+// excluded services, the interfaces ExcludeInterfaces or the Filter left
+// out, and with reachableOnly those no service uses, and reports them.
+func (d *Document) dropUnreachable(excluded []Service, reachableOnly bool) []Dropped {
+	objects := make(map[string]*Object)
+	var services []Service
+	for _, pkg := range d.Packages {
+		for i := range pkg.Objects {
+			objects[pkg.Objects[i].TypeID] = &pkg.Objects[i]
+		}
+		services = append(services, pkg.Services...)
+	}
+	used := reachable(objects, services)
+	// usedBy are the excluded services using each object
+	usedBy := make(map[string][]string)
+	for _, s := range excluded {
+		for id := range reachable(objects, []Service{s}) {
+			usedBy[id] = append(usedBy[id], s.Name)
+		}
+	}
+
+	dropped := []Dropped{}
+	for _, pkg := range d.Packages {
+		kept := make([]Object, 0, len(pkg.Objects))
+		for _, obj := range pkg.Objects {
+			var reason string
+			switch names := usedBy[obj.TypeID]; {
+			case used[obj.TypeID]:
+			case len(names) == 1:
+				reason = "only used by the excluded interface " + names[0]
+			case len(names) > 1:
+				reason = "only used by the excluded interfaces " + strings.Join(names, ", ")
+			case reachableOnly:
+				reason = "not used by any service"
+			}
+			if reason == "" {
+				kept = append(kept, obj)
+				continue
+			}
+			dropped = append(dropped, Dropped{
+				Package: pkg.PackagePath,
+				TypeID:  obj.TypeID,
+				Reason:  reason,
+			})
+		}
+		pkg.Objects = kept
+	}
+	return dropped
+}
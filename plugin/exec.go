@@ -0,0 +1,72 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Find gets the path of the executable of the plugin name. A name with a
+// path separator is used as a path, resolved against dir unless it is
+// absolute. Otherwise it is looked up on PATH with Prefix added if it
+// doesn't have it, and only then with the plain name, so a plugin isn't
+// mistaken for a command of the same name such as diff.
+func Find(dir, name string) (string, error) {
+	if strings.ContainsRune(name, '/') || strings.ContainsRune(name, filepath.Separator) {
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(dir, name)
+		}
+		return exec.LookPath(name)
+	}
+	if !strings.HasPrefix(name, Prefix) {
+		if path, err := exec.LookPath(Prefix + name); err == nil {
+			return path, nil
+		}
+	}
+	return exec.LookPath(name)
+}
+
+// Exec runs the plugin at path with req and returns its Response. It
+// returns an error if the plugin fails, responds with an Error or an
+// error Diagnostic, or lists a file outside the OutputDir.
+func Exec(path string, req *Request) (*Response, error) {
+	if req.ProtocolVersion == "" {
+		req.ProtocolVersion = ProtocolVersion
+	}
+	in, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(path)
+	cmd.Stdin = bytes.NewReader(in)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %w: %s", filepath.Base(path), err, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	var res Response
+	if err := json.Unmarshal(stdout.Bytes(), &res); err != nil {
+		return nil, fmt.Errorf("%s: reading response: %w", filepath.Base(path), err)
+	}
+	if res.Error != "" {
+		return &res, fmt.Errorf("%s: %s", filepath.Base(path), res.Error)
+	}
+	for _, d := range res.Diagnostics {
+		if d.Severity == Error {
+			return &res, fmt.Errorf("%s: %s", filepath.Base(path), d.Message)
+		}
+	}
+	for _, f := range res.Files {
+		if !filepath.IsLocal(filepath.FromSlash(f.Name)) {
+			return &res, fmt.Errorf("%s: %q is not a path inside the output directory", filepath.Base(path), f.Name)
+		}
+	}
+	return &res, nil
+}
@@ -0,0 +1,139 @@
+// Package plugin implements the protocol between fertilize and external
+// generators, and helps write them in Go.
+//
+// A plugin is an executable, usually named fertilize-gen-<name> and
+// found on PATH. fertilize writes a Request to its stdin as JSON and
+// reads a Response from its stdout. The plugin doesn't write files
+// itself: it lists them in the Response, and fertilize writes those that
+// changed, or only checks them with fertilize check.
+//
+// A plugin in Go:
+//
+//	func main() {
+//		plugin.Main(func(req *plugin.Request, res *plugin.Response) error {
+//			for _, d := range req.Document.Packages {
+//				res.AddFile(d.PackageName+".txt", d.PackagePath+"\n")
+//			}
+//			return nil
+//		})
+//	}
+//
+// Plugins in other languages read and write the same JSON.
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/gitamped/fertilize/parser"
+)
+
+// ProtocolVersion is the version of the protocol. It changes when
+// Requests or Responses change in a way plugins must know about.
+const ProtocolVersion = "1"
+
+// Prefix is prefixed to plugin names to find their executables.
+const Prefix = "fertilize-gen-"
+
+// Request is what fertilize sends a plugin.
+type Request struct {
+	// ProtocolVersion is the ProtocolVersion of fertilize.
+	ProtocolVersion string `json:"protocolVersion"`
+	// Document describes the packages to generate code for.
+	Document *parser.Document `json:"document"`
+	// Parameters are the options of the output in the config.
+	Parameters map[string]interface{} `json:"parameters"`
+	// OutputDir is the directory the files in the Response are
+	// written to.
+	OutputDir string `json:"outputDir"`
+}
+
+// Response is what a plugin sends back.
+type Response struct {
+	// Files are the files to write.
+	Files []File `json:"files"`
+	// Diagnostics are problems found generating the files, which
+	// fertilize prints.
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+	// Error is set when the plugin failed, in which case fertilize
+	// writes none of the files.
+	Error string `json:"error,omitempty"`
+}
+
+// File is a generated file.
+type File struct {
+	// Name is the path of the file, relative to the OutputDir of the
+	// Request, with / as separator. It can't leave the OutputDir.
+	Name string `json:"name"`
+	// Content is the content of the file.
+	Content string `json:"content"`
+}
+
+// Severities of Diagnostics.
+const (
+	// Warning is printed and generation goes on.
+	Warning = "warning"
+	// Error fails the generation, like Response.Error.
+	Error = "error"
+)
+
+// Diagnostic is a problem found by a plugin.
+type Diagnostic struct {
+	// Severity is Warning or Error.
+	Severity string `json:"severity"`
+	// Message describes the problem.
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return d.Severity + ": " + d.Message
+}
+
+// AddFile adds a file to the response.
+func (r *Response) AddFile(name, content string) {
+	r.Files = append(r.Files, File{Name: name, Content: content})
+}
+
+// Warnf adds a warning to the response.
+func (r *Response) Warnf(format string, args ...interface{}) {
+	r.Diagnostics = append(r.Diagnostics, Diagnostic{
+		Severity: Warning,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// ReadRequest reads a Request.
+func ReadRequest(r io.Reader) (*Request, error) {
+	var req Request
+	if err := json.NewDecoder(r).Decode(&req); err != nil {
+		return nil, fmt.Errorf("reading request: %w", err)
+	}
+	return &req, nil
+}
+
+// WriteResponse writes a Response.
+func WriteResponse(w io.Writer, res *Response) error {
+	return json.NewEncoder(w).Encode(res)
+}
+
+// Main runs a plugin: it reads the Request from stdin, calls generate to
+// fill in the Response and writes it to stdout. An error returned by
+// generate is sent as the Error of the Response. Main exits if the
+// Request can't be read or the Response can't be written.
+func Main(generate func(req *Request, res *Response) error) {
+	req, err := ReadRequest(os.Stdin)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	res := &Response{Files: []File{}}
+	if err := generate(req, res); err != nil {
+		res.Error = err.Error()
+	}
+	if err := WriteResponse(os.Stdout, res); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
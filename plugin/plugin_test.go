@@ -0,0 +1,195 @@
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/gitamped/fertilize/parser"
+)
+
+// TestMain runs the test binary as the plugin the tests execute when
+// FERTILIZE_TEST_PLUGIN is set.
+func TestMain(m *testing.M) {
+	if os.Getenv("FERTILIZE_TEST_PLUGIN") == "1" {
+		Main(fakePlugin)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// fakePlugin does what the mode parameter of the Request says.
+func fakePlugin(req *Request, res *Response) error {
+	switch req.Parameters["mode"] {
+	case "crash":
+		fmt.Fprintln(os.Stderr, "plugin crashed")
+		os.Exit(2)
+	case "garbage":
+		fmt.Println("not a response")
+		os.Exit(0)
+	case "error":
+		return errors.New("no services to generate")
+	case "diagnostic":
+		res.Warnf("first a warning")
+		res.Diagnostics = append(res.Diagnostics, Diagnostic{Severity: Error, Message: "bad option"})
+	case "file":
+		res.AddFile(req.Parameters["name"].(string), "")
+	default:
+		res.AddFile("version.txt", req.ProtocolVersion)
+		res.AddFile("dir/out.txt", req.OutputDir)
+		res.Warnf("%d packages", len(req.Document.Packages))
+	}
+	return nil
+}
+
+// run runs the test binary as a plugin with the mode and other
+// parameters.
+func run(t *testing.T, mode string, parameters map[string]interface{}) (*Response, error) {
+	t.Helper()
+	t.Setenv("FERTILIZE_TEST_PLUGIN", "1")
+	if parameters == nil {
+		parameters = map[string]interface{}{}
+	}
+	parameters["mode"] = mode
+	return Exec(os.Args[0], &Request{
+		Document:   &parser.Document{Packages: []*parser.Definition{{PackageName: "pleasantries"}}},
+		Parameters: parameters,
+		OutputDir:  "gen",
+	})
+}
+
+func TestExec(t *testing.T) {
+	res, err := run(t, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Response{
+		Files: []File{
+			{Name: "version.txt", Content: ProtocolVersion},
+			{Name: "dir/out.txt", Content: "gen"},
+		},
+		Diagnostics: []Diagnostic{{Severity: Warning, Message: "1 packages"}},
+	}
+	if !reflect.DeepEqual(res, want) {
+		t.Errorf("Exec = %+v, want %+v", res, want)
+	}
+}
+
+func TestExecPaths(t *testing.T) {
+	abs := "/etc/passwd"
+	if runtime.GOOS == "windows" {
+		abs = `C:\Windows\win.ini`
+	}
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{name: "out.go", ok: true},
+		{name: "dir/out.go", ok: true},
+		{name: "dir/../out.go", ok: true},
+		{name: "../x"},
+		{name: "dir/../../x"},
+		{name: abs},
+		{name: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := run(t, "file", map[string]interface{}{"name": tt.name})
+			switch {
+			case tt.ok && err != nil:
+				t.Errorf("Exec error = %v", err)
+			case !tt.ok && (err == nil || !strings.Contains(err.Error(), "not a path inside the output directory")):
+				t.Errorf("Exec error = %v, want the file rejected", err)
+			}
+		})
+	}
+}
+
+func TestExecErrors(t *testing.T) {
+	tests := []struct {
+		mode string
+		// want are parts of the error expected.
+		want []string
+		// response is set if the Response is returned with the error.
+		response bool
+	}{
+		{mode: "crash", want: []string{"exit status 2", "plugin crashed"}},
+		{mode: "garbage", want: []string{"reading response"}},
+		{mode: "error", want: []string{"no services to generate"}, response: true},
+		{mode: "diagnostic", want: []string{"bad option"}, response: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			res, err := run(t, tt.mode, nil)
+			if err == nil {
+				t.Fatal("Exec succeeded")
+			}
+			for _, want := range append(tt.want, filepath.Base(os.Args[0])) {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Exec error = %q, want it to contain %q", err, want)
+				}
+			}
+			if (res != nil) != tt.response {
+				t.Errorf("Exec response = %+v, want one: %v", res, tt.response)
+			}
+		})
+	}
+}
+
+func TestExecProtocolVersion(t *testing.T) {
+	t.Setenv("FERTILIZE_TEST_PLUGIN", "1")
+	req := &Request{
+		ProtocolVersion: "0",
+		Document:        &parser.Document{},
+		Parameters:      map[string]interface{}{},
+	}
+	res, err := Exec(os.Args[0], req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := res.Files[0].Content; got != "0" {
+		t.Errorf("plugin got protocol version %q, want the one requested", got)
+	}
+}
+
+func TestFind(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins are shell scripts")
+	}
+	dir := t.TempDir()
+	for _, name := range []string{"fertilize-gen-docs", "local", "diff", "fertilize-gen-diff"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir)
+	tests := []struct {
+		dir, name string
+		want      string
+	}{
+		{name: "docs", want: filepath.Join(dir, "fertilize-gen-docs")},
+		{name: "fertilize-gen-docs", want: filepath.Join(dir, "fertilize-gen-docs")},
+		// the plugin wins over the command of the same name
+		{name: "diff", want: filepath.Join(dir, "fertilize-gen-diff")},
+		{name: "local", want: filepath.Join(dir, "local")},
+		{name: filepath.Join(dir, "local"), want: filepath.Join(dir, "local")},
+		{dir: dir, name: "./local", want: filepath.Join(dir, "local")},
+		{name: "nope"},
+		{name: "fertilize-gen-nope"},
+		{dir: t.TempDir(), name: "./local"},
+	}
+	for _, tt := range tests {
+		got, err := Find(tt.dir, tt.name)
+		switch {
+		case tt.want == "" && err == nil:
+			t.Errorf("Find(%q, %q) = %q, want an error", tt.dir, tt.name, got)
+		case tt.want != "" && (err != nil || got != tt.want):
+			t.Errorf("Find(%q, %q) = %q, %v, want %q", tt.dir, tt.name, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/gitamped/fertilize/parser"
+)
+
+// Input is what a Command writes to the stdin of the external engine, as
+// JSON.
+type Input struct {
+	// Template is the path of the template to render.
+	Template string `json:"template"`
+	// Package is the Definition to render the template for.
+	Package *parser.Definition `json:"package"`
+	// Document is the Document the package is part of.
+	Document *parser.Document `json:"document"`
+	// Options are the options from the config.
+	Options map[string]interface{} `json:"options"`
+}
+
+// Command renders templates by running an external command with the
+// template path as its last argument. The command reads an Input on
+// stdin and writes the rendered template to stdout.
+type Command struct {
+	// Args are the command and its arguments.
+	Args []string
+	// Dir is the directory the command runs in. Empty means the
+	// current directory.
+	Dir string
+	// Template is the path of the template.
+	Template string
+	// Options are passed to the command in the Input.
+	Options map[string]interface{}
+}
+
+func (c *Command) Render(w io.Writer, doc *parser.Document, def *parser.Definition) error {
+	if len(c.Args) == 0 {
+		return errors.New("no command to render with")
+	}
+	options := c.Options
+	if options == nil {
+		options = map[string]interface{}{}
+	}
+	in, err := json.Marshal(Input{
+		Template: c.Template,
+		Package:  def,
+		Document: doc,
+		Options:  options,
+	})
+	if err != nil {
+		return err
+	}
+	args := append(append([]string{}, c.Args[1:]...), c.Template)
+	cmd := exec.Command(c.Args[0], args...)
+	cmd.Dir = c.Dir
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = w
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%s: %w: %s", c.Args[0], err, msg)
+		}
+		return fmt.Errorf("%s: %w", c.Args[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+// Package render renders templates for the packages of a Document.
+//
+// Templates are Go text/template or html/template templates, or are
+// rendered by an external command such as a Jinja or Handlebars script,
+// which reads the Document as JSON on stdin.
+package render
+
+import (
+	htmltemplate "html/template"
+	"io"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/gitamped/fertilize/gen"
+	"github.com/gitamped/fertilize/parser"
+)
+
+// Renderer renders a template for a package.
+type Renderer interface {
+	// Render renders the template for def, one of the packages of the
+	// Document, to w.
+	Render(w io.Writer, doc *parser.Document, def *parser.Definition) error
+}
+
+// Engines built into fertilize.
+const (
+	// TextEngine renders text/template templates.
+	TextEngine = "text"
+	// HTMLEngine renders html/template templates, escaping values
+	// for the context they appear in.
+	HTMLEngine = "html"
+)
+
+// Text parses a text/template template. The document function of the
+// template gets the Document being rendered, and funcs are available
+// too.
+func Text(name, src string, funcs map[string]interface{}) (Renderer, error) {
+	t, err := texttemplate.New(name).
+		Funcs(DocumentFuncs(nil)).
+		Funcs(funcs).
+		Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return &textRenderer{t: t}, nil
+}
+
+type textRenderer struct {
+	t *texttemplate.Template
+}
+
+func (r *textRenderer) Render(w io.Writer, doc *parser.Document, def *parser.Definition) error {
+	t, err := r.t.Clone()
+	if err != nil {
+		return err
+	}
+	return t.Funcs(DocumentFuncs(doc)).Execute(w, def)
+}
+
+// HTML parses an html/template template. Like Text templates, they have
+// a document function along with funcs.
+func HTML(name, src string, funcs map[string]interface{}) (Renderer, error) {
+	t, err := htmltemplate.New(name).
+		Funcs(DocumentFuncs(nil)).
+		Funcs(funcs).
+		Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return &htmlRenderer{t: t}, nil
+}
+
+type htmlRenderer struct {
+	t *htmltemplate.Template
+}
+
+func (r *htmlRenderer) Render(w io.Writer, doc *parser.Document, def *parser.Definition) error {
+	t, err := r.t.Clone()
+	if err != nil {
+		return err
+	}
+	return t.Funcs(DocumentFuncs(doc)).Execute(w, def)
+}
+
+// Funcs are the functions fertilize makes available to templates besides
+// document, with options from the config.
+func Funcs(options map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		// option gets a value from the options section of the config.
+		"option": func(key string) interface{} {
+			return options[strings.ToLower(key)]
+		},
+		// goType formats a FieldType as Go.
+		"goType": gen.GoType,
+		// methodImports gets the packages used by the method
+		// signatures of a Definition, keyed by import path.
+		"methodImports": gen.MethodImports,
+		// isSeedMethod reports whether a Method has the shape seed
+		// servers call.
+		"isSeedMethod": gen.IsSeedMethod,
+	}
+}
+
+// DocumentFuncs are the functions giving templates the Document.
+func DocumentFuncs(doc *parser.Document) map[string]interface{} {
+	return map[string]interface{}{
+		// document gets the Document the Definition is part of, for
+		// the module, the other packages and so on.
+		"document": func() *parser.Document {
+			return doc
+		},
+	}
+}
@@ -0,0 +1,171 @@
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gitamped/fertilize/parser"
+)
+
+// document has a package, pleasantries, whose service's comment needs
+// escaping in HTML.
+func document() (*parser.Document, *parser.Definition) {
+	def := &parser.Definition{
+		PackageName: "pleasantries",
+		PackagePath: "example.com/pleasantries",
+		Services: []parser.Service{{
+			Name:    "GreeterService",
+			Comment: `Greets <b>"everyone"</b> & more.`,
+		}},
+	}
+	return &parser.Document{Module: &parser.Module{Path: "example.com"}, Packages: []*parser.Definition{def}}, def
+}
+
+func TestText(t *testing.T) {
+	doc, def := document()
+	src := `{{.PackageName}} of {{document.Module.Path}}: {{range .Services}}{{.Comment}}{{end}} {{option "Greeting"}}`
+	r, err := Text("test", src, Funcs(map[string]interface{}{"greeting": "hi"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got strings.Builder
+	if err := r.Render(&got, doc, def); err != nil {
+		t.Fatal(err)
+	}
+	want := `pleasantries of example.com: Greets <b>"everyone"</b> & more. hi`
+	if got.String() != want {
+		t.Errorf("Render = %q, want %q", got.String(), want)
+	}
+
+	// each render gets its own document
+	other := &parser.Document{Module: &parser.Module{Path: "example.org"}, Packages: doc.Packages}
+	got.Reset()
+	if err := r.Render(&got, other, def); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got.String(), "of example.org") {
+		t.Errorf("Render = %q, want the other document's module", got.String())
+	}
+}
+
+func TestTextErrors(t *testing.T) {
+	if _, err := Text("test", "{{.PackageName", nil); err == nil {
+		t.Error("parsed an unclosed action")
+	}
+	doc, def := document()
+	r, err := Text("test", "{{.NoSuchField}}", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Render(&strings.Builder{}, doc, def); err == nil || !strings.Contains(err.Error(), "NoSuchField") {
+		t.Errorf("Render error = %v, want one about NoSuchField", err)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	doc, def := document()
+	src := `<p title="{{document.Module.Path}}">{{range .Services}}{{.Comment}}{{end}}</p>`
+	r, err := HTML("test.html", src, Funcs(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got strings.Builder
+	if err := r.Render(&got, doc, def); err != nil {
+		t.Fatal(err)
+	}
+	want := `<p title="example.com">Greets &lt;b&gt;&#34;everyone&#34;&lt;/b&gt; &amp; more.</p>`
+	if got.String() != want {
+		t.Errorf("Render = %q, want %q", got.String(), want)
+	}
+}
+
+// TestHelperProcess is the external engine Commands run in the tests
+// below. The template it is given says what to do.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+	defer os.Exit(0)
+	switch template := os.Args[len(os.Args)-1]; template {
+	case "fail.tmpl":
+		fmt.Fprintln(os.Stderr, "fail.tmpl:1: unexpected }}")
+		os.Exit(3)
+	case "silent.tmpl":
+		os.Exit(4)
+	default:
+		var in Input
+		if err := json.NewDecoder(os.Stdin).Decode(&in); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		fmt.Printf("%s %s %s %v", in.Template, in.Package.PackageName, in.Document.Module.Path, in.Options["greeting"])
+	}
+}
+
+// helper is a Command rendering template with TestHelperProcess.
+func helper(t *testing.T, template string) *Command {
+	t.Setenv("GO_WANT_HELPER_PROCESS", "1")
+	return &Command{
+		Args:     []string{os.Args[0], "-test.run=TestHelperProcess", "--"},
+		Template: template,
+		Options:  map[string]interface{}{"greeting": "hi"},
+	}
+}
+
+func TestCommand(t *testing.T) {
+	doc, def := document()
+	var got strings.Builder
+	if err := helper(t, "ok.tmpl").Render(&got, doc, def); err != nil {
+		t.Fatal(err)
+	}
+	if want := "ok.tmpl pleasantries example.com hi"; got.String() != want {
+		t.Errorf("Render = %q, want %q", got.String(), want)
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	doc, def := document()
+	tests := []struct {
+		name string
+		c    *Command
+		// want are parts of the error expected.
+		want []string
+	}{
+		{
+			name: "no command",
+			c:    &Command{Template: "ok.tmpl"},
+			want: []string{"no command"},
+		},
+		{
+			name: "stderr",
+			c:    helper(t, "fail.tmpl"),
+			want: []string{"exit status 3", "fail.tmpl:1: unexpected }}"},
+		},
+		{
+			name: "no stderr",
+			c:    helper(t, "silent.tmpl"),
+			want: []string{"exit status 4"},
+		},
+		{
+			name: "not found",
+			c:    &Command{Args: []string{"fertilize-no-such-engine"}, Template: "ok.tmpl"},
+			want: []string{"fertilize-no-such-engine"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.c.Render(&strings.Builder{}, doc, def)
+			if err == nil {
+				t.Fatal("Render succeeded")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Render error = %q, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
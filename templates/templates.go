@@ -0,0 +1,83 @@
+// Package templates holds the templates built into fertilize, which
+// outputs name as builtin:<name>.
+//
+// Each template starts with a comment describing it.
+package templates
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Prefix marks template paths naming a built-in template.
+const Prefix = "builtin:"
+
+//go:embed *.tmpl
+var files embed.FS
+
+// Template is a built-in template.
+type Template struct {
+	// Name is the name of the template, without Prefix.
+	Name string
+	// Description is the comment the template starts with.
+	Description string
+}
+
+// Name gets the name of the built-in template path names, reporting
+// whether it names one.
+func Name(path string) (string, bool) {
+	if !strings.HasPrefix(path, Prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(path, Prefix), true
+}
+
+// List lists the built-in templates ordered by name.
+func List() ([]Template, error) {
+	entries, err := fs.ReadDir(files, ".")
+	if err != nil {
+		return nil, err
+	}
+	list := make([]Template, 0, len(entries))
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), path.Ext(e.Name()))
+		b, err := files.ReadFile(e.Name())
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, Template{Name: name, Description: description(b)})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list, nil
+}
+
+// ErrNotFound is returned when there is no built-in template with a name.
+var ErrNotFound = errors.New("no such built-in template")
+
+// Read gets the source of the built-in template name.
+func Read(name string) ([]byte, error) {
+	b, err := files.ReadFile(name + ".tmpl")
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%s%s: %w", Prefix, name, ErrNotFound)
+	}
+	return b, err
+}
+
+var descriptionComment = regexp.MustCompile(`^\{\{/\*\s*(.*?)\s*\*/`)
+
+// description gets the comment a template starts with.
+func description(src []byte) string {
+	m := descriptionComment.FindSubmatch(src)
+	if m == nil {
+		return ""
+	}
+	return string(m[1])
+}
@@ -0,0 +1,33 @@
+package templates_test
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/gitamped/fertilize/fertilizetest"
+	"github.com/gitamped/fertilize/templates"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestBuiltins(t *testing.T) {
+	// typeCheck are the templates generating Go
+	typeCheck := map[string]bool{
+		"mocks":         true,
+		"seed-handlers": true,
+	}
+	list, err := templates.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tmpl := range list {
+		fertilizetest.Test(t, fertilizetest.Case{
+			Name:      tmpl.Name,
+			Template:  templates.Prefix + tmpl.Name,
+			Dir:       "../examples/testdata",
+			Packages:  []string{"./services/pleasantries", "./services/filters"},
+			Golden:    "../../templates/testdata/{{.PackageName}}_" + tmpl.Name + ".golden",
+			TypeCheck: typeCheck[tmpl.Name],
+		}, *update)
+	}
+}